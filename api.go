@@ -1,46 +1,286 @@
 package spark
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// BaseURL is the root of the Spark API, that the URLs of the resources (ex. RoomsURL) are under.
+const BaseURL = "https://api.ciscospark.com/v1"
+
 type httpClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
 var httpCli = httpClient(new(http.Client))
 
+// How long to wait before retrying a rate limited request if the server doesn't send a usable Retry-After header.
+const defaultRetryAfter = time.Second
+
 func (c *client) request(req *http.Request) ([]byte, error) {
-	// All requests require these headers
+	_, bs, err := c.requestWithResponse(req)
+	return bs, err
+}
+
+// Works like request, except it also returns the response itself, so callers can inspect the status code and headers.
+// The response body has already been read and closed by the time this returns.
+func (c *client) requestWithResponse(req *http.Request) (*http.Response, []byte, error) {
+	res, bs, err := c.do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// return code should be 200, 204 for delete methods, or 304 for conditional requests that haven't changed
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusNotModified {
+		return nil, nil, newAPIError(req, res, bs)
+	}
+
+	c.observe(req, res)
+	return res, bs, nil
+}
+
+// Passes the headers of a successful response to the client's observer, if it has one.
+func (c *client) observe(req *http.Request, res *http.Response) {
+	if c.observer != nil {
+		c.observer(resourceName(req.URL), res.Header)
+	}
+}
+
+// Passes the outcome of a request that was sent at start to the client's metrics observer, if it has one.
+func (c *client) observeMetrics(req *http.Request, status int, start time.Time) {
+	if c.metrics != nil {
+		c.metrics(resourceName(req.URL), req.Method, status, clk.Now().Sub(start))
+	}
+}
+
+// Returns the API resource that a request URL is for, ex. "rooms" for https://api.ciscospark.com/v1/rooms/123.
+func resourceName(u *url.URL) string {
+	path := strings.TrimPrefix(strings.TrimPrefix(u.Path, "/"), "v1/")
+	if i := strings.Index(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// Sets the headers that all requests require, sends the request, and reads the full response body.  The body is
+// always closed before returning, unless Do() itself fails.  If the server rate limits the request (HTTP 429), it
+// will be retried up to the client's retry limit, waiting as long as the server's Retry-After header asks each time,
+// unless the request's context is done first.
+func (c *client) do(req *http.Request) (*http.Response, []byte, error) {
+	// All requests require these headers.  Bodies are JSON unless the caller has already said otherwise.
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.apiVersion != "" {
+		req.Header.Set("Accept", fmt.Sprintf("application/json;version=%s", c.apiVersion))
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	// Anything but a GET may change the resource, so its cached copy can't be trusted anymore
+	if c.cache != nil && req.Method != "GET" {
+		u := *req.URL
+		u.RawQuery = ""
+		c.cache.remove(u.String())
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, bs, err := c.send(req)
+		if err != nil || res.StatusCode != http.StatusTooManyRequests || attempt >= c.maxRetries {
+			return res, bs, err
+		}
+
+		// The body of the previous attempt has been consumed, so it has to be rewound before the request can be
+		// resent.  If it can't be, give the 429 back to the caller instead.
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return res, bs, nil
+			}
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if err := sleep(req.Context(), retryAfter(res.Header)); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// Waits for d, or until ctx is done, in which case it returns ctx's error instead.
+func sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case <-clk.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	res, err := httpCli.Do(req)
+// Sends a single attempt of the request and reads the full response body.  If the client has a rate limit, this
+// waits for it first.
+func (c *client) send(req *http.Request) (*http.Response, []byte, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(req.Context()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	c.debugRequest(req)
+	start := clk.Now()
+	res, err := c.http().Do(req)
 	if err != nil {
-		return nil, err
+		c.observeMetrics(req, 0, start)
+		c.debugf("< error: %v\n", err)
+		return nil, nil, &TransportError{Err: err}
 	}
 	defer res.Body.Close()
 
 	bs, err := ioutil.ReadAll(res.Body)
+	c.observeMetrics(req, res.StatusCode, start)
 	if err != nil {
-		return nil, err
+		c.debugf("< %s (error reading body: %v)\n", res.Status, err)
+		return nil, nil, &BodyReadError{StatusCode: res.StatusCode, Err: err}
 	}
 
-	// return code should be 200, or 204 for delete methods
-	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
-		return nil, fmt.Errorf("HTTP Status %d: %q", res.StatusCode, string(bs))
+	c.debugf("< %s (%d bytes)\n", res.Status, len(bs))
+	if err := checkJSON(res, bs); err != nil {
+		return nil, nil, err
 	}
+	return res, bs, nil
+}
 
-	return bs, nil
+// Fails successful responses that have a body but say it isn't JSON, like the HTML error pages that some proxies send
+// with a 200.  Without this, they'd fail later on with a confusing decoding error.  Responses without a Content-Type
+// are given the benefit of the doubt.
+func checkJSON(res *http.Response, bs []byte) error {
+	if res.StatusCode < 200 || res.StatusCode > 299 || len(bytes.TrimSpace(bs)) == 0 {
+		return nil
+	}
+	ct := res.Header.Get("Content-Type")
+	if ct == "" {
+		return nil
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err == nil && (mt == "application/json" || mt == "text/json" || strings.HasSuffix(mt, "+json")) {
+		return nil
+	}
+	return &ContentTypeError{StatusCode: res.StatusCode, ContentType: ct, Body: bs}
+}
+
+// Logs a request's method, URL, and headers to the client's debug writer, if it has one, with the Authorization
+// header redacted.
+func (c *client) debugRequest(req *http.Request) {
+	if c.debug == nil {
+		return
+	}
+
+	c.debugf("> %s %s\n", req.Method, req.URL)
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := strings.Join(req.Header[k], ", ")
+		if k == "Authorization" {
+			v = "[REDACTED]"
+		}
+		c.debugf("> %s: %s\n", k, v)
+	}
+}
+
+func (c *client) debugf(format string, args ...interface{}) {
+	if c.debug != nil {
+		fmt.Fprintf(c.debug, format, args...)
+	}
 }
 
+// Parses a Retry-After header, which can be either a number of seconds or an HTTP date.
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(clk.Now()); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return defaultRetryAfter
+}
+
+// Unmarshals a response body into v.  Some endpoints return a 200 with an empty body on success, which json.Unmarshal
+// rejects with "unexpected end of JSON input".  An empty (or whitespace-only) body is instead treated as a successful
+// zero-value result, leaving v untouched.  If strict decoding is enabled, fields that v doesn't model are an error.
+// Bodies are decoded with the client's codec, except with strict decoding, which always uses encoding/json.
+func (c *client) unmarshal(b []byte, v interface{}) error {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+	if !c.strict {
+		return c.jsonCodec().Unmarshal(b, v)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// Sends a GET request for a single resource.  If the client has a GET cache (see SetGETCache), a cached response is
+// returned instead when there is one, and successful responses are cached.  The authenticated person is never
+// cached: people/me is an alias, so updates to the person under their ID wouldn't drop it, and clients sharing the
+// cache with another token would get each other's identity.
 func (c *client) getRequest(url string, uv url.Values) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := newGetRequest(context.Background(), url, uv)
+	if err != nil {
+		return nil, err
+	}
+	if c.cache == nil || url == PeopleURL+"/me" {
+		return c.request(req)
+	}
+
+	key := req.URL.String()
+	if b, ok := c.cache.get(key); ok {
+		return b, nil
+	}
+	res, b, err := c.requestWithResponse(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusOK {
+		c.cache.add(key, res, b)
+	}
+	return b, nil
+}
+
+// Works like getRequest, except the request is bound to the provided context, and never cached.
+func (c *client) getRequestContext(ctx context.Context, url string, uv url.Values) ([]byte, error) {
+	req, err := newGetRequest(ctx, url, uv)
+	if err != nil {
+		return nil, err
+	}
+	return c.request(req)
+}
+
+// Builds a GET request for url, with uv added to any parameters it already has.
+func newGetRequest(ctx context.Context, url string, uv url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -52,14 +292,44 @@ func (c *client) getRequest(url string, uv url.Values) ([]byte, error) {
 		}
 	}
 	req.URL.RawQuery = params.Encode()
-	return c.request(req)
+	return req, nil
 }
 
 func (c *client) postRequest(url string, body io.Reader) ([]byte, error) {
-	req, err := http.NewRequest("POST", url, body)
+	return c.postRequestContext(context.Background(), url, body)
+}
+
+// Works like postRequest, except the request is bound to the provided context.
+func (c *client) postRequestContext(ctx context.Context, url string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.request(req)
+}
+
+// Works like postRequest, except the body's length and content type are provided explicitly.  This is for bodies that
+// aren't JSON, like multipart uploads.  http.NewRequest can only determine the length of a *bytes.Buffer,
+// *bytes.Reader, or *strings.Reader body on its own, and any other reader is sent with chunked transfer encoding,
+// which some endpoints and proxies reject.  A negative length means the length is unknown.
+func (c *client) postRequestSized(url string, body io.Reader, length int64, contentType string) ([]byte, error) {
+	return c.sizedRequest("POST", url, body, length, contentType)
+}
+
+// Works like postRequestSized, except it sends a PUT.
+func (c *client) putRequestSized(url string, body io.Reader, length int64, contentType string) ([]byte, error) {
+	return c.sizedRequest("PUT", url, body, length, contentType)
+}
+
+func (c *client) sizedRequest(method, url string, body io.Reader, length int64, contentType string) ([]byte, error) {
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, err
 	}
+	if length >= 0 {
+		req.ContentLength = length
+	}
+	req.Header.Set("Content-Type", contentType)
 	return c.request(req)
 }
 
@@ -71,12 +341,53 @@ func (c *client) putRequest(url string, body io.Reader) ([]byte, error) {
 	return c.request(req)
 }
 
+// Works like the other request helpers, except that a successful response with an error object in its body (which
+// some proxies send instead of an error status) is treated as an error.  Deletes should return an empty 204, so
+// there's no legitimate body that could be mistaken for one.
 func (c *client) deleteRequest(url string) ([]byte, error) {
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
 		return nil, err
 	}
-	return c.request(req)
+
+	res, bs, err := c.requestWithResponse(req)
+	if err != nil {
+		return nil, err
+	}
+	if isErrorBody(bs) {
+		return nil, newAPIError(req, res, bs)
+	}
+	return bs, nil
+}
+
+// DoJSON is an escape hatch for calling endpoints that this package doesn't model yet, like newly released or beta
+// endpoints.  It sends an authenticated request with the given method to path, which is relative to BaseURL (ex.
+// "/recordings?max=10").  If body isn't nil, it's sent as the request's JSON body, and if out isn't nil, the
+// response's JSON body is decoded into it.  The request is sent like any other, with the client's retries, user agent,
+// and so on, and error statuses are returned as an *APIError.  Prefer the modeled methods where they exist: DoJSON
+// doesn't validate anything, or follow pagination.
+func (c *client) DoJSON(method, path string, body interface{}, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := c.encode(body)
+		if err != nil {
+			return err
+		}
+		r = b
+	}
+
+	req, err := http.NewRequest(method, BaseURL+"/"+strings.TrimPrefix(path, "/"), r)
+	if err != nil {
+		return err
+	}
+	resp, err := c.request(req)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return c.unmarshal(resp, out)
 }
 
 // Works like getRequest, except it handles paginated results.  It will retrieve up to max total entries, across
@@ -86,90 +397,210 @@ func (c *client) deleteRequest(url string) ([]byte, error) {
 // returned).  As a special case, if max is set to 0, this function will retrieve *all* values that the server makes
 // available.
 func (c *client) getRequestWithPaging(uri string, uv url.Values, max int) ([][]byte, error) {
-	all := false
-	if max == 0 {
-		all = true
+	var ret [][]byte
+	err := c.forEachPage(uri, uv, max, func(page []byte) (bool, error) {
+		ret = append(ret, page)
+		return true, nil
+	})
+	return ret, err
+}
+
+// Works like getRequest, except that it requests a single page of a list.  Unlike getRequestWithPaging, max is sent as
+// is rather than clamped to the client's page size (if max is 0, the server's default is used).  Along with the page,
+// this returns the pagination links the server sent with it.  Next is empty if the server indicated there are no more.
+func (c *client) getSinglePage(uri string, uv url.Values, max int) ([]byte, Links, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, Links{}, err
 	}
 
-	var ret [][]byte
-	for all || max > 0 {
+	params := req.URL.Query()
+	for k, vals := range uv {
+		for _, v := range vals {
+			params.Add(k, v)
+		}
+	}
+	if max > 0 {
+		params["max"] = []string{fmt.Sprintf("%d", max)}
+	}
+	req.URL.RawQuery = params.Encode()
+
+	res, b, err := c.do(req)
+	if err != nil {
+		return nil, Links{}, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, Links{}, newAPIError(req, res, b)
+	}
+	c.observe(req, res)
+	return b, parseLinkHeader(res.Header), nil
+}
+
+// Works like getRequestWithPaging, except that instead of collecting every page and returning them at the end, each
+// page is handed to fn as soon as it is received.  This lets callers process arbitrarily large result sets without
+// holding them all in memory, and stop early once they have what they need: if fn returns false, no further pages are
+// requested.  If fn returns an error, paging stops and that error is returned.
+func (c *client) forEachPage(uri string, uv url.Values, max int, fn func(page []byte) (bool, error)) error {
+	_, err := c.forEachPageWithCursor(uri, uv, max, fn, nil)
+	return err
+}
+
+// Works like forEachPage, except that before every page after the first is requested, cursor is given that page's
+// query parameters, and may adjust them.  This is for endpoints whose next links can't be followed as is.  A nil
+// cursor leaves the next links alone.  This also reports whether paging stopped because max was reached while the
+// server still had more results, as opposed to the server running out (or fn stopping it).
+func (c *client) forEachPageWithCursor(uri string, uv url.Values, max int, fn func(page []byte) (bool, error),
+	cursor func(params url.Values)) (bool, error) {
+	// How many more values are wanted, unless all of them are.  Each page asks for at most this many, and it only
+	// counts down by the size of the pages asked for, so it never goes below zero or overflows, however large max is.
+	// Nothing more is requested once it reaches zero, and nothing at all for a negative max.
+	all := max == 0
+	remaining := max
+
+	var pageMax int
+	if u, err := url.Parse(uri); err == nil {
+		pageMax = c.maxPerPage(resourceName(u))
+	} else {
+		pageMax = c.pageMax // the request below will fail with the same error
+	}
+
+	// The page size asked for on the last page, or the smaller one that the server has since capped it at
+	requested := 0
+	for pages := 0; all || remaining > 0; pages++ {
+		if c.maxPages > 0 && pages >= c.maxPages {
+			return false, ErrPageLimitExceeded
+		}
+
 		req, err := http.NewRequest("GET", uri, nil)
 		if err != nil {
-			return ret, err
+			return false, err
 		}
 
+		// Next links normally carry the query's parameters already, and repeating them (ex. personEmail twice) can get
+		// the request rejected, so only the ones a link left out are added back
 		params := req.URL.Query()
 		for k, vals := range uv {
+			if _, ok := params[k]; ok && pages > 0 {
+				continue
+			}
 			for _, v := range vals {
 				params.Add(k, v)
 			}
 		}
-		// We unconditionally overwrite the "max" parameter here.  We do this just in case the input uri has it
-		// set, and also because the "next" urls returned by paged queries have max set, but we sometimes want
-		// a different value that it sets for us.
-		if all || max > c.pageMax {
-			params["max"] = []string{fmt.Sprintf("%d", c.pageMax)}
-		} else {
-			params["max"] = []string{fmt.Sprintf("%d", max)}
+		// We overwrite the "max" parameter here, because the "next" urls returned by paged queries have max set, but
+		// we sometimes want a smaller value than the one it sets for us (ex. for the last page).  We never raise it,
+		// though: if the server capped its page size below ours, asking for more would only fight it.
+		perPage := pageMax
+		if !all && remaining < perPage {
+			perPage = remaining
+		}
+		if requested > 0 && requested < perPage {
+			perPage = requested
+		}
+		params["max"] = []string{fmt.Sprintf("%d", perPage)}
+		if pages > 0 && cursor != nil {
+			cursor(params)
 		}
 
-		// if max < pageMax, it'll go negative, but that'll end the loop just as effectively as setting it to 0.
-		// If All is set, in theory this could overflow, but that would require receiving more than 2.1 billion values
-		// (32-bit system) or 9 quintillion values (64-bit system), and if All is set, it doesn't really matter if it
-		// overflows, because we're looping until we run out anyway. Fortunately, overflowing an int in Go is not an
-		// error, it simply wraps around to positive integers.
-		max -= c.pageMax
+		if !all {
+			remaining -= perPage
+		}
+		requested = perPage
 
 		req.URL.RawQuery = params.Encode()
 
-		// All requests require these headers
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
-		req.Header.Set("Content-Type", "application/json; charset=utf-8")
-
-		res, err := httpCli.Do(req)
+		res, b, err := c.do(req)
 		if err != nil {
-			return ret, err
-		}
-		defer res.Body.Close()
-
-		b, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return ret, err
+			return false, err
 		}
 
 		// Return code should be 200, or 204 for delete methods
 		if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
-			return ret, fmt.Errorf("HTTP Status %d: %q", res.StatusCode, res.Status)
-		}
-
-		ret = append(ret, b)
-
-		// Check for pagination.  The Spark API indicates pagination by including a "Link" header.  This header
-		// can contain multiple URLs, but the one we care about is the rel="next" one, as that URL will give us the
-		// next page of results.  This will loop until the pagination stops or until the page limit argument is reached.
-		// As a special case, if pageLimit == 0, this will loop until the server stops returning next URLs, regardless
-		// of how many pages that involves.
-		found := false
-
-	headers:
-		for k, v := range res.Header {
-			if k == "Link" {
-				for _, l := range v {
-					spl := strings.Split(l, "; ")
-					if spl[1] == `rel="next"` {
-						found = true
-						// The format of the header is `<url?params>; rel="next"`
-						// The split above will leave spl[0] = `<url?params>`, so trim the first and last char
-						uri = spl[0][1 : len(spl[0])-1]
-					}
-					break headers
+			return false, newAPIError(req, res, b)
+		}
+		c.observe(req, res)
+
+		if more, err := fn(b); err != nil || !more {
+			return false, err
+		}
+
+		// Check for pagination.  The Spark API indicates pagination by including a "Link" header, and the rel="next"
+		// URL in it will give us the next page of results.  This will loop until the pagination stops or until the
+		// page limit argument is reached.  As a special case, if pageLimit == 0, this will loop until the server stops
+		// returning next URLs, regardless of how many pages that involves.
+		next := parseLinkHeader(res.Header).Next
+		if next == "" {
+			// Ran out of next headers, so the server has nothing more to give us
+			return false, nil
+		}
+		uri = next
+
+		// If the next link caps the page size below what we asked for, the server capped this page too, so only that
+		// many of the values we counted on were received.
+		if u, err := url.Parse(next); err == nil {
+			if n, err := strconv.Atoi(u.Query().Get("max")); err == nil && n > 0 && n < requested {
+				if !all {
+					remaining += requested - n
 				}
+				requested = n
 			}
 		}
-		if !found {
-			// Ran out of next headers, break and return
-			break
+	}
+	// Hit max, but the last page had a next link, so there may be more.  A negative max never requests a page, so it
+	// can't have cut anything short.
+	return max > 0, nil
+}
+
+// Links holds the pagination URLs from a list response's Link header, as returned by the *Single list methods (ex.
+// ListRoomsSingle).  Any of them may be empty, if the server didn't send that relation.
+type Links struct {
+	First string
+	Prev  string
+	Next  string
+	Last  string
+}
+
+// Parses the pagination URLs out of a response's Link headers.  The header is in the RFC 8288 format, ex.
+// `<https://api.ciscospark.com/v1/rooms?cursor=abc>; rel="next"`, and may contain several comma separated links, or be
+// repeated.  Relations other than first, prev, next, and last are ignored.
+func parseLinkHeader(h http.Header) Links {
+	var links Links
+	for _, v := range h["Link"] {
+		for {
+			start := strings.Index(v, "<")
+			end := strings.Index(v, ">")
+			if start < 0 || end < start {
+				break
+			}
+			uri := v[start+1 : end]
+
+			// The link's parameters run until the start of the next link, if there is one
+			v = v[end+1:]
+			params := v
+			if i := strings.Index(v, "<"); i >= 0 {
+				params, v = v[:i], v[i:]
+			}
+
+			for _, p := range strings.Split(params, ";") {
+				p = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(p), ","))
+				if !strings.HasPrefix(p, "rel=") {
+					continue
+				}
+				// A link can have several space separated relations, ex. rel="first prev"
+				for _, rel := range strings.Fields(strings.Trim(p[len("rel="):], `"`)) {
+					switch rel {
+					case "first":
+						links.First = uri
+					case "prev", "previous":
+						links.Prev = uri
+					case "next":
+						links.Next = uri
+					case "last":
+						links.Last = uri
+					}
+				}
+			}
 		}
 	}
-	return ret, nil
+	return links
 }
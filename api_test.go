@@ -2,10 +2,20 @@ package spark
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
+	"testing"
+	"time"
 
 	"io/ioutil"
 
@@ -50,6 +60,67 @@ var _ = Describe("API", func() {
 			Expect(resp).To(Equal(body))
 		})
 
+		It("sets the User-Agent header", func() {
+			var agents []string
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				agents = append(agents, req.Header.Get("User-Agent"))
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+				}
+				if len(agents) == 2 {
+					r.Header = map[string][]string{
+						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
+					}
+				}
+				return r, nil
+			}
+
+			_, err := c.getRequest(u, nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = c.SetUserAgent("my-bot/1.2").(*client).getRequestWithPaging(u, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = c.SetUserAgent("my-bot/1.2").SetUserAgent("").(*client).getRequest(u, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(agents).To(Equal([]string{DefaultUserAgent, "my-bot/1.2", "my-bot/1.2", DefaultUserAgent}))
+			Expect(DefaultUserAgent).To(Equal("go-spark/" + Version))
+		})
+
+		It("sets the Accept header to the version set by SetAPIVersion", func() {
+			var accepts []string
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				accepts = append(accepts, req.Header.Get("Accept"))
+				return &http.Response{Body: closer(bytes.NewBuffer(body)), StatusCode: http.StatusOK}, nil
+			}
+
+			_, err := c.getRequest(u, nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = c.SetAPIVersion(" 2.0-beta ").(*client).postRequest(u, bytes.NewBuffer(body))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = c.SetAPIVersion("2.0-beta").SetAPIVersion("").(*client).getRequest(u, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(accepts).To(Equal([]string{"", "application/json;version=2.0-beta", ""}))
+		})
+
+		It("authenticates with the token given to SetToken", func() {
+			var auths []string
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				auths = append(auths, req.Header.Get("Authorization"))
+				return &http.Response{Body: closer(bytes.NewBuffer(body)), StatusCode: http.StatusOK}, nil
+			}
+
+			refreshed := c.SetToken(" refreshed\n").(*client)
+			_, err := refreshed.getRequest(u, nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = c.getRequest(u, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(auths).To(Equal([]string{"Bearer refreshed", "Bearer mock"}))
+			Expect(refreshed.self).To(BeIdenticalTo(c.self))
+		})
+
 		It("calls Close() on the body", func() {
 			cls := closer(bytes.NewBuffer(body))
 
@@ -126,97 +197,144 @@ var _ = Describe("API", func() {
 			Expect(err.Error()).To(ContainSubstring("HTTP Status 500"))
 			Expect(resp).To(BeEmpty())
 		})
-	})
-
-	Describe("getRequest", func() {
-		It("calls with the correct method and values", func() {
-			vals := map[string][]string{
-				"1": {"a"},
-				"2": {"b"},
-				"3": {"c"},
-			}
 
+		It("fails a successful response that isn't JSON", func() {
+			page := "<html>" + strings.Repeat("x", 500) + "</html>"
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				uri := strings.Split(req.URL.String(), "?")[0]
-				Expect(uri).To(Equal(u))
-				Expect(req.Method).To(Equal("GET"))
-				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
-				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
-
-				for k, v := range vals {
-					Expect(req.URL.Query().Get(k)).To(Equal(v[0]))
-				}
-
 				r := &http.Response{
-					Body:       closer(bytes.NewBuffer(body)),
+					Body:       closer(bytes.NewBufferString(page)),
 					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"text/html"}},
 				}
 				return r, nil
 			}
 
-			resp, err := c.getRequest(u, url.Values(vals))
+			req, err := http.NewRequest("GET", u, nil)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(resp).To(Equal(body))
+
+			resp, err := c.request(req)
+			Expect(resp).To(BeEmpty())
+			cte, ok := err.(*ContentTypeError)
+			Expect(ok).To(BeTrue())
+			Expect(cte.StatusCode).To(Equal(http.StatusOK))
+			Expect(cte.ContentType).To(Equal("text/html"))
+			Expect(cte.Body).To(Equal([]byte(page)))
+			Expect(cte.Error()).To(HavePrefix(`expected JSON response but got text/html (HTTP Status 200): "<html>xxx`))
+			Expect(len(cte.Error())).To(BeNumerically("<", 300)) // only a snippet of the body
+
+			_, err = c.getRequestWithPaging(u, nil, 0)
+			Expect(err).To(BeAssignableToTypeOf(&ContentTypeError{}))
 		})
 
-		It("handles parameters that are already in the url", func() {
-			vals := map[string][]string{
-				"1": {"a"},
-				"2": {"b"},
-				"3": {"c"},
+		It("accepts JSON content types, and responses without a content type or body", func() {
+			for _, tc := range []struct {
+				contentType, body string
+			}{
+				{"application/json", `{}`},
+				{"application/json; charset=utf-8", `{}`},
+				{"application/problem+json", `{}`},
+				{"", `{}`},
+				{"text/plain", ""},
+			} {
+				tc := tc
+				mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+					r := &http.Response{
+						Body:       closer(bytes.NewBufferString(tc.body)),
+						StatusCode: http.StatusOK,
+						Header:     http.Header{},
+					}
+					if tc.contentType != "" {
+						r.Header.Set("Content-Type", tc.contentType)
+					}
+					return r, nil
+				}
+
+				req, err := http.NewRequest("GET", u, nil)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = c.request(req)
+				Expect(err).ToNot(HaveOccurred(), tc.contentType)
 			}
+		})
 
+		It("leaves error statuses with non-JSON bodies as APIErrors", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				uri := strings.Split(req.URL.String(), "?")[0]
-				Expect(uri).To(Equal(u))
-				Expect(req.Method).To(Equal("GET"))
-				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
-				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
-
-				for k, v := range vals {
-					Expect(req.URL.Query().Get(k)).To(Equal(v[0]))
-				}
-
 				r := &http.Response{
-					Body:       closer(bytes.NewBuffer(body)),
-					StatusCode: http.StatusOK,
+					Body:       closer(bytes.NewBufferString("<html>Bad Gateway</html>")),
+					StatusCode: http.StatusBadGateway,
+					Header:     http.Header{"Content-Type": {"text/html"}},
 				}
 				return r, nil
 			}
 
-			u2 := fmt.Sprintf("%s?1=a&2=b&3=c", u)
-
-			resp, err := c.getRequest(u2, nil)
+			req, err := http.NewRequest("GET", u, nil)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(resp).To(Equal(body))
+			_, err = c.request(req)
+			Expect(err).To(BeAssignableToTypeOf(&APIError{}))
 		})
 
-		It("handles a NewRequest() error properly", func() {
+		It("treats a 304 as not modified rather than an error", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				// This shouldn't be called in this test.  If it is, fail the test
-				Fail("unexpected call to http.Client.Do()")
-				return nil, nil
+				r := &http.Response{
+					Body:       closer(&bytes.Buffer{}),
+					StatusCode: http.StatusNotModified,
+				}
+				return r, nil
 			}
 
-			u2 := ":123" // invalid URL
-			resp, err := c.getRequest(u2, nil)
-			Expect(err).To(MatchError(fmt.Sprintf("parse %s: missing protocol scheme", u2)))
+			req, err := http.NewRequest("GET", u, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			res, resp, err := c.requestWithResponse(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusNotModified))
 			Expect(resp).To(BeEmpty())
 		})
 	})
 
-	Describe("postRequest", func() {
-		It("calls with the correct method and body", func() {
+	Describe("retries", func() {
+		var fake *fakeClock
+
+		BeforeEach(func() {
+			fake = &fakeClock{now: time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)}
+			clk = fake
+		})
+
+		AfterEach(func() {
+			clk = realClock{}
+		})
+
+		rateLimited := func(retryAfter string) *http.Response {
+			return &http.Response{
+				Body:       closer(bytes.NewBufferString("slow down")),
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": {retryAfter}},
+			}
+		}
+
+		It("doesn't retry by default", func() {
+			calls := 0
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				Expect(req.URL.String()).To(Equal(u))
-				Expect(req.Method).To(Equal("POST"))
-				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
-				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+				calls++
+				return rateLimited("3"), nil
+			}
 
-				b, err := ioutil.ReadAll(req.Body)
-				Expect(err).ToNot(HaveOccurred())
-				Expect(b).To(Equal(body))
+			req, err := http.NewRequest("GET", u, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = c.request(req)
+			Expect(err.Error()).To(ContainSubstring("HTTP Status 429"))
+			Expect(calls).To(Equal(1))
+			Expect(fake.sleeps).To(BeEmpty())
+		})
+
+		It("waits for the Retry-After duration before retrying a 429", func() {
+			c = c.SetMaxRetries(3).(*client)
 
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				if calls++; calls < 3 {
+					return rateLimited("3"), nil
+				}
 				r := &http.Response{
 					Body:       closer(bytes.NewBuffer(body)),
 					StatusCode: http.StatusOK,
@@ -224,37 +342,24 @@ var _ = Describe("API", func() {
 				return r, nil
 			}
 
-			resp, err := c.postRequest(u, bytes.NewBuffer(body))
+			req, err := http.NewRequest("GET", u, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			resp, err := c.request(req)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(resp).To(Equal(body))
+			Expect(calls).To(Equal(3))
+			Expect(fake.sleeps).To(Equal([]time.Duration{3 * time.Second, 3 * time.Second}))
 		})
 
-		It("handles a NewRequest() error properly", func() {
-			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				// This shouldn't be called in this test.  If it is, fail the test
-				Fail("unexpected call to http.Client.Do()")
-				return nil, nil
-			}
-
-			u2 := ":123" // invalid URL
-			resp, err := c.postRequest(u2, nil)
-			Expect(err).To(MatchError(fmt.Sprintf("parse %s: missing protocol scheme", u2)))
-			Expect(resp).To(BeEmpty())
-		})
-	})
+		It("accepts a Retry-After date", func() {
+			c = c.SetMaxRetries(1).(*client)
 
-	Describe("putRequest", func() {
-		It("calls with the correct method and body", func() {
+			calls := 0
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				Expect(req.URL.String()).To(Equal(u))
-				Expect(req.Method).To(Equal("PUT"))
-				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
-				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
-
-				b, err := ioutil.ReadAll(req.Body)
-				Expect(err).ToNot(HaveOccurred())
-				Expect(b).To(Equal(body))
-
+				if calls++; calls == 1 {
+					return rateLimited(fake.now.Add(10 * time.Second).Format(http.TimeFormat)), nil
+				}
 				r := &http.Response{
 					Body:       closer(bytes.NewBuffer(body)),
 					StatusCode: http.StatusOK,
@@ -262,82 +367,99 @@ var _ = Describe("API", func() {
 				return r, nil
 			}
 
-			resp, err := c.putRequest(u, bytes.NewBuffer(body))
+			req, err := http.NewRequest("GET", u, nil)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(resp).To(Equal(body))
+
+			_, err = c.request(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fake.sleeps).To(Equal([]time.Duration{10 * time.Second}))
 		})
 
-		It("handles a NewRequest() error properly", func() {
+		It("falls back to a default wait if Retry-After is missing or malformed", func() {
+			c = c.SetMaxRetries(1).(*client)
+
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				// This shouldn't be called in this test.  If it is, fail the test
-				Fail("unexpected call to http.Client.Do()")
-				return nil, nil
+				return rateLimited("soon"), nil
 			}
 
-			u2 := ":123" // invalid URL
-			resp, err := c.putRequest(u2, nil)
-			Expect(err).To(MatchError(fmt.Sprintf("parse %s: missing protocol scheme", u2)))
-			Expect(resp).To(BeEmpty())
+			req, err := http.NewRequest("GET", u, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = c.request(req)
+			Expect(err.Error()).To(ContainSubstring("HTTP Status 429"))
+			Expect(fake.sleeps).To(Equal([]time.Duration{defaultRetryAfter}))
 		})
-	})
 
-	Describe("deleteRequest", func() {
-		It("calls with the correct method and body", func() {
+		It("gives up after the maximum number of retries", func() {
+			c = c.SetMaxRetries(2).(*client)
+
+			calls := 0
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				Expect(req.URL.String()).To(Equal(u))
-				Expect(req.Method).To(Equal("DELETE"))
-				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
-				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+				calls++
+				return rateLimited("1"), nil
+			}
 
-				Expect(req.Body).To(BeNil())
+			req, err := http.NewRequest("GET", u, nil)
+			Expect(err).ToNot(HaveOccurred())
 
-				r := &http.Response{
-					Body:       closer(bytes.NewBuffer(body)),
-					StatusCode: http.StatusOK,
-				}
-				return r, nil
+			_, err = c.request(req)
+			Expect(err.Error()).To(ContainSubstring("HTTP Status 429"))
+			Expect(calls).To(Equal(3))
+			Expect(fake.sleeps).To(HaveLen(2))
+		})
+
+		It("stops waiting to retry when the request's context is done", func() {
+			c = c.SetMaxRetries(3).(*client)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				cancel()
+				return rateLimited("3"), nil
 			}
 
-			resp, err := c.deleteRequest(u)
+			req, err := http.NewRequest("GET", u, nil)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(resp).To(Equal(body))
+
+			_, err = c.request(req.WithContext(ctx))
+			Expect(err).To(Equal(context.Canceled))
+			Expect(calls).To(Equal(1))
+			Expect(fake.sleeps).To(BeEmpty())
 		})
 
-		It("handles a NewRequest() error properly", func() {
+		It("returns as soon as the context's deadline passes, however long Retry-After is", func() {
+			clk = realClock{}
+			c = c.SetMaxRetries(1).(*client)
+
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				// This shouldn't be called in this test.  If it is, fail the test
-				Fail("unexpected call to http.Client.Do()")
-				return nil, nil
+				return rateLimited("60"), nil
 			}
 
-			u2 := ":123" // invalid URL
-			resp, err := c.deleteRequest(u2)
-			Expect(err).To(MatchError(fmt.Sprintf("parse %s: missing protocol scheme", u2)))
-			Expect(resp).To(BeEmpty())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			req, err := http.NewRequest("GET", u, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			start := time.Now()
+			_, err = c.request(req.WithContext(ctx))
+			Expect(err).To(Equal(context.DeadlineExceeded))
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
 		})
-	})
 
-	Describe("getRequestWithPaging", func() {
-		It("calls with the correct method and values", func() {
-			vals := map[string][]string{
-				"1": {"a"},
-				"2": {"b"},
-				"3": {"c"},
-			}
-			max := 50
+		It("resends the full body on each retry", func() {
+			c = c.SetMaxRetries(1).(*client)
 
+			calls := 0
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				uri := strings.Split(req.URL.String(), "?")[0]
-				Expect(uri).To(Equal(u))
-				Expect(req.Method).To(Equal("GET"))
-				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
-				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+				b, err := ioutil.ReadAll(req.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b).To(Equal(body))
 
-				Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", max)))
-				for k, v := range vals {
-					Expect(req.URL.Query().Get(k)).To(Equal(v[0]))
+				if calls++; calls == 1 {
+					return rateLimited("1"), nil
 				}
-
 				r := &http.Response{
 					Body:       closer(bytes.NewBuffer(body)),
 					StatusCode: http.StatusOK,
@@ -345,31 +467,19 @@ var _ = Describe("API", func() {
 				return r, nil
 			}
 
-			resp, err := c.getRequestWithPaging(u, url.Values(vals), max)
+			_, err := c.postRequest(u, bytes.NewBuffer(body))
 			Expect(err).ToNot(HaveOccurred())
-			Expect(resp).To(ConsistOf([][]byte{body}))
+			Expect(calls).To(Equal(2))
 		})
 
-		It("handles parameters that are already in the url", func() {
-			vals := map[string][]string{
-				"1": {"a"},
-				"2": {"b"},
-				"3": {"c"},
-			}
-			max := 50
+		It("retries paged requests", func() {
+			c = c.SetMaxRetries(1).(*client)
 
+			calls := 0
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				uri := strings.Split(req.URL.String(), "?")[0]
-				Expect(uri).To(Equal(u))
-				Expect(req.Method).To(Equal("GET"))
-				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
-				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
-
-				Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", max)))
-				for k, v := range vals {
-					Expect(req.URL.Query().Get(k)).To(Equal(v[0]))
+				if calls++; calls == 1 {
+					return rateLimited("2"), nil
 				}
-
 				r := &http.Response{
 					Body:       closer(bytes.NewBuffer(body)),
 					StatusCode: http.StatusOK,
@@ -377,192 +487,228 @@ var _ = Describe("API", func() {
 				return r, nil
 			}
 
-			u2 := fmt.Sprintf("%s?1=a&2=b&3=c", u)
-
-			resp, err := c.getRequestWithPaging(u2, nil, max)
+			resp, err := c.getRequestWithPaging(u, nil, 0)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(resp).To(ConsistOf([][]byte{body}))
+			Expect(fake.sleeps).To(Equal([]time.Duration{2 * time.Second}))
 		})
+	})
 
-		It("calls with the client max if it's smaller than the max argument", func() {
-			max := 50
-			expected := 10
-			c.pageMax = expected
+	Describe("rate limit", func() {
+		var sent []time.Time
 
+		BeforeEach(func() {
+			sent = nil
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", expected)))
+				sent = append(sent, time.Now())
 				r := &http.Response{
 					Body:       closer(bytes.NewBuffer(body)),
 					StatusCode: http.StatusOK,
+					Header:     http.Header{},
+				}
+				if len(sent) < 3 {
+					r.Header.Set("Link", `<http://mock.url.com/mock?cursor=next>; rel="next"`)
 				}
 				return r, nil
 			}
-
-			resp, err := c.getRequestWithPaging(u, nil, max)
-			Expect(err).ToNot(HaveOccurred())
-			Expect(resp).To(ConsistOf([][]byte{body}))
 		})
 
-		It("pages", func() {
-			expectedCalls := 10
-			calls := 0
-			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", c.pageMax)))
+		It("spaces requests out to the limit", func() {
+			c = c.SetRateLimit(20, 1).(*client) // one request every 50ms
 
-				r := &http.Response{
-					Body:       closer(bytes.NewBuffer(body)),
-					StatusCode: http.StatusOK,
-				}
-				if calls++; calls < expectedCalls {
-					r.Header = map[string][]string{
-						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
-					}
-				}
+			for i := 0; i < 3; i++ {
+				req, err := http.NewRequest("GET", u, nil)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = c.request(req)
+				Expect(err).ToNot(HaveOccurred())
+			}
 
-				return r, nil
+			Expect(sent).To(HaveLen(3))
+			for i := 1; i < len(sent); i++ {
+				Expect(sent[i].Sub(sent[i-1])).To(BeNumerically(">=", 40*time.Millisecond))
 			}
+		})
+
+		It("spaces out the pages of paged requests", func() {
+			c = c.SetRateLimit(20, 1).(*client)
 
 			resp, err := c.getRequestWithPaging(u, nil, 0)
 			Expect(err).ToNot(HaveOccurred())
-			slice := make([][]byte, expectedCalls)
-			for i := 0; i < expectedCalls; i++ {
-				slice[i] = body
-			}
-			Expect(resp).To(ConsistOf(slice))
-			Expect(calls).To(Equal(expectedCalls))
+			Expect(resp).To(HaveLen(3))
+			Expect(sent[2].Sub(sent[0])).To(BeNumerically(">=", 80*time.Millisecond))
 		})
 
-		It("pages until max is hit", func() {
-			max := 50
-			clientmax := 10
-			c.pageMax = clientmax
-			expectedCalls := 5
+		It("allows bursts", func() {
+			c = c.SetRateLimit(1, 3).(*client)
 
-			calls := 0
-			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				calls++
-				Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", clientmax)))
+			start := time.Now()
+			_, err := c.getRequestWithPaging(u, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sent).To(HaveLen(3))
+			Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+		})
 
-				r := &http.Response{
-					Body:       closer(bytes.NewBuffer(body)),
-					StatusCode: http.StatusOK,
-					Header: map[string][]string{
-						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
-					},
-				}
+		It("is shared by copies of the client", func() {
+			limited := c.SetRateLimit(20, 1)
+			other := limited.SetMaxRetries(1).(*client)
 
-				return r, nil
-			}
+			req, err := http.NewRequest("GET", u, nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = limited.(*client).request(req)
+			Expect(err).ToNot(HaveOccurred())
 
-			resp, err := c.getRequestWithPaging(u, nil, max)
+			req, err = http.NewRequest("GET", u, nil)
 			Expect(err).ToNot(HaveOccurred())
-			slice := make([][]byte, expectedCalls)
-			for i := 0; i < expectedCalls; i++ {
-				slice[i] = body
-			}
-			Expect(resp).To(ConsistOf(slice))
-			Expect(calls).To(Equal(expectedCalls))
+			_, err = other.request(req)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(sent[1].Sub(sent[0])).To(BeNumerically(">=", 40*time.Millisecond))
 		})
 
-		It("pages with a non-integer multiple of client max", func() {
-			max := 55
-			clientmax := 10
-			c.pageMax = clientmax
-			expectedCalls := 6
+		It("gives up waiting when the request's context is done", func() {
+			c = c.SetRateLimit(0.1, 1).(*client) // one request every 10s
 
-			calls := 0
-			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				if calls++; calls < expectedCalls {
-					Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", clientmax)))
-				} else {
-					Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", max%clientmax)))
-				}
+			_, err := c.getRequest(u, nil)
+			Expect(err).ToNot(HaveOccurred())
 
-				r := &http.Response{
-					Body:       closer(bytes.NewBuffer(body)),
-					StatusCode: http.StatusOK,
-					Header: map[string][]string{
-						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
-					},
-				}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_, err = c.getRequestContext(ctx, u, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(sent).To(HaveLen(1))
+		})
 
-				return r, nil
-			}
+		It("doesn't limit the calling client, and can be removed", func() {
+			limited := c.SetRateLimit(1, 1).(*client)
+			Expect(c.limiter).To(BeNil())
+			Expect(limited.limiter).ToNot(BeNil())
+			Expect(limited.SetRateLimit(0, 1).(*client).limiter).To(BeNil())
+		})
+	})
 
-			resp, err := c.getRequestWithPaging(u, nil, max)
-			Expect(err).ToNot(HaveOccurred())
-			slice := make([][]byte, expectedCalls)
-			for i := 0; i < expectedCalls; i++ {
-				slice[i] = body
-			}
-			Expect(resp).To(ConsistOf(slice))
-			Expect(calls).To(Equal(expectedCalls))
+	Describe("unmarshal", func() {
+		It("unmarshals a JSON body", func() {
+			var r Room
+			Expect(c.unmarshal([]byte(`{"id":"1"}`), &r)).To(Succeed())
+			Expect(r.ID).To(Equal("1"))
 		})
 
-		It("calls Close() on the body", func() {
-			cls := closer(bytes.NewBuffer(body))
+		It("treats an empty or whitespace-only body as a zero value", func() {
+			var r Room
+			Expect(c.unmarshal(nil, &r)).To(Succeed())
+			Expect(c.unmarshal([]byte(" \n"), &r)).To(Succeed())
+			Expect(r).To(Equal(Room{}))
+		})
+
+		It("still fails on a malformed body", func() {
+			var r Room
+			Expect(c.unmarshal([]byte("not json"), &r)).ToNot(Succeed())
+		})
+
+		It("ignores unmodeled fields by default", func() {
+			var r Room
+			Expect(c.unmarshal([]byte(`{"id":"1","unmodeledField":"hello"}`), &r)).To(Succeed())
+			Expect(r.ID).To(Equal("1"))
+		})
+
+		It("fails on unmodeled fields in strict mode", func() {
+			c = c.SetStrictDecoding(true).(*client)
+
+			var r Room
+			err := c.unmarshal([]byte(`{"id":"1","unmodeledField":"hello"}`), &r)
+			Expect(err).To(MatchError(`json: unknown field "unmodeledField"`))
+			Expect(c.unmarshal([]byte(`{"id":"1"}`), &r)).To(Succeed())
+		})
+
+		It("doesn't modify the calling client when enabling strict mode", func() {
+			c.pageMax = 10
+			strict := c.SetStrictDecoding(true).(*client)
+			Expect(strict.strict).To(BeTrue())
+			Expect(strict.pageMax).To(Equal(10))
+			Expect(c.strict).To(BeFalse())
+		})
+	})
+
+	Describe("getRequest", func() {
+		It("calls with the correct method and values", func() {
+			vals := map[string][]string{
+				"1": {"a"},
+				"2": {"b"},
+				"3": {"c"},
+			}
 
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				uri := strings.Split(req.URL.String(), "?")[0]
+				Expect(uri).To(Equal(u))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+
+				for k, v := range vals {
+					Expect(req.URL.Query().Get(k)).To(Equal(v[0]))
+				}
+
 				r := &http.Response{
-					Body:       cls,
+					Body:       closer(bytes.NewBuffer(body)),
 					StatusCode: http.StatusOK,
 				}
 				return r, nil
 			}
 
-			resp, err := c.getRequestWithPaging(u, nil, 0)
+			resp, err := c.getRequest(u, url.Values(vals))
 			Expect(err).ToNot(HaveOccurred())
-			Expect(cls.closed).To(BeTrue())
-			Expect(resp).To(ConsistOf([][]byte{body}))
+			Expect(resp).To(Equal(body))
 		})
 
-		It("calls Close() on the body on each iteration", func() {
-			cls1 := closer(bytes.NewBuffer(body))
-			cls2 := closer(bytes.NewBuffer(body))
+		It("handles parameters that are already in the url", func() {
+			vals := map[string][]string{
+				"1": {"a"},
+				"2": {"b"},
+				"3": {"c"},
+			}
 
-			called := false
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				var r *http.Response
-				if !called {
-					called = true
-					r = &http.Response{
-						Body:       cls1,
-						StatusCode: http.StatusOK,
-						Header: map[string][]string{
-							"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
-						},
-					}
-				} else {
-					r = &http.Response{
-						Body:       cls2,
-						StatusCode: http.StatusOK,
-					}
+				uri := strings.Split(req.URL.String(), "?")[0]
+				Expect(uri).To(Equal(u))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+
+				for k, v := range vals {
+					Expect(req.URL.Query().Get(k)).To(Equal(v[0]))
+				}
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
 				}
 				return r, nil
 			}
 
-			resp, err := c.getRequestWithPaging(u, nil, 0)
+			u2 := fmt.Sprintf("%s?1=a&2=b&3=c", u)
+
+			resp, err := c.getRequest(u2, nil)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(cls1.closed).To(BeTrue())
-			Expect(cls2.closed).To(BeTrue())
-			Expect(resp).To(ConsistOf([][]byte{body, body}))
+			Expect(resp).To(Equal(body))
 		})
 
-		It("doesn't close the body if Do() fails", func() {
-			cls := closer(bytes.NewBuffer(body))
-
+		It("names the request's method and path, but not its query, in status errors", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
 				r := &http.Response{
-					Body:       cls,
-					StatusCode: http.StatusOK,
+					Body:       closer(bytes.NewBufferString("broken")),
+					StatusCode: http.StatusInternalServerError,
 				}
-				return r, mockErr
+				return r, nil
 			}
 
-			resp, err := c.getRequestWithPaging(u, nil, 0)
-			Expect(err).To(MatchError(mockErr))
-			Expect(cls.closed).To(BeFalse())
-			Expect(resp).To(BeEmpty())
+			_, err := c.getRequest(RoomsURL+"/1", url.Values{"email": {"secret@world.com"}})
+			Expect(err).To(MatchError(`GET /v1/rooms/1: HTTP Status 500: "broken"`))
+			Expect(err.Error()).ToNot(ContainSubstring("secret"))
+
+			var apiErr *APIError
+			Expect(errors.As(err, &apiErr)).To(BeTrue())
+			Expect(apiErr.Method).To(Equal("GET"))
+			Expect(apiErr.Path).To(Equal("/v1/rooms/1"))
 		})
 
 		It("handles a NewRequest() error properly", func() {
@@ -573,59 +719,678 @@ var _ = Describe("API", func() {
 			}
 
 			u2 := ":123" // invalid URL
-			resp, err := c.getRequestWithPaging(u2, nil, 0)
+			resp, err := c.getRequest(u2, nil)
 			Expect(err).To(MatchError(fmt.Sprintf("parse %s: missing protocol scheme", u2)))
 			Expect(resp).To(BeEmpty())
 		})
+	})
 
-		It("handles a NewRequest() error properly after the first iteration", func() {
-			called := false
-			u2 := ":123" // invalid URL
-
+	Describe("postRequest", func() {
+		It("calls with the correct method and body", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				if called {
-					// should only be called once, fail if called a second time
-					Fail("unexpected call to http.Client.Do()")
-				}
-				called = true
+				Expect(req.URL.String()).To(Equal(u))
+				Expect(req.Method).To(Equal("POST"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+
+				b, err := ioutil.ReadAll(req.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b).To(Equal(body))
 
 				r := &http.Response{
 					Body:       closer(bytes.NewBuffer(body)),
 					StatusCode: http.StatusOK,
-					Header: map[string][]string{
-						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u2)}, // invalid URL
-					},
 				}
 				return r, nil
 			}
 
-			resp, err := c.getRequestWithPaging(u, nil, 0)
-			Expect(err).To(MatchError(fmt.Sprintf("parse %s: missing protocol scheme", u2)))
-			Expect(resp).To(ConsistOf([][]byte{body}))
+			resp, err := c.postRequest(u, bytes.NewBuffer(body))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp).To(Equal(body))
 		})
 
-		It("handles a body read error properly", func() {
-			cls := closer(&failReader{})
-
+		It("sets the content length for a buffered body", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.ContentLength).To(BeEquivalentTo(len(body)))
 				r := &http.Response{
-					Body:       cls,
+					Body:       closer(bytes.NewBuffer(body)),
 					StatusCode: http.StatusOK,
 				}
 				return r, nil
 			}
 
-			resp, err := c.getRequestWithPaging(u, nil, 0)
-			Expect(err).To(MatchError(mockErr))
-			Expect(cls.closed).To(BeTrue())
-			Expect(resp).To(BeEmpty())
+			_, err := c.postRequest(u, bytes.NewBuffer(body))
+			Expect(err).ToNot(HaveOccurred())
 		})
 
-		It("handles a body read error properly after the first iteration", func() {
-			cls1 := closer(bytes.NewBuffer(body))
-			cls2 := closer(&failReader{})
-
-			called := false
+		It("handles a NewRequest() error properly", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				// This shouldn't be called in this test.  If it is, fail the test
+				Fail("unexpected call to http.Client.Do()")
+				return nil, nil
+			}
+
+			u2 := ":123" // invalid URL
+			resp, err := c.postRequest(u2, nil)
+			Expect(err).To(MatchError(fmt.Sprintf("parse %s: missing protocol scheme", u2)))
+			Expect(resp).To(BeEmpty())
+		})
+	})
+
+	Describe("postRequestSized", func() {
+		It("sends the explicit length and content type for a reader of unknown length", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(u))
+				Expect(req.Method).To(Equal("POST"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				Expect(req.Header.Get("Content-Type")).To(Equal("multipart/form-data; boundary=mock"))
+				Expect(req.ContentLength).To(BeEquivalentTo(len(body)))
+
+				b, err := ioutil.ReadAll(req.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b).To(Equal(body))
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			// wrapping the buffer hides its type, so http.NewRequest can't determine the length on its own
+			resp, err := c.postRequestSized(u, io.MultiReader(bytes.NewBuffer(body)), int64(len(body)), "multipart/form-data; boundary=mock")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp).To(Equal(body))
+		})
+
+		It("leaves the length unknown if it's negative", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.ContentLength).To(BeZero()) // http.Request's representation of "unknown" for a non-nil body
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			_, err := c.postRequestSized(u, io.MultiReader(bytes.NewBuffer(body)), -1, "text/plain")
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("putRequest", func() {
+		It("calls with the correct method and body", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(u))
+				Expect(req.Method).To(Equal("PUT"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+
+				b, err := ioutil.ReadAll(req.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b).To(Equal(body))
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			resp, err := c.putRequest(u, bytes.NewBuffer(body))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp).To(Equal(body))
+		})
+
+		It("handles a NewRequest() error properly", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				// This shouldn't be called in this test.  If it is, fail the test
+				Fail("unexpected call to http.Client.Do()")
+				return nil, nil
+			}
+
+			u2 := ":123" // invalid URL
+			resp, err := c.putRequest(u2, nil)
+			Expect(err).To(MatchError(fmt.Sprintf("parse %s: missing protocol scheme", u2)))
+			Expect(resp).To(BeEmpty())
+		})
+	})
+
+	Describe("deleteRequest", func() {
+		It("calls with the correct method and body", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(u))
+				Expect(req.Method).To(Equal("DELETE"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+
+				Expect(req.Body).To(BeNil())
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			resp, err := c.deleteRequest(u)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp).To(Equal(body))
+		})
+
+		It("fails if a successful response contains an error object", func() {
+			errBody := []byte(`{"message":"Room not found","errors":[{"description":"Room not found"}],"trackingId":"1"}`)
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(errBody)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			resp, err := c.deleteRequest(u)
+			Expect(err).To(MatchError(&APIError{
+				StatusCode: http.StatusOK,
+				Body:       errBody,
+				Method:     "DELETE",
+				Path:       u,
+				Message:    "Room not found",
+				Errors:     []ErrorDetail{{"Room not found"}},
+				TrackingID: "1",
+			}))
+			Expect(resp).To(BeNil())
+		})
+
+		It("allows successful responses with other JSON bodies", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"id":"1"}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			_, err := c.deleteRequest(u)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("handles a NewRequest() error properly", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				// This shouldn't be called in this test.  If it is, fail the test
+				Fail("unexpected call to http.Client.Do()")
+				return nil, nil
+			}
+
+			u2 := ":123" // invalid URL
+			resp, err := c.deleteRequest(u2)
+			Expect(err).To(MatchError(fmt.Sprintf("parse %s: missing protocol scheme", u2)))
+			Expect(resp).To(BeEmpty())
+		})
+	})
+
+	Describe("getRequestWithPaging", func() {
+		It("calls with the correct method and values", func() {
+			vals := map[string][]string{
+				"1": {"a"},
+				"2": {"b"},
+				"3": {"c"},
+			}
+			max := 50
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				uri := strings.Split(req.URL.String(), "?")[0]
+				Expect(uri).To(Equal(u))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+
+				Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", max)))
+				for k, v := range vals {
+					Expect(req.URL.Query().Get(k)).To(Equal(v[0]))
+				}
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			resp, err := c.getRequestWithPaging(u, url.Values(vals), max)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp).To(ConsistOf([][]byte{body}))
+		})
+
+		It("handles parameters that are already in the url", func() {
+			vals := map[string][]string{
+				"1": {"a"},
+				"2": {"b"},
+				"3": {"c"},
+			}
+			max := 50
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				uri := strings.Split(req.URL.String(), "?")[0]
+				Expect(uri).To(Equal(u))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				Expect(req.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+
+				Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", max)))
+				for k, v := range vals {
+					Expect(req.URL.Query().Get(k)).To(Equal(v[0]))
+				}
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			u2 := fmt.Sprintf("%s?1=a&2=b&3=c", u)
+
+			resp, err := c.getRequestWithPaging(u2, nil, max)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp).To(ConsistOf([][]byte{body}))
+		})
+
+		It("calls with the client max if it's smaller than the max argument", func() {
+			max := 50
+			expected := 10
+			c.pageMax = expected
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", expected)))
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			resp, err := c.getRequestWithPaging(u, nil, max)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp).To(ConsistOf([][]byte{body}))
+		})
+
+		It("pages", func() {
+			expectedCalls := 10
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", c.pageMax)))
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+				}
+				if calls++; calls < expectedCalls {
+					r.Header = map[string][]string{
+						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
+					}
+				}
+
+				return r, nil
+			}
+
+			resp, err := c.getRequestWithPaging(u, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+			slice := make([][]byte, expectedCalls)
+			for i := 0; i < expectedCalls; i++ {
+				slice[i] = body
+			}
+			Expect(resp).To(ConsistOf(slice))
+			Expect(calls).To(Equal(expectedCalls))
+		})
+
+		It("pages until max is hit", func() {
+			max := 50
+			clientmax := 10
+			c.pageMax = clientmax
+			expectedCalls := 5
+
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", clientmax)))
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
+					},
+				}
+
+				return r, nil
+			}
+
+			resp, err := c.getRequestWithPaging(u, nil, max)
+			Expect(err).ToNot(HaveOccurred())
+			slice := make([][]byte, expectedCalls)
+			for i := 0; i < expectedCalls; i++ {
+				slice[i] = body
+			}
+			Expect(resp).To(ConsistOf(slice))
+			Expect(calls).To(Equal(expectedCalls))
+		})
+
+		// The server always has more to give, so these only stop when max is reached
+		for _, tc := range []struct {
+			name    string
+			max     int
+			perPage []string
+		}{
+			{"1", 1, []string{"1"}},
+			{"the page size", 10, []string{"10"}},
+			{"one more than the page size", 11, []string{"10", "1"}},
+			{"twice the page size", 20, []string{"10", "10"}},
+		} {
+			tc := tc
+			It("asks for the right page sizes for a max of "+tc.name, func() {
+				c.pageMax = 10
+
+				var sent []string
+				mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+					sent = append(sent, req.URL.Query().Get("max"))
+					r := &http.Response{
+						Body:       closer(bytes.NewBuffer(body)),
+						StatusCode: http.StatusOK,
+						Header: map[string][]string{
+							"Link": {fmt.Sprintf("<%s?max=10>; rel=\"next\"", u)},
+						},
+					}
+					return r, nil
+				}
+
+				resp, err := c.getRequestWithPaging(u, nil, tc.max)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sent).To(Equal(tc.perPage))
+				Expect(resp).To(HaveLen(len(tc.perPage)))
+			})
+		}
+
+		// The server runs out after three pages, so these stop there unless max is reached first
+		for _, tc := range []struct {
+			name    string
+			max     int
+			perPage []string
+		}{
+			{"0", 0, []string{"10", "10", "10"}},
+			{"math.MaxInt32", math.MaxInt32, []string{"10", "10", "10"}},
+			{"less than 0", -1, nil},
+		} {
+			tc := tc
+			It("terminates for a max of "+tc.name, func() {
+				c.pageMax = 10
+
+				var sent []string
+				mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+					sent = append(sent, req.URL.Query().Get("max"))
+					r := &http.Response{
+						Body:       closer(bytes.NewBuffer(body)),
+						StatusCode: http.StatusOK,
+						Header:     http.Header{},
+					}
+					if len(sent) < 3 {
+						r.Header.Set("Link", fmt.Sprintf("<%s?max=10>; rel=\"next\"", u))
+					}
+					return r, nil
+				}
+
+				pages := 0
+				truncated, err := c.forEachPageWithCursor(u, nil, tc.max, func(page []byte) (bool, error) {
+					pages++
+					return true, nil
+				}, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sent).To(Equal(tc.perPage))
+				Expect(pages).To(Equal(len(tc.perPage)))
+				Expect(truncated).To(BeFalse())
+			})
+		}
+
+		It("doesn't report a negative max as truncated", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Fail("no request should be sent")
+				return nil, nil
+			}
+
+			messages, truncated, err := c.ListMessagesTruncated(-1, "room", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(messages).To(BeEmpty())
+			Expect(truncated).To(BeFalse())
+		})
+
+		It("counts down by the per-resource page size", func() {
+			c = c.SetMaxPerPage(50).SetMaxPerPageFor("rooms", 10).(*client)
+
+			var sent []string
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				sent = append(sent, req.URL.Query().Get("max"))
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", RoomsURL)},
+					},
+				}
+				return r, nil
+			}
+
+			_, err := c.getRequestWithPaging(RoomsURL, nil, 21)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sent).To(Equal([]string{"10", "10", "1"}))
+		})
+
+		It("pages with a non-integer multiple of client max", func() {
+			max := 55
+			clientmax := 10
+			c.pageMax = clientmax
+			expectedCalls := 6
+
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				if calls++; calls < expectedCalls {
+					Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", clientmax)))
+				} else {
+					Expect(req.URL.Query().Get("max")).To(Equal(fmt.Sprintf("%d", max%clientmax)))
+				}
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
+					},
+				}
+
+				return r, nil
+			}
+
+			resp, err := c.getRequestWithPaging(u, nil, max)
+			Expect(err).ToNot(HaveOccurred())
+			slice := make([][]byte, expectedCalls)
+			for i := 0; i < expectedCalls; i++ {
+				slice[i] = body
+			}
+			Expect(resp).To(ConsistOf(slice))
+			Expect(calls).To(Equal(expectedCalls))
+		})
+
+		It("calls Close() on the body", func() {
+			cls := closer(bytes.NewBuffer(body))
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       cls,
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			resp, err := c.getRequestWithPaging(u, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cls.closed).To(BeTrue())
+			Expect(resp).To(ConsistOf([][]byte{body}))
+		})
+
+		It("doesn't raise the max that the server's next link caps its pages at", func() {
+			max := 250
+			c.pageMax = 1000
+			serverMax := 100
+
+			var maxes []string
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				maxes = append(maxes, req.URL.Query().Get("max"))
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s?max=%d>; rel=\"next\"", u, serverMax)},
+					},
+				}
+				return r, nil
+			}
+
+			resp, err := c.getRequestWithPaging(u, nil, max)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp).To(HaveLen(3))
+			// 100, 100, then the remaining 50, since the first page was capped at 100 rather than the 250 asked for
+			Expect(maxes).To(Equal([]string{"250", "100", "50"}))
+		})
+
+		It("lowers the max in the server's next link, if it wants fewer", func() {
+			c.pageMax = 10
+
+			var maxes []string
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				maxes = append(maxes, req.URL.Query().Get("max"))
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s?max=100>; rel=\"next\"", u)},
+					},
+				}
+				return r, nil
+			}
+
+			_, err := c.getRequestWithPaging(u, nil, 25)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(maxes).To(Equal([]string{"10", "10", "5"}))
+		})
+
+		It("calls Close() on the body on each iteration", func() {
+			cls1 := closer(bytes.NewBuffer(body))
+			cls2 := closer(bytes.NewBuffer(body))
+
+			called := false
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var r *http.Response
+				if !called {
+					called = true
+					r = &http.Response{
+						Body:       cls1,
+						StatusCode: http.StatusOK,
+						Header: map[string][]string{
+							"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
+						},
+					}
+				} else {
+					r = &http.Response{
+						Body:       cls2,
+						StatusCode: http.StatusOK,
+					}
+				}
+				return r, nil
+			}
+
+			resp, err := c.getRequestWithPaging(u, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cls1.closed).To(BeTrue())
+			Expect(cls2.closed).To(BeTrue())
+			Expect(resp).To(ConsistOf([][]byte{body, body}))
+		})
+
+		It("doesn't close the body if Do() fails", func() {
+			cls := closer(bytes.NewBuffer(body))
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       cls,
+					StatusCode: http.StatusOK,
+				}
+				return r, mockErr
+			}
+
+			resp, err := c.getRequestWithPaging(u, nil, 0)
+			Expect(err).To(MatchError(mockErr))
+			Expect(cls.closed).To(BeFalse())
+			Expect(resp).To(BeEmpty())
+		})
+
+		It("handles a NewRequest() error properly", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				// This shouldn't be called in this test.  If it is, fail the test
+				Fail("unexpected call to http.Client.Do()")
+				return nil, nil
+			}
+
+			u2 := ":123" // invalid URL
+			resp, err := c.getRequestWithPaging(u2, nil, 0)
+			Expect(err).To(MatchError(fmt.Sprintf("parse %s: missing protocol scheme", u2)))
+			Expect(resp).To(BeEmpty())
+		})
+
+		It("handles a NewRequest() error properly after the first iteration", func() {
+			called := false
+			u2 := ":123" // invalid URL
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				if called {
+					// should only be called once, fail if called a second time
+					Fail("unexpected call to http.Client.Do()")
+				}
+				called = true
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u2)}, // invalid URL
+					},
+				}
+				return r, nil
+			}
+
+			resp, err := c.getRequestWithPaging(u, nil, 0)
+			Expect(err).To(MatchError(fmt.Sprintf("parse %s: missing protocol scheme", u2)))
+			Expect(resp).To(ConsistOf([][]byte{body}))
+		})
+
+		It("handles a body read error properly", func() {
+			cls := closer(&failReader{})
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       cls,
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			resp, err := c.getRequestWithPaging(u, nil, 0)
+			Expect(err).To(MatchError(mockErr))
+			Expect(cls.closed).To(BeTrue())
+			Expect(resp).To(BeEmpty())
+		})
+
+		It("handles a body read error properly after the first iteration", func() {
+			cls1 := closer(bytes.NewBuffer(body))
+			cls2 := closer(&failReader{})
+
+			called := false
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
 				var r *http.Response
 				if !called {
@@ -646,14 +1411,189 @@ var _ = Describe("API", func() {
 				return r, nil
 			}
 
-			resp, err := c.getRequestWithPaging(u, nil, 0)
+			resp, err := c.getRequestWithPaging(u, nil, 0)
+			Expect(err).To(MatchError(mockErr))
+			Expect(cls1.closed).To(BeTrue())
+			Expect(cls2.closed).To(BeTrue())
+			Expect(resp).To(ConsistOf([][]byte{body}))
+		})
+
+		It("handles an unexpected HTTP status code", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusInternalServerError,
+				}
+				return r, nil
+			}
+
+			resp, err := c.getRequestWithPaging(u, nil, 0)
+			Expect(err.Error()).To(ContainSubstring("HTTP Status 500"))
+			Expect(resp).To(BeEmpty())
+		})
+
+		It("handles an unexpected HTTP status code after the first iteration", func() {
+			cls1 := closer(bytes.NewBuffer(body))
+			cls2 := closer(bytes.NewBuffer(body))
+
+			called := false
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var r *http.Response
+				if !called {
+					called = true
+					r = &http.Response{
+						Body:       cls1,
+						StatusCode: http.StatusOK,
+						Header: map[string][]string{
+							"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
+						},
+					}
+				} else {
+					r = &http.Response{
+						Body:       cls2,
+						StatusCode: http.StatusInternalServerError,
+					}
+				}
+				return r, nil
+			}
+
+			resp, err := c.getRequestWithPaging(u, nil, 0)
+			Expect(err.Error()).To(ContainSubstring("HTTP Status 500"))
+			Expect(resp).To(ConsistOf([][]byte{body}))
+		})
+	})
+
+	Describe("forEachPage", func() {
+		It("hands each page to the callback as it's received", func() {
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(fmt.Sprintf("page %d", calls))),
+					StatusCode: http.StatusOK,
+				}
+				if calls < 3 {
+					r.Header = map[string][]string{
+						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
+					}
+				}
+				return r, nil
+			}
+
+			var pages []string
+			err := c.forEachPage(u, nil, 0, func(page []byte) (bool, error) {
+				Expect(calls).To(Equal(len(pages) + 1)) // the page arrives before the next one is requested
+				pages = append(pages, string(page))
+				return true, nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pages).To(Equal([]string{"page 1", "page 2", "page 3"}))
+		})
+
+		It("stops paging when the callback returns false", func() {
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
+					},
+				}
+				return r, nil
+			}
+
+			pages := 0
+			err := c.forEachPage(u, nil, 0, func(page []byte) (bool, error) {
+				pages++
+				return pages < 2, nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pages).To(Equal(2))
+			Expect(calls).To(Equal(2))
+		})
+
+		It("stops paging and returns the callback's error", func() {
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
+					},
+				}
+				return r, nil
+			}
+
+			err := c.forEachPage(u, nil, 0, func(page []byte) (bool, error) {
+				return true, mockErr
+			})
 			Expect(err).To(MatchError(mockErr))
-			Expect(cls1.closed).To(BeTrue())
-			Expect(cls2.closed).To(BeTrue())
-			Expect(resp).To(ConsistOf([][]byte{body}))
+			Expect(calls).To(Equal(1))
+		})
+	})
+
+	Describe("response observer", func() {
+		var resources []string
+		var headers []http.Header
+
+		BeforeEach(func() {
+			resources, headers = nil, nil
+			c = c.SetResponseObserver(func(resource string, h http.Header) {
+				resources = append(resources, resource)
+				headers = append(headers, h)
+			}).(*client)
 		})
 
-		It("handles an unexpected HTTP status code", func() {
+		It("receives the headers of successful responses", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Trackingid":            {"tracking 1"},
+						"X-Ratelimit-Remaining": {"42"},
+					},
+				}
+				return r, nil
+			}
+
+			_, err := c.getRequest(RoomsURL+"/1", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resources).To(Equal([]string{"rooms"}))
+			Expect(headers).To(HaveLen(1))
+			Expect(headers[0].Get("TrackingID")).To(Equal("tracking 1"))
+			Expect(headers[0].Get("X-RateLimit-Remaining")).To(Equal("42"))
+		})
+
+		It("receives the headers of every page", func() {
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Trackingid": {fmt.Sprintf("tracking %d", calls)},
+					},
+				}
+				if calls < 2 {
+					r.Header["Link"] = []string{fmt.Sprintf("<%s>; rel=\"next\"", MessagesURL)}
+				}
+				return r, nil
+			}
+
+			_, err := c.getRequestWithPaging(MessagesURL, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resources).To(Equal([]string{"messages", "messages"}))
+			Expect(headers).To(HaveLen(2))
+			Expect(headers[1].Get("TrackingID")).To(Equal("tracking 2"))
+		})
+
+		It("isn't called for failed responses", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
 				r := &http.Response{
 					Body:       closer(bytes.NewBuffer(body)),
@@ -662,39 +1602,489 @@ var _ = Describe("API", func() {
 				return r, nil
 			}
 
-			resp, err := c.getRequestWithPaging(u, nil, 0)
-			Expect(err.Error()).To(ContainSubstring("HTTP Status 500"))
-			Expect(resp).To(BeEmpty())
+			_, err := c.getRequest(RoomsURL, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(resources).To(BeEmpty())
 		})
 
-		It("handles an unexpected HTTP status code after the first iteration", func() {
-			cls1 := closer(bytes.NewBuffer(body))
-			cls2 := closer(bytes.NewBuffer(body))
+		It("doesn't affect the calling client", func() {
+			orig := New("mock").(*client)
+			Expect(orig.SetResponseObserver(func(string, http.Header) {}).(*client).observer).ToNot(BeNil())
+			Expect(orig.observer).To(BeNil())
+		})
+	})
+
+	Describe("metrics observer", func() {
+		type observation struct {
+			resource, method string
+			status           int
+			latency          time.Duration
+		}
+		var seen []observation
+		var fc *fakeClock
+
+		BeforeEach(func() {
+			seen = nil
+			fc = &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+			clk = fc
+			c = c.SetMetricsObserver(func(resource, method string, status int, latency time.Duration) {
+				seen = append(seen, observation{resource, method, status, latency})
+			}).(*client)
+		})
+
+		AfterEach(func() {
+			clk = realClock{}
+		})
+
+		It("sees the resource, method, status and latency of every request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				fc.now = fc.now.Add(250 * time.Millisecond)
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+				}
+				if req.Method == "DELETE" {
+					r.StatusCode = http.StatusNotFound
+				}
+				return r, nil
+			}
+
+			_, err := c.getRequest(RoomsURL+"/1", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = c.deleteRequest(MessagesURL + "/1")
+			Expect(err).To(HaveOccurred())
+
+			Expect(seen).To(Equal([]observation{
+				{"rooms", "GET", http.StatusOK, 250 * time.Millisecond},
+				{"messages", "DELETE", http.StatusNotFound, 250 * time.Millisecond},
+			}))
+		})
+
+		It("sees a status of 0 when no response is received", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				fc.now = fc.now.Add(time.Second)
+				return nil, mockErr
+			}
+
+			_, err := c.getRequest(PeopleURL+"/me", nil)
+			Expect(err).To(HaveOccurred())
+			Expect(seen).To(Equal([]observation{{"people", "GET", 0, time.Second}}))
+		})
+
+		It("doesn't affect the calling client", func() {
+			orig := New("mock").(*client)
+			Expect(orig.SetMetricsObserver(func(string, string, int, time.Duration) {}).(*client).metrics).ToNot(BeNil())
+			Expect(orig.metrics).To(BeNil())
+		})
+	})
+
+	Describe("DoJSON", func() {
+		It("gets an unmodeled endpoint", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.URL.String()).To(Equal(BaseURL + "/recordings?max=10"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				Expect(req.Body).To(BeNil())
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"items":[{"id":"1"}]}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			var out struct {
+				Items []struct{ ID string }
+			}
+			Expect(c.DoJSON("GET", "/recordings?max=10", nil, &out)).To(Succeed())
+			Expect(out.Items).To(HaveLen(1))
+			Expect(out.Items[0].ID).To(Equal("1"))
+		})
+
+		It("posts a JSON body to an unmodeled endpoint", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.Method).To(Equal("POST"))
+				Expect(req.URL.String()).To(Equal(BaseURL + "/meetings"))
+				Expect(req.Header.Get("Content-Type")).To(HavePrefix("application/json"))
+
+				b, err := ioutil.ReadAll(req.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b).To(MatchJSON(`{"title":"standup"}`))
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1","title":"standup"}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			var out map[string]string
+			Expect(c.DoJSON("POST", "meetings", map[string]string{"title": "standup"}, &out)).To(Succeed())
+			Expect(out).To(Equal(map[string]string{"id": "1", "title": "standup"}))
+		})
+
+		It("doesn't need an output", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{Body: closer(&bytes.Buffer{}), StatusCode: http.StatusNoContent}, nil
+			}
+			Expect(c.DoJSON("DELETE", "/meetings/1", nil, nil)).To(Succeed())
+		})
+
+		It("returns error statuses as an APIError", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"message":"nope"}`)), StatusCode: http.StatusNotFound}, nil
+			}
+			err := c.DoJSON("GET", "/nope", nil, nil)
+			Expect(IsNotFound(err)).To(BeTrue())
+		})
+
+		It("fails if the body can't be encoded", func() {
+			Expect(c.DoJSON("POST", "/meetings", make(chan int), nil)).To(HaveOccurred())
+		})
+	})
+
+	Describe("http client", func() {
+		It("sends requests with the client set by SetHTTPClient", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Fail("request was sent with the default client")
+				return nil, nil
+			}
+
+			var sent *http.Request
+			cli := &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+				sent = req
+				return &http.Response{Body: closer(bytes.NewBuffer(body)), StatusCode: http.StatusOK}, nil
+			})}
+
+			resp, err := c.SetHTTPClient(cli).(*client).getRequest(RoomsURL, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp).To(Equal(body))
+			Expect(sent.Header.Get("Authorization")).To(Equal("Bearer mock"))
+		})
 
+		It("restores the default client when given nil", func() {
 			called := false
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				var r *http.Response
-				if !called {
-					called = true
-					r = &http.Response{
-						Body:       cls1,
+				called = true
+				return &http.Response{Body: closer(bytes.NewBuffer(body)), StatusCode: http.StatusOK}, nil
+			}
+
+			_, err := c.SetHTTPClient(new(http.Client)).SetHTTPClient(nil).(*client).getRequest(RoomsURL, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(called).To(BeTrue())
+		})
+
+		It("connects with the TLS config set by SetTLSConfig", func() {
+			srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(body)
+			}))
+			defer srv.Close()
+			httpCli = http.DefaultClient // the mock would never check the server's certificate
+			defer func() { httpCli = mockCli }()
+
+			_, err := c.getRequest(srv.URL, nil)
+			Expect(err).To(HaveOccurred()) // the test server's CA isn't trusted by default
+
+			roots := x509.NewCertPool()
+			roots.AddCert(srv.Certificate())
+			cfg := &tls.Config{RootCAs: roots}
+			tc := c.SetTLSConfig(cfg).(*client)
+			Expect(tc.httpCli.(*http.Client).Transport.(*http.Transport).TLSClientConfig).To(BeIdenticalTo(cfg))
+
+			resp, err := tc.getRequest(srv.URL, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp).To(Equal(body))
+		})
+
+		It("uses whichever of SetTLSConfig and SetHTTPClient was called last", func() {
+			cli := new(http.Client)
+			Expect(c.SetTLSConfig(new(tls.Config)).SetHTTPClient(cli).(*client).httpCli).To(BeIdenticalTo(cli))
+			Expect(c.SetHTTPClient(cli).SetTLSConfig(new(tls.Config)).(*client).httpCli).ToNot(BeIdenticalTo(cli))
+			Expect(c.SetTLSConfig(new(tls.Config)).SetTLSConfig(nil).(*client).httpCli).To(BeNil())
+		})
+
+		It("sizes the connection pool set by SetConnectionPool", func() {
+			pc := c.SetConnectionPool(100, 20).(*client)
+			t := pc.httpCli.(*http.Client).Transport.(*http.Transport)
+			Expect(t.MaxIdleConns).To(Equal(100))
+			Expect(t.MaxIdleConnsPerHost).To(Equal(20))
+			Expect(t).ToNot(BeIdenticalTo(http.DefaultTransport))
+			Expect(http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost).To(BeZero()) // untouched
+
+			cli := new(http.Client)
+			Expect(c.SetConnectionPool(100, 20).SetHTTPClient(cli).(*client).httpCli).To(BeIdenticalTo(cli))
+		})
+
+		It("returns redirects instead of following them, when SetFollowRedirects disables it", func() {
+			var sent []string
+			cli := &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+				sent = append(sent, req.URL.Path)
+				if req.URL.Path == "/login" {
+					return &http.Response{
+						Body:       closer(bytes.NewBufferString("<html>log in</html>")),
 						StatusCode: http.StatusOK,
-						Header: map[string][]string{
-							"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
-						},
-					}
-				} else {
-					r = &http.Response{
-						Body:       cls2,
-						StatusCode: http.StatusInternalServerError,
+						Header:     http.Header{"Content-Type": {"text/html"}},
+					}, nil
+				}
+				return &http.Response{
+					Body:       closer(bytes.NewBufferString("")),
+					StatusCode: http.StatusFound,
+					Header:     http.Header{"Location": {"https://proxy.corp.com/login"}},
+				}, nil
+			})}
+
+			_, err := c.SetHTTPClient(cli).(*client).getRequest(RoomsURL, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(sent).To(Equal([]string{"/v1/rooms", "/login"}))
+
+			sent = nil
+			_, err = c.SetHTTPClient(cli).SetFollowRedirects(false).(*client).getRequest(RoomsURL, nil)
+			Expect(err).To(MatchError(&APIError{StatusCode: http.StatusFound, Body: []byte{}, Method: "GET", Path: "/v1/rooms"}))
+			Expect(sent).To(Equal([]string{"/v1/rooms"}))
+			Expect(cli.CheckRedirect).To(BeNil()) // the caller's client is left alone
+
+			sent = nil
+			_, err = c.SetFollowRedirects(false).SetFollowRedirects(true).SetHTTPClient(cli).(*client).getRequest(RoomsURL, nil)
+			Expect(err).ToNot(MatchError(ContainSubstring("302")))
+			Expect(sent).To(HaveLen(2))
+		})
+
+		It("leaves clients other than *http.Client alone when redirects are disabled", func() {
+			Expect(c.SetFollowRedirects(false).(*client).http()).To(BeIdenticalTo(mockCli))
+		})
+
+		It("can be closed more than once", func() {
+			httpCli = new(http.Client)
+			defer func() { httpCli = mockCli }()
+
+			Expect(c.Close()).To(Succeed())
+			Expect(c.Close()).To(Succeed())
+			Expect(c.SetHTTPClient(&http.Client{Transport: new(http.Transport)}).Close()).To(Succeed())
+		})
+
+		It("only closes an http client that was given to it, not the shared default", func() {
+			shared := new(idleCloser)
+			httpCli = &http.Client{Transport: shared}
+			defer func() { httpCli = mockCli }()
+
+			Expect(c.Close()).To(Succeed())
+			Expect(shared.closed).To(BeZero())
+
+			own := new(idleCloser)
+			Expect(c.SetHTTPClient(&http.Client{Transport: own}).Close()).To(Succeed())
+			Expect(own.closed).To(Equal(1))
+			Expect(shared.closed).To(BeZero())
+		})
+	})
+
+	Describe("parseLinkHeader", func() {
+		It("parses all four relations from one header", func() {
+			h := http.Header{"Link": {
+				`<https://x.com/rooms?cursor=1>; rel="first", <https://x.com/rooms?cursor=2>; rel="prev", ` +
+					`<https://x.com/rooms?cursor=4>; rel="next", <https://x.com/rooms?cursor=9>; rel="last"`,
+			}}
+			Expect(parseLinkHeader(h)).To(Equal(Links{
+				First: "https://x.com/rooms?cursor=1",
+				Prev:  "https://x.com/rooms?cursor=2",
+				Next:  "https://x.com/rooms?cursor=4",
+				Last:  "https://x.com/rooms?cursor=9",
+			}))
+		})
+
+		It("parses relations from repeated headers", func() {
+			h := http.Header{"Link": {
+				`<https://x.com/rooms?cursor=4>; rel="next"`,
+				`<https://x.com/rooms?cursor=9>; rel="last"`,
+			}}
+			Expect(parseLinkHeader(h)).To(Equal(Links{
+				Next: "https://x.com/rooms?cursor=4",
+				Last: "https://x.com/rooms?cursor=9",
+			}))
+		})
+
+		It("handles links with several relations, other parameters, and commas in the URL", func() {
+			h := http.Header{"Link": {
+				`<https://x.com/rooms?ids=a,b>; title="start"; rel="first prev", <https://x.com/other>; rel="help"`,
+			}}
+			Expect(parseLinkHeader(h)).To(Equal(Links{
+				First: "https://x.com/rooms?ids=a,b",
+				Prev:  "https://x.com/rooms?ids=a,b",
+			}))
+		})
+
+		It("returns empty links if there is no header, or it can't be parsed", func() {
+			Expect(parseLinkHeader(http.Header{})).To(Equal(Links{}))
+			Expect(parseLinkHeader(http.Header{"Link": {"garbage"}})).To(Equal(Links{}))
+		})
+	})
+
+	Describe("debug writer", func() {
+		It("logs requests and responses without the token", func() {
+			c = New("super secret token").(*client)
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					Status:     "200 OK",
+					StatusCode: http.StatusOK,
+				}
+				if req.URL.Query().Get("page") == "" {
+					r.Header = map[string][]string{
+						"Link": {fmt.Sprintf("<%s?page=2>; rel=\"next\"", RoomsURL)},
 					}
 				}
 				return r, nil
 			}
 
+			var out bytes.Buffer
+			_, err := c.SetDebugWriter(&out).(*client).getRequestWithPaging(RoomsURL, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			log := out.String()
+			Expect(log).To(ContainSubstring("> GET " + RoomsURL + "?max=50\n"))
+			Expect(log).To(ContainSubstring("> GET " + RoomsURL + "?max=50&page=2\n"))
+			Expect(log).To(ContainSubstring("> Authorization: [REDACTED]\n"))
+			Expect(log).To(ContainSubstring("> Content-Type: application/json; charset=utf-8\n"))
+			Expect(strings.Count(log, fmt.Sprintf("< 200 OK (%d bytes)\n", len(body)))).To(Equal(2))
+			Expect(log).ToNot(ContainSubstring("secret"))
+		})
+
+		It("logs failed requests", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+
+			var out bytes.Buffer
+			_, err := c.SetDebugWriter(&out).(*client).getRequest(RoomsURL, nil)
+			Expect(err).To(MatchError(mockErr))
+			Expect(out.String()).To(HaveSuffix("< error: mock error\n"))
+		})
+
+		It("logs nothing by default", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{Body: closer(bytes.NewBuffer(body)), StatusCode: http.StatusOK}, nil
+			}
+
+			var out bytes.Buffer
+			_, err := c.SetDebugWriter(&out).SetDebugWriter(nil).(*client).getRequest(RoomsURL, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out.Len()).To(BeZero())
+		})
+	})
+
+	Describe("per-resource page max", func() {
+		var maxes map[string]string
+
+		BeforeEach(func() {
+			maxes = make(map[string]string)
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				maxes[resourceName(req.URL)] = req.URL.Query().Get("max")
+				return &http.Response{Body: closer(bytes.NewBuffer(body)), StatusCode: http.StatusOK}, nil
+			}
+		})
+
+		It("uses the per-resource max for that resource, and the global max for others", func() {
+			c = c.SetMaxPerPage(25).SetMaxPerPageFor("messages", 1000).(*client)
+
+			_, err := c.getRequestWithPaging(MessagesURL, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = c.getRequestWithPaging(RoomsURL, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(maxes).To(Equal(map[string]string{"messages": "1000", "rooms": "25"}))
+		})
+
+		It("removes the override when set to 0", func() {
+			c = c.SetMaxPerPageFor("messages", 1000).SetMaxPerPageFor("messages", 0).(*client)
+
+			_, err := c.getRequestWithPaging(MessagesURL, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(maxes["messages"]).To(Equal("50"))
+		})
+
+		It("doesn't affect the calling client", func() {
+			orig := c.SetMaxPerPageFor("rooms", 10).(*client)
+			orig.SetMaxPerPageFor("rooms", 20).SetMaxPerPageFor("messages", 30)
+			Expect(orig.maxPerPage("rooms")).To(Equal(10))
+			Expect(orig.maxPerPage("messages")).To(Equal(50))
+		})
+	})
+
+	Describe("page limit", func() {
+		var calls int
+
+		BeforeEach(func() {
+			calls = 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				r := &http.Response{
+					Body:       closer(bytes.NewBuffer(body)),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", u)},
+					},
+				}
+				return r, nil
+			}
+		})
+
+		It("stops following next links at the cap, and returns what it has", func() {
+			resp, err := c.SetMaxPages(5).(*client).getRequestWithPaging(u, nil, 0)
+			Expect(err).To(MatchError(ErrPageLimitExceeded))
+			Expect(resp).To(HaveLen(5))
+			Expect(calls).To(Equal(5))
+		})
+
+		It("defaults to a generous cap", func() {
 			resp, err := c.getRequestWithPaging(u, nil, 0)
-			Expect(err.Error()).To(ContainSubstring("HTTP Status 500"))
-			Expect(resp).To(ConsistOf([][]byte{body}))
+			Expect(err).To(MatchError(ErrPageLimitExceeded))
+			Expect(resp).To(HaveLen(defaultMaxPages))
+		})
+
+		It("doesn't fail if the query ends exactly at the cap", func() {
+			resp, err := c.SetMaxPages(2).(*client).getRequestWithPaging(u, nil, 2*c.pageMax)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp).To(HaveLen(2))
 		})
 	})
 })
+
+// Compares concurrent requests with the default connection pool, which only keeps 2 idle connections per host, against
+// a pool large enough for every goroutine.  Run with: go test -run '^$' -bench ConnectionPool
+// GetMyself is cached after its first request, so this uses Ping, which sends the same request every time.
+func BenchmarkConnectionPool(b *testing.B) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"me"}`))
+	}))
+	defer srv.Close()
+
+	for _, bc := range []struct {
+		name           string
+		maxIdlePerHost int
+	}{
+		{"default", http.DefaultMaxIdleConnsPerHost},
+		{"pooled", 64},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			c := New("mock").SetConnectionPool(100, bc.maxIdlePerHost).(*client)
+			defer c.Close()
+
+			// Send the requests for Spark to the test server instead
+			t := c.httpCli.(*http.Client).Transport.(*http.Transport)
+			t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+			t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return new(net.Dialer).DialContext(ctx, network, srv.Listener.Addr().String())
+			}
+
+			b.SetParallelism(16)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := c.Ping(context.Background()); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+// A transport that counts how many times its idle connections were closed, and never sends anything
+type idleCloser struct {
+	closed int
+}
+
+func (ic *idleCloser) RoundTrip(*http.Request) (*http.Response, error) { return nil, mockErr }
+func (ic *idleCloser) CloseIdleConnections()                           { ic.closed++ }
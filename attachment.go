@@ -0,0 +1,64 @@
+package spark
+
+import "fmt"
+
+const AttachmentActionsURL = BaseURL + "/attachment/actions"
+
+// AttachmentAction is a person's submission of an Adaptive Card that was attached to a message.  Inputs holds the
+// values of the card's input fields, keyed by their IDs.
+type AttachmentAction struct {
+	ID        string                 `json:"id,omitempty"`
+	Type      string                 `json:"type,omitempty"`
+	MessageID string                 `json:"messageId,omitempty"`
+	Inputs    map[string]interface{} `json:"inputs,omitempty"`
+	PersonID  string                 `json:"personId,omitempty"`
+	RoomID    string                 `json:"roomId,omitempty"`
+	Created   Time                   `json:"created,omitempty"`
+}
+
+// https://developer.webex.com/docs/api/v1/attachment-actions/get-attachment-action-details
+func (c *client) GetAttachmentAction(actionID string) (*AttachmentAction, error) {
+	if actionID == "" {
+		return nil, fmt.Errorf("no attachment action ID specified")
+	}
+
+	resp, err := c.getRequest(fmt.Sprintf("%s/%s", AttachmentActionsURL, actionID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var a AttachmentAction
+	err = c.unmarshal(resp, &a)
+	return &a, err
+}
+
+// HandleCardSubmit is a helper method for bots with interactive cards.  Given the event of an attachmentActions
+// webhook, ex. from ParseWebhookEvent, it fetches the full attachment action, including the inputs that were submitted
+// (which Spark leaves out of the event).  It fails if the event isn't for a created attachment action.
+func (c *client) HandleCardSubmit(event *WebhookEvent) (*AttachmentAction, error) {
+	id, err := cardSubmitID(event)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetAttachmentAction(id)
+}
+
+// Returns the ID of the attachment action that a card submission event is for.
+func cardSubmitID(event *WebhookEvent) (string, error) {
+	if event == nil {
+		return "", fmt.Errorf("nil webhook event")
+	}
+	if event.Resource != "attachmentActions" || event.Event != "created" {
+		return "", fmt.Errorf("webhook event is for %s %s, not a card submission (attachmentActions created)",
+			event.Resource, event.Event)
+	}
+
+	var data AttachmentAction
+	if err := event.DecodeData(&data); err != nil {
+		return "", err
+	}
+	if data.ID == "" {
+		return "", fmt.Errorf("webhook event data has no attachment action ID")
+	}
+	return data.ID, nil
+}
@@ -0,0 +1,136 @@
+package spark
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AttachmentAction (Mock)", func() {
+	var c Client
+	var mockCli *mockHTTPClient
+
+	var action *AttachmentAction
+
+	BeforeEach(func() {
+		c = New("mock")
+		mockCli = new(mockHTTPClient)
+		httpCli = mockCli // set client global to a mock
+
+		action = &AttachmentAction{
+			ID:        "action",
+			Type:      "submit",
+			MessageID: "message",
+			Inputs:    map[string]interface{}{"choice": "yes", "comment": "looks good"},
+			PersonID:  "person",
+			RoomID:    "room",
+			Created:   Time{time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)},
+		}
+	})
+
+	respondWith := func(a *AttachmentAction) {
+		mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+			Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/%s", AttachmentActionsURL, a.ID)))
+			Expect(req.Method).To(Equal("GET"))
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+			var b bytes.Buffer
+			Expect(json.NewEncoder(&b).Encode(a)).To(Succeed())
+			return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+		}
+	}
+
+	Describe("GetAttachmentAction", func() {
+		It("gets an attachment action by ID", func() {
+			respondWith(action)
+			Expect(c.GetAttachmentAction(action.ID)).To(Equal(action))
+		})
+
+		It("fails if no attachment action ID is specified", func() {
+			a, err := c.GetAttachmentAction("")
+			Expect(err).To(MatchError("no attachment action ID specified"))
+			Expect(a).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			a, err := c.GetAttachmentAction("action")
+			Expect(err).To(MatchError(mockErr))
+			Expect(a).To(BeNil())
+		})
+	})
+
+	Describe("HandleCardSubmit", func() {
+		// What Spark POSTs to the webhook: the data has everything but the inputs.
+		body := []byte(`{
+			"id": "hook",
+			"name": "cards",
+			"targetUrl": "https://example.com/hook",
+			"resource": "attachmentActions",
+			"event": "created",
+			"actorId": "person",
+			"data": {
+				"id": "action",
+				"type": "submit",
+				"messageId": "message",
+				"personId": "person",
+				"roomId": "room",
+				"created": "2019-01-02T03:04:05.000Z"
+			}
+		}`)
+
+		It("fetches the submitted action for a card submission webhook", func() {
+			e, err := ParseWebhookEvent(body)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var partial AttachmentAction
+			Expect(e.DecodeData(&partial)).To(Succeed())
+			Expect(partial.ID).To(Equal(action.ID))
+			Expect(partial.Inputs).To(BeNil())
+
+			respondWith(action)
+			a, err := c.HandleCardSubmit(e)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(a).To(Equal(action))
+			Expect(a.Inputs["choice"]).To(Equal("yes"))
+		})
+
+		It("fails for events that aren't card submissions", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Fail("unexpected request")
+				return nil, nil
+			}
+
+			_, err := c.HandleCardSubmit(nil)
+			Expect(err).To(MatchError("nil webhook event"))
+
+			_, err = c.HandleCardSubmit(&WebhookEvent{Resource: "messages", Event: "created", Data: json.RawMessage(`{"id":"1"}`)})
+			Expect(err).To(MatchError("webhook event is for messages created, not a card submission (attachmentActions created)"))
+
+			_, err = c.HandleCardSubmit(&WebhookEvent{Resource: "attachmentActions", Event: "deleted", Data: json.RawMessage(`{"id":"1"}`)})
+			Expect(err).Should(HaveOccurred())
+
+			_, err = c.HandleCardSubmit(&WebhookEvent{Resource: "attachmentActions", Event: "created", Data: json.RawMessage(`{}`)})
+			Expect(err).To(MatchError("webhook event data has no attachment action ID"))
+		})
+
+		It("passes through errors encountered fetching the action", func() {
+			e, err := ParseWebhookEvent(body)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			a, err := c.HandleCardSubmit(e)
+			Expect(err).To(MatchError(mockErr))
+			Expect(a).To(BeNil())
+		})
+	})
+})
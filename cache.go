@@ -0,0 +1,93 @@
+package spark
+
+import (
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Caches the bodies of successful GET responses, keyed by their full URL, for ttl after they're received.  Once it
+// holds max entries, the least recently used is evicted to make room.  Expired entries are dropped as they're found.
+type getCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	order   *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	body    []byte
+	expires time.Time
+}
+
+func newGetCache(ttl time.Duration, max int) *getCache {
+	return &getCache{ttl: ttl, max: max, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Returns a copy of the cached body for key, if there's one that hasn't expired.
+func (g *getCache) get(key string) ([]byte, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	el, ok := g.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if !clk.Now().Before(e.expires) {
+		g.order.Remove(el)
+		delete(g.entries, key)
+		return nil, false
+	}
+	g.order.MoveToFront(el)
+	return append([]byte(nil), e.body...), true
+}
+
+// Caches a copy of body for key, unless the response it came from asked not to be stored.
+func (g *getCache) add(key string, res *http.Response, body []byte) {
+	if noStore(res.Header) {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	e := &cacheEntry{key: key, body: append([]byte(nil), body...), expires: clk.Now().Add(g.ttl)}
+	if el, ok := g.entries[key]; ok {
+		el.Value = e
+		g.order.MoveToFront(el)
+		return
+	}
+	g.entries[key] = g.order.PushFront(e)
+	for g.order.Len() > g.max {
+		oldest := g.order.Back()
+		g.order.Remove(oldest)
+		delete(g.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Drops the cached body for key, if there is one.
+func (g *getCache) remove(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if el, ok := g.entries[key]; ok {
+		g.order.Remove(el)
+		delete(g.entries, key)
+	}
+}
+
+// Reports whether a response's Cache-Control header includes the no-store directive.
+func noStore(h http.Header) bool {
+	for _, v := range h["Cache-Control"] {
+		for _, directive := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package spark
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GET cache", func() {
+	var c Client
+	var mockCli *mockHTTPClient
+	var fc *fakeClock
+	var calls []string
+
+	BeforeEach(func() {
+		mockCli = new(mockHTTPClient)
+		httpCli = mockCli
+		fc = &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+		clk = fc
+		calls = nil
+
+		mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+			calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+			return &http.Response{
+				Body:       closer(bytes.NewBufferString(fmt.Sprintf(`{"id":"%d"}`, len(calls)))),
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+			}, nil
+		}
+		c = New("mock").SetGETCache(time.Minute, 2)
+	})
+
+	AfterEach(func() {
+		clk = realClock{}
+	})
+
+	It("serves repeated requests from the cache until they expire", func() {
+		Expect(c.GetRoom("1")).To(Equal(&Room{ID: "1"}))
+		Expect(c.GetRoom("1")).To(Equal(&Room{ID: "1"}))
+		Expect(calls).To(HaveLen(1))
+
+		fc.now = fc.now.Add(time.Minute)
+		Expect(c.GetRoom("1")).To(Equal(&Room{ID: "2"}))
+		Expect(calls).To(HaveLen(2))
+	})
+
+	It("evicts the least recently used response", func() {
+		_, _ = c.GetRoom("1")
+		_, _ = c.GetRoom("2")
+		_, _ = c.GetRoom("1") // 2 is now the least recently used
+		_, _ = c.GetRoom("3")
+		Expect(calls).To(Equal([]string{"GET /v1/rooms/1", "GET /v1/rooms/2", "GET /v1/rooms/3"}))
+
+		_, _ = c.GetRoom("1")
+		_, _ = c.GetRoom("2")
+		Expect(calls).To(Equal([]string{"GET /v1/rooms/1", "GET /v1/rooms/2", "GET /v1/rooms/3", "GET /v1/rooms/2"}))
+	})
+
+	It("doesn't cache responses marked no-store", func() {
+		mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+			calls = append(calls, req.URL.Path)
+			return &http.Response{
+				Body:       closer(bytes.NewBufferString(`{"id":"1"}`)),
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Cache-Control": {"private, No-Store"}},
+			}, nil
+		}
+
+		_, _ = c.GetPerson("1")
+		_, _ = c.GetPerson("1")
+		Expect(calls).To(HaveLen(2))
+	})
+
+	It("drops a resource's cached response when it's written to", func() {
+		_, _ = c.GetRoom("1")
+		Expect(c.DeleteRoom("1")).To(Succeed())
+		_, _ = c.GetRoom("1")
+		Expect(calls).To(Equal([]string{"GET /v1/rooms/1", "DELETE /v1/rooms/1", "GET /v1/rooms/1"}))
+	})
+
+	It("never caches the authenticated person, which updates to their ID wouldn't drop", func() {
+		Expect(c.GetMyself()).To(Equal(&Person{ID: "1"}))
+		_, err := c.UpdatePerson(&Person{ID: "1", DisplayName: "new"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.GetMyself()).To(Equal(&Person{ID: "3"}))
+		Expect(calls).To(Equal([]string{"GET /v1/people/me", "PUT /v1/people/1", "GET /v1/people/me"}))
+	})
+
+	It("never caches lists", func() {
+		_, _ = c.ListRooms(0, nil)
+		_, _ = c.ListRooms(0, nil)
+		Expect(calls).To(HaveLen(2))
+	})
+
+	It("doesn't share cached bodies with callers", func() {
+		raw, err := c.GetPersonRaw("1")
+		Expect(err).ToNot(HaveOccurred())
+		raw[0] = 'x'
+		Expect(c.GetPersonRaw("1")).To(MatchJSON(`{"id":"1"}`))
+	})
+
+	It("is shared by copies of the client, and disabled by a ttl or size of 0", func() {
+		_, _ = c.GetRoom("1")
+		_, _ = c.SetMaxRetries(1).GetRoom("1")
+		Expect(calls).To(HaveLen(1))
+
+		_, _ = c.SetGETCache(0, 2).GetRoom("1")
+		_, _ = c.SetGETCache(time.Minute, 0).GetRoom("1")
+		Expect(calls).To(HaveLen(3))
+	})
+})
@@ -0,0 +1,16 @@
+package spark
+
+import "time"
+
+// Abstracts the passage of time, so that retry and rate limiting logic can be tested without actually sleeping.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+var clk = clock(realClock{})
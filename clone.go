@@ -0,0 +1,86 @@
+package spark
+
+// The Clone methods return deep copies of resources, for callers that want to modify a resource (ex. for an optimistic
+// update) without affecting the original.  Slices and maps are copied too, so nothing is shared between the copy and
+// the original.  Cloning a nil resource returns nil.
+
+// Clone returns a deep copy of the person.
+func (p *Person) Clone() *Person {
+	if p == nil {
+		return nil
+	}
+	cp := *p
+	cp.Emails = cloneStrings(p.Emails)
+	cp.Roles = cloneStrings(p.Roles)
+	cp.Licenses = cloneStrings(p.Licenses)
+	return &cp
+}
+
+// Clone returns a deep copy of the room.
+func (r *Room) Clone() *Room {
+	if r == nil {
+		return nil
+	}
+	cp := *r
+	return &cp
+}
+
+// Clone returns a deep copy of the message.
+func (m *Message) Clone() *Message {
+	if m == nil {
+		return nil
+	}
+	cp := *m
+	cp.Files = cloneStrings(m.Files)
+	return &cp
+}
+
+// Clone returns a deep copy of the membership.
+func (m *Membership) Clone() *Membership {
+	if m == nil {
+		return nil
+	}
+	cp := *m
+	return &cp
+}
+
+// Clone returns a deep copy of the webhook, including any nested maps and slices in its Data.
+func (w *Webhook) Clone() *Webhook {
+	if w == nil {
+		return nil
+	}
+	cp := *w
+	if w.Data != nil {
+		cp.Data = cloneValue(w.Data).(map[string]interface{})
+	}
+	return &cp
+}
+
+// Copies a string slice, keeping nil slices nil.
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(s)), s...)
+}
+
+// Deep copies the kinds of values that encoding/json decodes into an interface{}.  Everything other than maps and
+// slices is immutable, so it's returned as is.
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		cp := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			cp[k] = cloneValue(e)
+		}
+		return cp
+	case []interface{}:
+		cp := make([]interface{}, len(v))
+		for i, e := range v {
+			cp[i] = cloneValue(e)
+		}
+		return cp
+	default:
+		return v
+	}
+}
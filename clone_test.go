@@ -0,0 +1,83 @@
+package spark
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Clone", func() {
+	It("copies a person without sharing its slices", func() {
+		p := &Person{
+			ID:       "1",
+			Emails:   []string{"a@world.com", "b@world.com"},
+			Roles:    []string{"role"},
+			Licenses: []string{"license"},
+			Created:  Time{time.Now()},
+		}
+		cp := p.Clone()
+		Expect(cp).To(Equal(p))
+		Expect(cp).ToNot(BeIdenticalTo(p))
+
+		cp.ID = "2"
+		cp.Emails[0] = "changed@world.com"
+		cp.Roles = append(cp.Roles, "another")
+		cp.Licenses[0] = "changed"
+		Expect(p.ID).To(Equal("1"))
+		Expect(p.Emails).To(Equal([]string{"a@world.com", "b@world.com"}))
+		Expect(p.Roles).To(Equal([]string{"role"}))
+		Expect(p.Licenses).To(Equal([]string{"license"}))
+	})
+
+	It("copies a message without sharing its files", func() {
+		m := &Message{ID: "1", Files: []string{"file"}}
+		cp := m.Clone()
+		Expect(cp).To(Equal(m))
+
+		cp.Files[0] = "changed"
+		Expect(m.Files).To(Equal([]string{"file"}))
+	})
+
+	It("copies a webhook without sharing its data", func() {
+		w := &Webhook{ID: "1", Data: map[string]interface{}{
+			"nested": map[string]interface{}{"key": "value"},
+			"list":   []interface{}{"a", map[string]interface{}{"key": "value"}},
+		}}
+		cp := w.Clone()
+		Expect(cp).To(Equal(w))
+
+		cp.Data["nested"].(map[string]interface{})["key"] = "changed"
+		cp.Data["list"].([]interface{})[1].(map[string]interface{})["key"] = "changed"
+		cp.Data["new"] = true
+		Expect(w.Data).To(Equal(map[string]interface{}{
+			"nested": map[string]interface{}{"key": "value"},
+			"list":   []interface{}{"a", map[string]interface{}{"key": "value"}},
+		}))
+	})
+
+	It("copies rooms and memberships", func() {
+		r := &Room{ID: "1", Title: "room"}
+		cp := r.Clone()
+		Expect(cp).To(Equal(r))
+		cp.Title = "changed"
+		Expect(r.Title).To(Equal("room"))
+
+		m := &Membership{ID: "1", IsModerator: true}
+		mcp := m.Clone()
+		Expect(mcp).To(Equal(m))
+		mcp.IsModerator = false
+		Expect(m.IsModerator).To(BeTrue())
+	})
+
+	It("preserves nil resources and slices", func() {
+		Expect((*Person)(nil).Clone()).To(BeNil())
+		Expect((*Room)(nil).Clone()).To(BeNil())
+		Expect((*Message)(nil).Clone()).To(BeNil())
+		Expect((*Membership)(nil).Clone()).To(BeNil())
+		Expect((*Webhook)(nil).Clone()).To(BeNil())
+
+		Expect((&Person{ID: "1"}).Clone().Emails).To(BeNil())
+		Expect((&Message{ID: "1", Files: []string{}}).Clone().Files).ToNot(BeNil())
+	})
+})
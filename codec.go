@@ -0,0 +1,38 @@
+package spark
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Codec encodes request bodies and decodes response bodies, for callers that want a faster JSON implementation than
+// encoding/json (ex. jsoniter) for bulk work.  It must be compatible with encoding/json: the package's types rely on
+// their json struct tags and their MarshalJSON and UnmarshalJSON methods being honored.  See SetCodec.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// StdCodec is the Codec that clients use by default, which is encoding/json.
+type StdCodec struct{}
+
+func (StdCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (StdCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// Returns the codec that the client encodes and decodes bodies with.
+func (c *client) jsonCodec() Codec {
+	if c.codec != nil {
+		return c.codec
+	}
+	return StdCodec{}
+}
+
+// Encodes a request body with the client's codec.  The body is a *bytes.Buffer, so requests sent with it have a content
+// length, and can be rewound for retries.
+func (c *client) encode(v interface{}) (*bytes.Buffer, error) {
+	b, err := c.jsonCodec().Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(b), nil
+}
@@ -0,0 +1,129 @@
+package spark
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Counts what it encodes and decodes, and otherwise works like encoding/json
+type countingCodec struct {
+	marshaled, unmarshaled int
+}
+
+func (cc *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	cc.marshaled++
+	return json.Marshal(v)
+}
+
+func (cc *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	cc.unmarshaled++
+	return json.Unmarshal(data, v)
+}
+
+var _ = Describe("Codec", func() {
+	var c Client
+	var mockCli *mockHTTPClient
+	var codec *countingCodec
+
+	BeforeEach(func() {
+		mockCli = new(mockHTTPClient)
+		httpCli = mockCli
+		codec = new(countingCodec)
+		c = New("mock").SetCodec(codec)
+	})
+
+	It("encodes request bodies and decodes responses with the codec", func() {
+		mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+			b, err := ioutil.ReadAll(req.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(MatchJSON(`{"title":"room"}`))
+			return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1","title":"room"}`)), StatusCode: http.StatusOK}, nil
+		}
+
+		Expect(c.CreateRoom("room", "")).To(Equal(&Room{ID: "1", Title: "room"}))
+		Expect(codec.marshaled).To(Equal(1))
+		Expect(codec.unmarshaled).To(Equal(1))
+	})
+
+	It("decodes every page of a listing with the codec", func() {
+		calls := 0
+		mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+			calls++
+			r := &http.Response{
+				Body:       closer(bytes.NewBufferString(fmt.Sprintf(`{"items":[{"id":"%d"}]}`, calls))),
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+			}
+			if calls < 3 {
+				r.Header.Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", RoomsURL))
+			}
+			return r, nil
+		}
+
+		rooms, err := c.ListRooms(0, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rooms).To(HaveLen(3))
+		Expect(codec.unmarshaled).To(Equal(3))
+	})
+
+	It("isn't used for strict decoding, and is restored to the default by nil", func() {
+		mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1"}`)), StatusCode: http.StatusOK}, nil
+		}
+
+		_, err := c.SetStrictDecoding(true).GetRoom("1")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = c.SetCodec(nil).GetRoom("1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(codec.unmarshaled).To(BeZero())
+		Expect(c.Config().CustomCodec).To(BeTrue())
+		Expect(c.SetCodec(nil).Config().CustomCodec).To(BeFalse())
+	})
+
+	It("surfaces the codec's errors", func() {
+		_, err := c.SetCodec(failingCodec{}).CreateRoom("room", "")
+		Expect(err).To(MatchError("can't encode"))
+	})
+})
+
+type failingCodec struct{}
+
+func (failingCodec) Marshal(v interface{}) ([]byte, error)      { return nil, fmt.Errorf("can't encode") }
+func (failingCodec) Unmarshal(data []byte, v interface{}) error { return fmt.Errorf("can't decode") }
+
+// Measures decoding a large page of rooms with a client's codec.  To compare another codec (ex. jsoniter) against
+// StdCodec, add a case for it.  Run with: go test -run '^$' -bench Codec
+func BenchmarkCodec(b *testing.B) {
+	items := make([]string, 1000)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"id":"%d","title":"room %d","type":"group","isLocked":false,"created":"2018-06-01T12:00:00.000Z"}`, i, i)
+	}
+	page := []byte(`{"items":[` + strings.Join(items, ",") + `]}`)
+
+	for _, bc := range []struct {
+		name  string
+		codec Codec
+	}{
+		{"std", StdCodec{}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			c := New("mock").SetCodec(bc.codec).(*client)
+			b.SetBytes(int64(len(page)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var rl RoomList
+				if err := c.unmarshal(page, &rl); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
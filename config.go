@@ -0,0 +1,106 @@
+package spark
+
+import (
+	"net/http"
+	"time"
+)
+
+// The placeholder that ClientConfig reports in place of a token.
+const redactedToken = "[redacted]"
+
+// ClientConfig is a snapshot of a client's effective settings, as configured by New and the SetX methods, for
+// debugging and support logs.  The token itself is never included: Token is "[redacted]" if the client has one, and
+// empty if it doesn't.  The observers and debug writer are only reported as being set or not.
+type ClientConfig struct {
+	BaseURL string
+	Token   string
+
+	MaxPerPage    int
+	MaxPerPageFor map[string]int
+	MaxPages      int
+	MaxRetries    int
+
+	// RateLimit is in requests per second, and 0 if requests aren't paced.  See SetRateLimit.
+	RateLimit float64
+	RateBurst int
+
+	// GETCacheTTL is 0 if responses aren't cached.  See SetGETCache.
+	GETCacheTTL     time.Duration
+	GETCacheEntries int
+
+	StrictDecoding        bool
+	CustomCodec           bool
+	Deduplication         bool
+	AdminToken            bool
+	UserAgent             string
+	APIVersion            string
+	MarkdownFallback      bool
+	RequireClassification bool
+
+	// Timeout is that of the *http.Client that requests are sent with, where 0 means none.  CustomHTTPClient reports
+	// whether one was set with SetHTTPClient (or SetTLSConfig or SetConnectionPool), rather than the default.
+	Timeout          time.Duration
+	CustomHTTPClient bool
+	FollowRedirects  bool
+
+	ResponseObserver bool
+	MetricsObserver  bool
+	DebugWriter      bool
+
+	// Whether the identity that IsSelfAuthored and friends look up has been cached yet, and how many idempotency keys
+	// are remembered for CreateMessageWithOptions.
+	IdentityCached  bool
+	IdempotencyKeys int
+}
+
+// Config reports the client's effective settings.  The result is a copy, so changing it doesn't affect the client.
+func (c *client) Config() ClientConfig {
+	cfg := ClientConfig{
+		BaseURL:               BaseURL,
+		MaxPerPage:            c.pageMax,
+		MaxPages:              c.maxPages,
+		MaxRetries:            c.maxRetries,
+		StrictDecoding:        c.strict,
+		CustomCodec:           c.codec != nil,
+		Deduplication:         c.dedupe,
+		AdminToken:            c.admin,
+		UserAgent:             c.userAgent,
+		APIVersion:            c.apiVersion,
+		MarkdownFallback:      c.markdownFallback,
+		RequireClassification: c.requireClassification,
+		CustomHTTPClient:      c.httpCli != nil,
+		FollowRedirects:       !c.noRedirects,
+		ResponseObserver:      c.observer != nil,
+		MetricsObserver:       c.metrics != nil,
+		DebugWriter:           c.debug != nil,
+	}
+	if c.token != "" {
+		cfg.Token = redactedToken
+	}
+	if len(c.resourcePageMax) > 0 {
+		cfg.MaxPerPageFor = make(map[string]int, len(c.resourcePageMax))
+		for resource, max := range c.resourcePageMax {
+			cfg.MaxPerPageFor[resource] = max
+		}
+	}
+	if c.cache != nil {
+		cfg.GETCacheTTL = c.cache.ttl
+		cfg.GETCacheEntries = c.cache.max
+	}
+	if c.limiter != nil {
+		cfg.RateLimit = c.limiter.perSecond
+		cfg.RateBurst = c.limiter.burst
+	}
+	if cli, ok := c.http().(*http.Client); ok {
+		cfg.Timeout = cli.Timeout
+	}
+	if c.self != nil {
+		c.self.mu.Lock()
+		cfg.IdentityCached = c.self.me != nil
+		c.self.mu.Unlock()
+	}
+	if c.sent != nil {
+		cfg.IdempotencyKeys = c.sent.max
+	}
+	return cfg
+}
@@ -0,0 +1,93 @@
+package spark
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	It("reports the settings of a new client", func() {
+		cfg := New("secret-token").Config()
+		Expect(cfg.BaseURL).To(Equal(BaseURL))
+		Expect(cfg.Token).To(Equal("[redacted]"))
+		Expect(cfg.MaxPerPage).To(Equal(50))
+		Expect(cfg.MaxPages).To(Equal(defaultMaxPages))
+		Expect(cfg.UserAgent).To(Equal(DefaultUserAgent))
+		Expect(cfg.RateLimit).To(BeZero())
+		Expect(cfg.MaxPerPageFor).To(BeNil())
+		Expect(cfg.CustomHTTPClient).To(BeFalse())
+		Expect(cfg.FollowRedirects).To(BeTrue())
+		Expect(cfg.IdempotencyKeys).To(Equal(maxIdempotencyKeys))
+	})
+
+	It("reports what was set with the SetX methods", func() {
+		c := New("secret-token").
+			SetMaxPerPage(25).
+			SetMaxPerPageFor("messages", 100).
+			SetMaxPages(7).
+			SetMaxRetries(3).
+			SetRateLimit(2.5, 4).
+			SetGETCache(time.Minute, 100).
+			SetStrictDecoding(true).
+			SetDeduplication(true).
+			SetAdminToken(true).
+			SetUserAgent("my-bot/1.0").
+			SetAPIVersion("beta").
+			SetMarkdownFallback(true).
+			SetRequireClassification(true).
+			SetHTTPClient(&http.Client{Timeout: 5 * time.Second}).
+			SetFollowRedirects(false).
+			SetResponseObserver(func(string, http.Header) {}).
+			SetMetricsObserver(func(string, string, int, time.Duration) {}).
+			SetDebugWriter(new(bytes.Buffer))
+
+		Expect(c.Config()).To(Equal(ClientConfig{
+			BaseURL:               BaseURL,
+			Token:                 "[redacted]",
+			MaxPerPage:            25,
+			MaxPerPageFor:         map[string]int{"messages": 100},
+			MaxPages:              7,
+			MaxRetries:            3,
+			RateLimit:             2.5,
+			RateBurst:             4,
+			GETCacheTTL:           time.Minute,
+			GETCacheEntries:       100,
+			StrictDecoding:        true,
+			Deduplication:         true,
+			AdminToken:            true,
+			UserAgent:             "my-bot/1.0",
+			APIVersion:            "beta",
+			MarkdownFallback:      true,
+			RequireClassification: true,
+			Timeout:               5 * time.Second,
+			CustomHTTPClient:      true,
+			ResponseObserver:      true,
+			MetricsObserver:       true,
+			DebugWriter:           true,
+			IdempotencyKeys:       maxIdempotencyKeys,
+		}))
+	})
+
+	It("never includes the token", func() {
+		Expect(New("").Config().Token).To(BeEmpty())
+		Expect(New("secret-token").SetToken("refreshed-token").Config().Token).To(Equal("[redacted]"))
+	})
+
+	It("returns a copy", func() {
+		c := New("secret-token").SetMaxPerPageFor("messages", 100)
+		cfg := c.Config()
+		cfg.MaxPerPageFor["messages"] = 1
+		Expect(c.Config().MaxPerPageFor).To(Equal(map[string]int{"messages": 100}))
+	})
+
+	It("reports whether the identity has been cached", func() {
+		c := New("secret-token")
+		Expect(c.Config().IdentityCached).To(BeFalse())
+		c.(*client).self.me = &Person{ID: "me"}
+		Expect(c.Config().IdentityCached).To(BeTrue())
+	})
+})
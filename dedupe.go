@@ -0,0 +1,85 @@
+package spark
+
+// The Dedupe helpers collapse resources with the same ID, keeping the first occurrence of each and otherwise preserving
+// the order of the list.  Spark can return an item twice when the data shifts while a query is paging through it.  See
+// also SetDeduplication, which applies them to the results of the list methods automatically.
+
+// DedupeRooms returns the rooms with duplicate IDs removed.
+func DedupeRooms(rooms []*Room) []*Room {
+	if rooms == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(rooms))
+	ret := make([]*Room, 0, len(rooms))
+	for _, r := range rooms {
+		if !seen[r.ID] {
+			seen[r.ID] = true
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+// DedupePeople returns the people with duplicate IDs removed.
+func DedupePeople(people []*Person) []*Person {
+	if people == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(people))
+	ret := make([]*Person, 0, len(people))
+	for _, p := range people {
+		if !seen[p.ID] {
+			seen[p.ID] = true
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// DedupeMessages returns the messages with duplicate IDs removed.
+func DedupeMessages(messages []*Message) []*Message {
+	if messages == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(messages))
+	ret := make([]*Message, 0, len(messages))
+	for _, m := range messages {
+		if !seen[m.ID] {
+			seen[m.ID] = true
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// DedupeMemberships returns the memberships with duplicate IDs removed.
+func DedupeMemberships(memberships []*Membership) []*Membership {
+	if memberships == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(memberships))
+	ret := make([]*Membership, 0, len(memberships))
+	for _, m := range memberships {
+		if !seen[m.ID] {
+			seen[m.ID] = true
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// DedupeWebhooks returns the webhooks with duplicate IDs removed.
+func DedupeWebhooks(webhooks []*Webhook) []*Webhook {
+	if webhooks == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(webhooks))
+	ret := make([]*Webhook, 0, len(webhooks))
+	for _, w := range webhooks {
+		if !seen[w.ID] {
+			seen[w.ID] = true
+			ret = append(ret, w)
+		}
+	}
+	return ret
+}
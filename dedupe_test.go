@@ -0,0 +1,33 @@
+package spark
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dedupe", func() {
+	It("keeps the first of each room ID, in order", func() {
+		rooms := []*Room{{ID: "1", Title: "a"}, {ID: "2"}, {ID: "1", Title: "b"}, {ID: "3"}, {ID: "2"}}
+		Expect(DedupeRooms(rooms)).To(Equal([]*Room{rooms[0], rooms[1], rooms[3]}))
+	})
+
+	It("dedupes the other resources by ID", func() {
+		people := []*Person{{ID: "1"}, {ID: "1"}, {ID: "2"}}
+		Expect(DedupePeople(people)).To(Equal([]*Person{people[0], people[2]}))
+
+		messages := []*Message{{ID: "1"}, {ID: "2"}, {ID: "2"}}
+		Expect(DedupeMessages(messages)).To(Equal([]*Message{messages[0], messages[1]}))
+
+		memberships := []*Membership{{ID: "1"}, {ID: "1"}}
+		Expect(DedupeMemberships(memberships)).To(Equal([]*Membership{memberships[0]}))
+
+		webhooks := []*Webhook{{ID: "1"}, {ID: "2"}}
+		Expect(DedupeWebhooks(webhooks)).To(Equal(webhooks))
+	})
+
+	It("preserves empty and nil lists", func() {
+		Expect(DedupeRooms(nil)).To(BeNil())
+		Expect(DedupeRooms([]*Room{})).To(BeEmpty())
+		Expect(DedupeRooms([]*Room{})).ToNot(BeNil())
+	})
+})
@@ -0,0 +1,47 @@
+package spark
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// RoomDeepLink returns a link that opens the room in the Webex app, in the form webexteams://im?space=<uuid>, for bots
+// that want to point people at a room from a message.  Returns an empty string for a nil room, or one without an ID.
+func RoomDeepLink(room *Room) string {
+	if room == nil || room.ID == "" {
+		return ""
+	}
+	return fmt.Sprintf("webexteams://im?space=%s", url.QueryEscape(resourceUUID(room.ID)))
+}
+
+// MessageDeepLink works like RoomDeepLink, except the link also scrolls to the message in its room, in the form
+// webexteams://im?space=<uuid>&message=<uuid>.  Returns an empty string for a nil message, or one without an ID or a
+// room ID.
+func MessageDeepLink(msg *Message) string {
+	if msg == nil || msg.ID == "" || msg.RoomID == "" {
+		return ""
+	}
+	return fmt.Sprintf("webexteams://im?space=%s&message=%s",
+		url.QueryEscape(resourceUUID(msg.RoomID)), url.QueryEscape(resourceUUID(msg.ID)))
+}
+
+// Returns the UUID that a Spark ID is for.  The API's IDs are base64 encodings of URIs like
+// ciscospark://us/ROOM/<uuid>, but the app's links take the bare UUID.  IDs that don't decode to such a URI are
+// returned as is, since they may already be UUIDs.
+func resourceUUID(id string) string {
+	b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(id, "="))
+	if err != nil {
+		return id
+	}
+	uri := string(b)
+	if !strings.HasPrefix(uri, "ciscospark://") {
+		return id
+	}
+	i := strings.LastIndex(uri, "/")
+	if i == len(uri)-1 {
+		return id
+	}
+	return uri[i+1:]
+}
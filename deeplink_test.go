@@ -0,0 +1,46 @@
+package spark
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Deep links", func() {
+	const (
+		roomID    = "Y2lzY29zcGFyazovL3VzL1JPT00vYmJjZWIxYWQtNDNmMS0zYjU4LTkxNDctZjE0YmIwYzRkMTU0"
+		messageID = "Y2lzY29zcGFyazovL3VzL01FU1NBR0UvOWExZTNhNTAtMGM0Ny0xMWU4LTlkNjktYWI5YjdkNGM4YWE4"
+	)
+
+	Describe("RoomDeepLink", func() {
+		It("links to the room's UUID", func() {
+			Expect(RoomDeepLink(&Room{ID: roomID})).To(Equal("webexteams://im?space=bbceb1ad-43f1-3b58-9147-f14bb0c4d154"))
+		})
+
+		It("decodes padded IDs", func() {
+			Expect(RoomDeepLink(&Room{ID: "Y2lzY29zcGFyazovL3VzL1JPT00vYWJjZA=="})).To(Equal("webexteams://im?space=abcd"))
+		})
+
+		It("uses IDs that aren't encoded URIs as is", func() {
+			Expect(RoomDeepLink(&Room{ID: "bbceb1ad-43f1-3b58-9147-f14bb0c4d154"})).To(Equal("webexteams://im?space=bbceb1ad-43f1-3b58-9147-f14bb0c4d154"))
+			Expect(RoomDeepLink(&Room{ID: "room 1"})).To(Equal("webexteams://im?space=room+1"))
+		})
+
+		It("returns an empty string for a nil room or one without an ID", func() {
+			Expect(RoomDeepLink(nil)).To(BeEmpty())
+			Expect(RoomDeepLink(&Room{Title: "room"})).To(BeEmpty())
+		})
+	})
+
+	Describe("MessageDeepLink", func() {
+		It("links to the message's UUID in its room", func() {
+			Expect(MessageDeepLink(&Message{ID: messageID, RoomID: roomID})).To(Equal(
+				"webexteams://im?space=bbceb1ad-43f1-3b58-9147-f14bb0c4d154&message=9a1e3a50-0c47-11e8-9d69-ab9b7d4c8aa8"))
+		})
+
+		It("returns an empty string for a nil message or one without an ID or room ID", func() {
+			Expect(MessageDeepLink(nil)).To(BeEmpty())
+			Expect(MessageDeepLink(&Message{RoomID: roomID})).To(BeEmpty())
+			Expect(MessageDeepLink(&Message{ID: messageID})).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,269 @@
+package spark
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ErrPageLimitExceeded is returned by paginated queries that stopped because they reached the client's page limit,
+// while the server still had more pages.  See SetMaxPages.
+var ErrPageLimitExceeded = errors.New("pagination exceeded page limit")
+
+// ErrRoomNotFound is returned (wrapped with the name that was looked up) by GetRoomByName when none of the client's
+// rooms has the name.  Check for it with errors.Is.
+var ErrRoomNotFound = errors.New("room not found")
+
+// ErrPersonNotFound is returned (wrapped with the email that was looked up) by GetPersonByEmail when nobody has the
+// email.  Check for it with errors.Is.
+var ErrPersonNotFound = errors.New("person not found")
+
+// APIError is returned when the server responds to a request with an unexpected HTTP status code.  Method and Path
+// identify the request that failed.  Path leaves out the query, which can hold sensitive values (ex. emails).
+//
+// When Body is one of Spark's error objects, Message, Errors, and TrackingID are filled in from it, and the error's
+// message is built from them.  Otherwise they're left empty, and the error's message includes the raw body.  Include
+// the TrackingID when reporting a problem to Cisco, it identifies the request in their logs.
+type APIError struct {
+	StatusCode int
+	Body       []byte
+	Method     string
+	Path       string
+	Message    string
+	Errors     []ErrorDetail
+	TrackingID string
+}
+
+// ErrorDetail is one of the problems listed in a Spark error object, ex. an invalid field of the request.
+type ErrorDetail struct {
+	Description string `json:"description"`
+}
+
+func (e *APIError) Error() string {
+	detail := fmt.Sprintf("%q", string(e.Body))
+	if msg := e.message(); msg != "" {
+		detail = msg
+		if e.TrackingID != "" {
+			detail += fmt.Sprintf(" (tracking ID %s)", e.TrackingID)
+		}
+	}
+
+	if e.Method == "" && e.Path == "" {
+		return fmt.Sprintf("HTTP Status %d: %s", e.StatusCode, detail)
+	}
+	return fmt.Sprintf("%s %s: HTTP Status %d: %s", e.Method, e.Path, e.StatusCode, detail)
+}
+
+// Returns the error's Message, along with any of its Errors that say something else, or "" if it has neither.
+func (e *APIError) message() string {
+	msgs := make([]string, 0, len(e.Errors)+1)
+	if e.Message != "" {
+		msgs = append(msgs, e.Message)
+	}
+	for _, d := range e.Errors {
+		if d.Description != "" && d.Description != e.Message {
+			msgs = append(msgs, d.Description)
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Returns the APIError for a response to req with an unexpected status.
+func newAPIError(req *http.Request, res *http.Response, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: res.StatusCode, Body: body, Method: req.Method, Path: req.URL.Path}
+	if e, ok := parseErrorBody(body); ok {
+		apiErr.Message = e.Message
+		apiErr.Errors = e.Errors
+		apiErr.TrackingID = e.TrackingID
+	}
+	return apiErr
+}
+
+// TransportError is returned when a request couldn't be sent, or no response to it was received, ex. because of a
+// network failure or a timeout.  Whether the server acted on the request is unknown.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("sending request: %v", e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// BodyReadError is returned when a response was received, but its body couldn't be read.  The server has acted on the
+// request, so it's not necessarily safe to retry.
+type BodyReadError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *BodyReadError) Error() string {
+	return fmt.Sprintf("reading response body (HTTP Status %d): %v", e.StatusCode, e.Err)
+}
+
+func (e *BodyReadError) Unwrap() error {
+	return e.Err
+}
+
+// ContentTypeError is returned when the server responds to a request successfully, but with a body that isn't JSON,
+// ex. an HTML error page from a proxy between the client and Spark.  Whether Spark acted on the request is unknown.
+type ContentTypeError struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// How much of the body a ContentTypeError's message includes.
+const contentTypeSnippet = 200
+
+func (e *ContentTypeError) Error() string {
+	snippet := e.Body
+	if len(snippet) > contentTypeSnippet {
+		snippet = snippet[:contentTypeSnippet]
+	}
+	return fmt.Sprintf("expected JSON response but got %s (HTTP Status %d): %q", e.ContentType, e.StatusCode, snippet)
+}
+
+// IsNotFound reports whether err is (or wraps) an APIError for an HTTP 404.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized reports whether err is (or wraps) an APIError for an HTTP 401.
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsRateLimited reports whether err is (or wraps) an APIError for an HTTP 429.
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// IsConflict reports whether err is (or wraps) an APIError for an HTTP 409.
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+// RoomErrors is returned by methods that query several rooms, when the queries for some of them failed.  It maps the
+// IDs of the rooms that failed to their errors.
+type RoomErrors map[string]error
+
+func (e RoomErrors) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	msgs := make([]string, len(ids))
+	for i, id := range ids {
+		msgs[i] = fmt.Sprintf("room %s: %v", id, e[id])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// WebhookErrors is returned by methods that act on several webhooks, when doing so failed for some of them.  It maps
+// the IDs of the webhooks that failed to their errors.
+type WebhookErrors map[string]error
+
+func (e WebhookErrors) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	msgs := make([]string, len(ids))
+	for i, id := range ids {
+		msgs[i] = fmt.Sprintf("webhook %s: %v", id, e[id])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// MessageErrors is returned by methods that act on several messages, when doing so failed for some of them.  It maps
+// the IDs of the messages that failed to their errors.
+type MessageErrors map[string]error
+
+func (e MessageErrors) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	msgs := make([]string, len(ids))
+	for i, id := range ids {
+		msgs[i] = fmt.Sprintf("message %s: %v", id, e[id])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// PeopleErrors is returned by methods that act on several people, when doing so failed for some of them.  It maps the
+// emails of the people that failed to their errors.
+type PeopleErrors map[string]error
+
+func (e PeopleErrors) Error() string {
+	emails := make([]string, 0, len(e))
+	for email := range e {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	msgs := make([]string, len(emails))
+	for i, email := range emails {
+		msgs[i] = fmt.Sprintf("person %s: %v", email, e[email])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// PingError is returned by Ping when the health check fails.  Unauthorized reports whether the server rejected the
+// client's token, as opposed to the server being unreachable (or timing out) or failing in some other way.
+type PingError struct {
+	Unauthorized bool
+	Err          error
+}
+
+func (e *PingError) Error() string {
+	if e.Unauthorized {
+		return fmt.Sprintf("ping failed, token was rejected: %v", e.Err)
+	}
+	return fmt.Sprintf("ping failed: %v", e.Err)
+}
+
+func (e *PingError) Unwrap() error {
+	return e.Err
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// The shape of the error bodies that Spark sends, ex.
+// {"message": "Room not found", "errors": [{"description": "Room not found"}], "trackingId": "..."}
+type errorBody struct {
+	Message    string        `json:"message"`
+	Errors     []ErrorDetail `json:"errors"`
+	TrackingID string        `json:"trackingId"`
+}
+
+// Parses a response body as a Spark error object, reporting whether it is one.
+func parseErrorBody(b []byte) (errorBody, bool) {
+	var e errorBody
+	if err := json.Unmarshal(b, &e); err != nil {
+		return errorBody{}, false
+	}
+	return e, e.Message != "" || len(e.Errors) > 0
+}
+
+// Reports whether a response body is a Spark error object.
+func isErrorBody(b []byte) bool {
+	_, ok := parseErrorBody(b)
+	return ok
+}
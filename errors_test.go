@@ -0,0 +1,168 @@
+package spark
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Errors", func() {
+	Describe("APIError", func() {
+		It("formats the status and body", func() {
+			err := &APIError{StatusCode: http.StatusNotFound, Body: []byte("not here")}
+			Expect(err).To(MatchError(`HTTP Status 404: "not here"`))
+		})
+
+		It("leads with the request's method and path when known", func() {
+			err := &APIError{StatusCode: http.StatusNotFound, Body: []byte("not here"), Method: "DELETE", Path: "/v1/rooms/1"}
+			Expect(err).To(MatchError(`DELETE /v1/rooms/1: HTTP Status 404: "not here"`))
+		})
+
+		It("is returned for unexpected status codes", func() {
+			mockCli := new(mockHTTPClient)
+			httpCli = mockCli
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString("not here")),
+					StatusCode: http.StatusNotFound,
+				}
+				return r, nil
+			}
+
+			_, err := New("mock").GetRoom("1")
+			var apiErr *APIError
+			Expect(errors.As(err, &apiErr)).To(BeTrue())
+			Expect(apiErr.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(apiErr.Body).To(Equal([]byte("not here")))
+		})
+
+		It("parses Spark's error objects", func() {
+			body := `{"message":"Invalid request","errors":[{"description":"Invalid request"},{"description":"title is required"}],"trackingId":"ROUTER_1"}`
+			mockCli := new(mockHTTPClient)
+			httpCli = mockCli
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{Body: closer(bytes.NewBufferString(body)), StatusCode: http.StatusBadRequest}, nil
+			}
+
+			_, err := New("mock").CreateRoom("room", "")
+			var apiErr *APIError
+			Expect(errors.As(err, &apiErr)).To(BeTrue())
+			Expect(apiErr.Message).To(Equal("Invalid request"))
+			Expect(apiErr.Errors).To(Equal([]ErrorDetail{{"Invalid request"}, {"title is required"}}))
+			Expect(apiErr.TrackingID).To(Equal("ROUTER_1"))
+			Expect(apiErr.Body).To(Equal([]byte(body)))
+			Expect(err).To(MatchError("POST /v1/rooms: HTTP Status 400: Invalid request; title is required (tracking ID ROUTER_1)"))
+		})
+
+		It("formats error objects without a message or tracking ID", func() {
+			err := &APIError{StatusCode: http.StatusBadRequest, Errors: []ErrorDetail{{"title is required"}, {"type is invalid"}}}
+			Expect(err).To(MatchError("HTTP Status 400: title is required; type is invalid"))
+		})
+
+		It("falls back to the raw body when it isn't an error object", func() {
+			for _, body := range []string{`<html>Bad Gateway</html>`, `{"message": 5}`, `{"id":"1"}`, `[]`, `null`, ``} {
+				mockCli := new(mockHTTPClient)
+				httpCli = mockCli
+				mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+					return &http.Response{Body: closer(bytes.NewBufferString(body)), StatusCode: http.StatusBadGateway}, nil
+				}
+
+				_, err := New("mock").GetRoom("1")
+				Expect(err).To(MatchError(&APIError{StatusCode: http.StatusBadGateway, Body: []byte(body), Method: "GET", Path: "/v1/rooms/1"}), body)
+				Expect(err).To(MatchError(fmt.Sprintf("GET /v1/rooms/1: HTTP Status 502: %q", body)), body)
+			}
+		})
+	})
+
+	Describe("failure modes", func() {
+		var mockCli *mockHTTPClient
+
+		BeforeEach(func() {
+			mockCli = new(mockHTTPClient)
+			httpCli = mockCli
+		})
+
+		It("wraps Do() failures in a TransportError", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+
+			_, err := New("mock").GetRoom("1")
+			var transportErr *TransportError
+			Expect(errors.As(err, &transportErr)).To(BeTrue())
+			Expect(errors.Is(err, mockErr)).To(BeTrue())
+			Expect(errors.As(err, new(*BodyReadError))).To(BeFalse())
+			Expect(errors.As(err, new(*APIError))).To(BeFalse())
+		})
+
+		It("wraps body read failures in a BodyReadError", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(new(failReader)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			_, err := New("mock").GetRoom("1")
+			var readErr *BodyReadError
+			Expect(errors.As(err, &readErr)).To(BeTrue())
+			Expect(readErr.StatusCode).To(Equal(http.StatusOK))
+			Expect(errors.Is(err, mockErr)).To(BeTrue())
+			Expect(errors.As(err, new(*TransportError))).To(BeFalse())
+			Expect(errors.As(err, new(*APIError))).To(BeFalse())
+		})
+
+		It("returns an APIError, and only an APIError, for bad statuses", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString("oops")),
+					StatusCode: http.StatusInternalServerError,
+				}
+				return r, nil
+			}
+
+			_, err := New("mock").GetRoom("1")
+			Expect(errors.As(err, new(*APIError))).To(BeTrue())
+			Expect(errors.As(err, new(*TransportError))).To(BeFalse())
+			Expect(errors.As(err, new(*BodyReadError))).To(BeFalse())
+		})
+	})
+
+	Describe("predicates", func() {
+		predicates := map[int]func(error) bool{
+			http.StatusNotFound:        IsNotFound,
+			http.StatusUnauthorized:    IsUnauthorized,
+			http.StatusTooManyRequests: IsRateLimited,
+			http.StatusConflict:        IsConflict,
+		}
+
+		It("match only their own status code", func() {
+			for status, is := range predicates {
+				for other := range predicates {
+					err := &APIError{StatusCode: other}
+					Expect(is(err)).To(Equal(status == other), fmt.Sprintf("predicate for %d, error %d", status, other))
+				}
+			}
+		})
+
+		It("match wrapped errors", func() {
+			for status, is := range predicates {
+				err := fmt.Errorf("wrapped: %w", &APIError{StatusCode: status})
+				Expect(is(err)).To(BeTrue(), fmt.Sprintf("predicate for %d", status))
+			}
+		})
+
+		It("don't match non-API errors", func() {
+			for status, is := range predicates {
+				Expect(is(nil)).To(BeFalse(), fmt.Sprintf("predicate for %d", status))
+				Expect(is(mockErr)).To(BeFalse(), fmt.Sprintf("predicate for %d", status))
+				Expect(is(fmt.Errorf("HTTP Status %d", status))).To(BeFalse(), fmt.Sprintf("predicate for %d", status))
+			}
+		})
+	})
+})
@@ -0,0 +1,1729 @@
+package spark
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeClient is an in-memory implementation of Client, for unit testing code that depends on a Client without talking
+// to Spark.  Resources created through it are stored in memory and returned by its get and list methods, so a test can,
+// for example, CreateMessage and then see that message in ListMessages.  It's safe for concurrent use.
+//
+// Any method can be made to fail by registering an error for it with SetError.  Lookups of resources that don't exist
+// fail with an *APIError with a 404 status, so IsNotFound works just like it does against the real API.
+type FakeClient struct {
+	mu sync.Mutex
+
+	me          *Person
+	errors      map[string]error
+	nextID      int
+	people      []*Person
+	rooms       []*Room
+	memberships []*Membership
+	messages    []*Message // newest first, like the real API
+	webhooks    []*Webhook
+	actions     []*AttachmentAction
+
+	idempotencyKeys map[string]string // message IDs by key
+}
+
+var _ Client = (*FakeClient)(nil)
+
+// NewFakeClient creates an empty FakeClient that authenticates as the provided person.  The person is added to the
+// fake's people, and is the author of every message created through it.  If me is nil, a placeholder person is used.
+func NewFakeClient(me *Person) *FakeClient {
+	if me == nil {
+		me = &Person{
+			ID:          "fake-me",
+			Emails:      []string{"me@example.com"},
+			DisplayName: "Fake Me",
+		}
+	}
+	cp := me.Clone()
+
+	return &FakeClient{
+		me:     cp,
+		errors: make(map[string]error),
+		people: []*Person{cp},
+	}
+}
+
+// SetError makes the named method (ex. "CreateMessage") fail with err, without doing anything else, until it is
+// cleared by calling SetError again with a nil error.
+func (f *FakeClient) SetError(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		delete(f.errors, method)
+		return
+	}
+	f.errors[method] = err
+}
+
+// AddMembership adds a membership to the fake, for testing code that reads memberships.
+func (f *FakeClient) AddMembership(m *Membership) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cp := m.Clone()
+	if cp.ID == "" {
+		cp.ID = f.newID()
+	}
+	f.memberships = append(f.memberships, cp)
+}
+
+// AddAttachmentAction adds an attachment action to the fake, as if someone had submitted a card, for testing code that
+// handles card submissions.  It returns the added action, which has an ID assigned if it didn't have one.
+func (f *FakeClient) AddAttachmentAction(a *AttachmentAction) *AttachmentAction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cp := *a
+	if cp.ID == "" {
+		cp.ID = f.newID()
+	}
+	if cp.Created.IsZero() {
+		cp.Created = Time{time.Now()}
+	}
+	f.actions = append(f.actions, &cp)
+	ret := cp
+	return &ret
+}
+
+// Must be called with the lock held.
+func (f *FakeClient) newID() string {
+	f.nextID++
+	return fmt.Sprintf("fake-%d", f.nextID)
+}
+
+func notFound(kind, id string) error {
+	return &APIError{StatusCode: http.StatusNotFound, Body: []byte(fmt.Sprintf("no %s with ID %q", kind, id))}
+}
+
+// Shortens a list result to max entries, unless max is 0.
+func limit(n, max int) int {
+	if max > 0 && max < n {
+		return max
+	}
+	return n
+}
+
+func (f *FakeClient) SetMaxPerPage(max int) Client                     { return f }
+func (f *FakeClient) SetMaxPerPageFor(resource string, max int) Client { return f }
+func (f *FakeClient) SetMaxPages(pages int) Client                     { return f }
+func (f *FakeClient) SetToken(token string) Client                     { return f }
+func (f *FakeClient) SetAdminToken(admin bool) Client                  { return f }
+func (f *FakeClient) SetUserAgent(ua string) Client                    { return f }
+func (f *FakeClient) SetAPIVersion(v string) Client                    { return f }
+func (f *FakeClient) SetDeduplication(dedupe bool) Client              { return f }
+func (f *FakeClient) SetStrictDecoding(strict bool) Client             { return f }
+func (f *FakeClient) SetCodec(codec Codec) Client                      { return f }
+func (f *FakeClient) SetMaxRetries(retries int) Client                 { return f }
+func (f *FakeClient) SetRateLimit(perSecond float64, burst int) Client { return f }
+
+func (f *FakeClient) SetResponseObserver(fn func(resource string, h http.Header)) Client { return f }
+func (f *FakeClient) SetConnectionPool(maxIdle, maxIdlePerHost int) Client               { return f }
+func (f *FakeClient) SetFollowRedirects(follow bool) Client                              { return f }
+func (f *FakeClient) SetTLSConfig(cfg *tls.Config) Client                                { return f }
+func (f *FakeClient) SetHTTPClient(cli *http.Client) Client                              { return f }
+func (f *FakeClient) SetRequireClassification(require bool) Client                       { return f }
+func (f *FakeClient) SetMarkdownFallback(fallback bool) Client                           { return f }
+func (f *FakeClient) SetDebugWriter(w io.Writer) Client                                  { return f }
+func (f *FakeClient) Close() error                                                       { return nil }
+
+// The fake ignores the SetX methods, so it always reports the settings of a new client.
+func (f *FakeClient) Config() ClientConfig {
+	return New("fake").Config()
+}
+
+func (f *FakeClient) SetMetricsObserver(fn func(resource, method string, status int, latency time.Duration)) Client {
+	return f
+}
+
+func (f *FakeClient) SetGETCache(ttl time.Duration, maxEntries int) Client { return f }
+
+// The fake doesn't model any endpoints beyond the ones the rest of Client covers, so unless an error is injected with
+// SetError, DoJSON fails with a 404 APIError like a real unknown endpoint would.
+func (f *FakeClient) DoJSON(method, path string, body interface{}, out interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["DoJSON"]; err != nil {
+		return err
+	}
+	return &APIError{StatusCode: http.StatusNotFound, Body: []byte(fmt.Sprintf("no endpoint %s %s", method, path))}
+}
+
+// The fake's service is always operational, unless an error is set for ServiceStatus.
+func (f *FakeClient) ServiceStatus() (*Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ServiceStatus"]; err != nil {
+		return nil, err
+	}
+	return &Status{Indicator: StatusOperational, Description: "All Systems Operational"}, nil
+}
+
+func (f *FakeClient) GetPerson(personID string) (*Person, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetPerson"]; err != nil {
+		return nil, err
+	}
+	return f.getPerson(personID)
+}
+
+// Must be called with the lock held.
+func (f *FakeClient) getPerson(personID string) (*Person, error) {
+	if personID == "" {
+		return nil, fmt.Errorf("no person ID specified")
+	}
+	if personID == "me" {
+		personID = f.me.ID
+	}
+	for _, p := range f.people {
+		if p.ID == personID {
+			return p.Clone(), nil
+		}
+	}
+	return nil, notFound("person", personID)
+}
+
+func (f *FakeClient) GetPersonRaw(personID string) (json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetPersonRaw"]; err != nil {
+		return nil, err
+	}
+	p, err := f.getPerson(personID)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(p)
+}
+
+func (f *FakeClient) GetMyself() (*Person, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetMyself"]; err != nil {
+		return nil, err
+	}
+	return f.getPerson("me")
+}
+
+func (f *FakeClient) TokenInfo() (*TokenInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["TokenInfo"]; err != nil {
+		return nil, err
+	}
+	me, err := f.getPerson("me")
+	if err != nil {
+		return nil, err
+	}
+	return tokenInfo(me), nil
+}
+
+func (f *FakeClient) Ping(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["Ping"]; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (f *FakeClient) GetPersonByEmail(email string) (*Person, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetPersonByEmail"]; err != nil {
+		return nil, err
+	}
+	if email == "" {
+		return nil, fmt.Errorf("no email specified")
+	}
+	for _, p := range f.people {
+		if hasEmail(p, email) {
+			return p.Clone(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrPersonNotFound, email)
+}
+
+func (f *FakeClient) IsSelfAuthored(ctx context.Context, msg *Message) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["IsSelfAuthored"]; err != nil {
+		return false, err
+	}
+	if msg == nil {
+		return false, fmt.Errorf("nil message")
+	}
+	return msg.PersonID == f.me.ID, nil
+}
+
+func (f *FakeClient) ListPeople(max int, params *PeopleListParams) ([]*Person, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListPeople"]; err != nil {
+		return nil, err
+	}
+	return f.listPeople(max, params)
+}
+
+// The fake never pages, so its pagination links are always empty.
+func (f *FakeClient) ListPeopleSingle(max int, params *PeopleListParams) ([]*Person, Links, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListPeopleSingle"]; err != nil {
+		return nil, Links{}, err
+	}
+	people, err := f.listPeople(max, params)
+	return people, Links{}, err
+}
+
+func (f *FakeClient) ListOrgPeople(orgID string) ([]*Person, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListOrgPeople"]; err != nil {
+		return nil, err
+	}
+	if orgID == "" {
+		return nil, fmt.Errorf("no org ID specified")
+	}
+	return f.listPeople(0, &PeopleListParams{OrgID: orgID})
+}
+
+func (f *FakeClient) listPeople(max int, params *PeopleListParams) ([]*Person, error) {
+	if params == nil {
+		params = &PeopleListParams{}
+	}
+
+	people := []*Person{}
+	for _, p := range f.people {
+		if params.Email != "" && !hasEmail(p, params.Email) {
+			continue
+		}
+		if params.DisplayName != "" && !strings.HasPrefix(p.DisplayName, params.DisplayName) {
+			continue
+		}
+		if params.ID != "" && p.ID != params.ID {
+			continue
+		}
+		if params.OrgID != "" && p.OrgId != params.OrgID {
+			continue
+		}
+		people = append(people, p.Clone())
+	}
+	return people[:limit(len(people), max)], nil
+}
+
+func (f *FakeClient) CreatePerson(p *Person) (*Person, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["CreatePerson"]; err != nil {
+		return nil, err
+	}
+	if err := validateNewPerson(p); err != nil {
+		return nil, err
+	}
+	return f.createPerson(p), nil
+}
+
+// Must be called with the lock held.
+func (f *FakeClient) createPerson(p *Person) *Person {
+	cp := p.Clone()
+	cp.ID = f.newID()
+	cp.Created = Time{time.Now()}
+	f.people = append(f.people, cp)
+
+	return cp.Clone()
+}
+
+// The fake creates the people one at a time.  Like the real client, those not created before ctx is done are failed
+// with its error.
+func (f *FakeClient) CreatePeople(ctx context.Context, people []*Person, concurrency int) ([]*Person, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["CreatePeople"]; err != nil {
+		return nil, err
+	}
+	if err := validateNewPeople(people); err != nil {
+		return nil, err
+	}
+
+	created := []*Person{}
+	errs := make(PeopleErrors)
+	for _, p := range people {
+		if err := ctx.Err(); err != nil {
+			errs[p.Emails[0]] = err
+			continue
+		}
+		created = append(created, f.createPerson(p))
+	}
+	if len(errs) > 0 {
+		return created, errs
+	}
+	return created, nil
+}
+
+func (f *FakeClient) UpdatePerson(p *Person) (*Person, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["UpdatePerson"]; err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("nil person")
+	}
+	if p.ID == "" {
+		return nil, fmt.Errorf("no person ID specified")
+	}
+
+	for i, existing := range f.people {
+		if existing.ID == p.ID {
+			cp := p.Clone()
+			cp.Created = existing.Created
+			f.people[i] = cp
+
+			return cp.Clone(), nil
+		}
+	}
+	return nil, notFound("person", p.ID)
+}
+
+func (f *FakeClient) UpdatePersonFields(personID string, fields map[string]interface{}) (*Person, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["UpdatePersonFields"]; err != nil {
+		return nil, err
+	}
+	if personID == "" {
+		return nil, fmt.Errorf("no person ID specified")
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no person fields specified")
+	}
+
+	for i, existing := range f.people {
+		if existing.ID != personID {
+			continue
+		}
+		current, err := json.Marshal(existing)
+		if err != nil {
+			return nil, err
+		}
+		merged, err := mergeFields(StdCodec{}, current, fields)
+		if err != nil {
+			return nil, err
+		}
+		var p Person
+		if err := json.Unmarshal(merged, &p); err != nil {
+			return nil, err
+		}
+		p.ID, p.Created = existing.ID, existing.Created
+		f.people[i] = &p
+
+		ret := p
+		return &ret, nil
+	}
+	return nil, notFound("person", personID)
+}
+
+// The fake doesn't store images, so it gives the person a made-up avatar URL instead.
+func (f *FakeClient) SetPersonAvatar(personID string, image io.Reader, contentType string) (*Person, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["SetPersonAvatar"]; err != nil {
+		return nil, err
+	}
+	if personID == "" {
+		return nil, fmt.Errorf("no person ID specified")
+	}
+	if image == nil {
+		return nil, fmt.Errorf("no avatar image specified")
+	}
+	if err := validateImageType(contentType); err != nil {
+		return nil, err
+	}
+
+	for i, p := range f.people {
+		if p.ID == personID {
+			cp := p.Clone()
+			cp.Avatar = fmt.Sprintf("%s/avatars/%s", BaseURL, f.newID())
+			f.people[i] = cp
+
+			return cp.Clone(), nil
+		}
+	}
+	return nil, notFound("person", personID)
+}
+
+func (f *FakeClient) DeletePerson(ID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["DeletePerson"]; err != nil {
+		return err
+	}
+	if ID == "" {
+		return fmt.Errorf("no person ID specified")
+	}
+
+	for i, p := range f.people {
+		if p.ID == ID {
+			f.people = append(f.people[:i], f.people[i+1:]...)
+			return nil
+		}
+	}
+	return notFound("person", ID)
+}
+
+func (f *FakeClient) GetRoom(roomId string) (*Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetRoom"]; err != nil {
+		return nil, err
+	}
+	return f.getRoom(roomId)
+}
+
+// Must be called with the lock held.
+func (f *FakeClient) getRoom(roomID string) (*Room, error) {
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+	for _, r := range f.rooms {
+		if r.ID == roomID {
+			return r.Clone(), nil
+		}
+	}
+	return nil, notFound("room", roomID)
+}
+
+func (f *FakeClient) GetRoomRaw(roomID string) (json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetRoomRaw"]; err != nil {
+		return nil, err
+	}
+	r, err := f.getRoom(roomID)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(r)
+}
+
+// The fake's ETags are a hash of the room's JSON, so they change whenever the room does.
+func (f *FakeClient) GetRoomIfChanged(roomID, etag string) (*Room, string, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetRoomIfChanged"]; err != nil {
+		return nil, "", false, err
+	}
+	r, err := f.getRoom(roomID)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, "", false, err
+	}
+	current := fmt.Sprintf(`"%x"`, sha1.Sum(b))
+	if current == etag {
+		return nil, etag, false, nil
+	}
+	return r, current, true, nil
+}
+
+func (f *FakeClient) GetRoomByName(roomName string) (*Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetRoomByName"]; err != nil {
+		return nil, err
+	}
+	if roomName == "" {
+		return nil, fmt.Errorf("no room name specified")
+	}
+	for _, r := range f.rooms {
+		if r.Title == roomName {
+			return r.Clone(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrRoomNotFound, roomName)
+}
+
+func (f *FakeClient) ListRooms(max int, params *RoomListParams) ([]*Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListRooms"]; err != nil {
+		return nil, err
+	}
+	return f.listRooms(max, params)
+}
+
+func (f *FakeClient) ListDirectRooms() ([]*Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListDirectRooms"]; err != nil {
+		return nil, err
+	}
+	return f.listRooms(0, &RoomListParams{Type: RoomTypeDirect})
+}
+
+func (f *FakeClient) ListGroupRooms(max int) ([]*Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListGroupRooms"]; err != nil {
+		return nil, err
+	}
+	return f.listRooms(max, &RoomListParams{Type: RoomTypeGroup})
+}
+
+// The fake never pages, so any number of pages lists every room.
+func (f *FakeClient) ListRoomsPages(pages int, params *RoomListParams) ([]*Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListRoomsPages"]; err != nil {
+		return nil, err
+	}
+	if pages < 1 {
+		return nil, fmt.Errorf("at least one page must be requested, not %d", pages)
+	}
+	return f.listRooms(0, params)
+}
+
+func (f *FakeClient) ListActiveRooms(since time.Time) ([]*Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListActiveRooms"]; err != nil {
+		return nil, err
+	}
+
+	rooms := []*Room{}
+	for _, r := range f.rooms {
+		if r.LastActivity.Before(since) {
+			continue
+		}
+		rooms = append(rooms, r.Clone())
+	}
+	sort.SliceStable(rooms, func(i, j int) bool { return rooms[i].LastActivity.After(rooms[j].LastActivity.Time) })
+	return rooms, nil
+}
+
+func (f *FakeClient) ListStaleRooms(olderThan time.Duration) ([]*Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListStaleRooms"]; err != nil {
+		return nil, err
+	}
+
+	rooms := []*Room{}
+	for _, r := range f.rooms {
+		if !r.StaleSince(olderThan) {
+			continue
+		}
+		rooms = append(rooms, r.Clone())
+	}
+	sort.SliceStable(rooms, func(i, j int) bool { return rooms[i].LastActivity.After(rooms[j].LastActivity.Time) })
+	return rooms, nil
+}
+
+func (f *FakeClient) ListRoomsSingle(max int, params *RoomListParams) ([]*Room, Links, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListRoomsSingle"]; err != nil {
+		return nil, Links{}, err
+	}
+	rooms, err := f.listRooms(max, params)
+	return rooms, Links{}, err
+}
+
+func (f *FakeClient) listRooms(max int, params *RoomListParams) ([]*Room, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	if params == nil {
+		params = &RoomListParams{}
+	}
+
+	rooms := []*Room{}
+	for _, r := range f.rooms {
+		if params.TeamID != "" && r.TeamID != params.TeamID {
+			continue
+		}
+		if params.Type != "" && r.Type != params.Type {
+			continue
+		}
+		rooms = append(rooms, r.Clone())
+	}
+	return rooms[:limit(len(rooms), max)], nil
+}
+
+func (f *FakeClient) CreateRoom(name, teamID string) (*Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["CreateRoom"]; err != nil {
+		return nil, err
+	}
+	title, err := roomTitle(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.createRoom(&Room{Title: title, TeamID: teamID, Type: RoomTypeGroup}), nil
+}
+
+func (f *FakeClient) CreateRoomWithOptions(r *NewRoom) (*Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["CreateRoomWithOptions"]; err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, fmt.Errorf("nil room")
+	}
+	title, err := roomTitle(r.Title)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.createRoom(&Room{
+		Title:              title,
+		TeamID:             r.TeamID,
+		Type:               RoomTypeGroup,
+		IsLocked:           r.IsLocked,
+		ClassificationID:   r.ClassificationID,
+		IsAnnouncementOnly: r.IsAnnouncementOnly,
+	}), nil
+}
+
+// Stores a new room, with the fake's identity as its creator and moderator.  Must be called with the lock held.
+func (f *FakeClient) createRoom(r *Room) *Room {
+	now := Time{time.Now()}
+
+	cp := r.Clone()
+	cp.ID = f.newID()
+	cp.CreatorID = f.me.ID
+	cp.Created = now
+	cp.LastActivity = now
+	f.rooms = append(f.rooms, cp)
+
+	f.memberships = append(f.memberships, &Membership{
+		ID:                f.newID(),
+		RoomID:            cp.ID,
+		PersonID:          f.me.ID,
+		PersonDisplayName: f.me.DisplayName,
+		PersonEmail:       firstEmail(f.me),
+		IsModerator:       true,
+		Created:           now,
+	})
+
+	return cp.Clone()
+}
+
+func firstEmail(p *Person) string {
+	if len(p.Emails) == 0 {
+		return ""
+	}
+	return p.Emails[0]
+}
+
+func (f *FakeClient) UpdateRoomName(roomID, newName string) (*Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["UpdateRoomName"]; err != nil {
+		return nil, err
+	}
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+	title, err := roomTitle(newName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range f.rooms {
+		if r.ID == roomID {
+			r.Title = title
+			return r.Clone(), nil
+		}
+	}
+	return nil, notFound("room", roomID)
+}
+
+func (f *FakeClient) MoveRoomToTeam(roomID, teamID string) (*Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["MoveRoomToTeam"]; err != nil {
+		return nil, err
+	}
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+
+	for _, r := range f.rooms {
+		if r.ID == roomID {
+			r.TeamID = teamID
+			return r.Clone(), nil
+		}
+	}
+	return nil, notFound("room", roomID)
+}
+
+func (f *FakeClient) DeleteRoom(roomID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["DeleteRoom"]; err != nil {
+		return err
+	}
+	if roomID == "" {
+		return fmt.Errorf("no room ID specified")
+	}
+
+	for i, r := range f.rooms {
+		if r.ID == roomID {
+			f.rooms = append(f.rooms[:i], f.rooms[i+1:]...)
+			return nil
+		}
+	}
+	return notFound("room", roomID)
+}
+
+func (f *FakeClient) CreateMembership(m *NewMembership) (*Membership, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["CreateMembership"]; err != nil {
+		return nil, err
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := f.getRoom(m.RoomID); err != nil {
+		return nil, err
+	}
+
+	membership := &Membership{
+		ID:          f.newID(),
+		RoomID:      m.RoomID,
+		PersonID:    m.PersonID,
+		PersonEmail: m.PersonEmail,
+		IsModerator: m.IsModerator,
+		Created:     Time{time.Now()},
+	}
+	for _, p := range f.people {
+		if p.ID == m.PersonID || (m.PersonEmail != "" && hasEmail(p, m.PersonEmail)) {
+			membership.PersonID = p.ID
+			membership.PersonEmail = firstEmail(p)
+			membership.PersonDisplayName = p.DisplayName
+		}
+	}
+	f.memberships = append(f.memberships, membership)
+
+	return membership.Clone(), nil
+}
+
+func (f *FakeClient) GetMembership(roomID, personID string) (*Membership, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetMembership"]; err != nil {
+		return nil, err
+	}
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+	if personID == "" {
+		return nil, fmt.Errorf("no person ID specified")
+	}
+
+	for _, m := range f.memberships {
+		if m.RoomID == roomID && m.PersonID == personID {
+			return m.Clone(), nil
+		}
+	}
+	return nil, fmt.Errorf("no membership found for person %q in room %q", personID, roomID)
+}
+
+func (f *FakeClient) CountRoomMembers(roomID string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["CountRoomMembers"]; err != nil {
+		return 0, err
+	}
+	if roomID == "" {
+		return 0, fmt.Errorf("no room ID specified")
+	}
+	return len(f.roomMemberships(roomID, false)), nil
+}
+
+func (f *FakeClient) ListRoomModerators(roomID string) ([]*Membership, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListRoomModerators"]; err != nil {
+		return nil, err
+	}
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+	return f.roomMemberships(roomID, true), nil
+}
+
+func (f *FakeClient) RoomReadStatus(roomID string) (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["RoomReadStatus"]; err != nil {
+		return nil, err
+	}
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+	return readStatus(f.roomMemberships(roomID, false)), nil
+}
+
+func (f *FakeClient) ListMemberships(max int, params *MembershipListParams) ([]*Membership, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListMemberships"]; err != nil {
+		return nil, err
+	}
+	return f.listMemberships(max, params)
+}
+
+// Lists the memberships of the fake's me, who stands in for the identity that the real client looks up.
+func (f *FakeClient) ListMyMemberships() ([]*Membership, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListMyMemberships"]; err != nil {
+		return nil, err
+	}
+	return f.listMemberships(0, &MembershipListParams{PersonID: f.me.ID})
+}
+
+// Must be called with the lock held.
+func (f *FakeClient) listMemberships(max int, params *MembershipListParams) ([]*Membership, error) {
+	if params == nil {
+		params = &MembershipListParams{}
+	}
+
+	memberships := []*Membership{}
+	for _, m := range f.memberships {
+		if params.RoomID != "" && m.RoomID != params.RoomID {
+			continue
+		}
+		if params.PersonID != "" && m.PersonID != params.PersonID {
+			continue
+		}
+		if params.PersonEmail != "" && !strings.EqualFold(m.PersonEmail, params.PersonEmail) {
+			continue
+		}
+		if params.ModeratorsOnly && !m.IsModerator {
+			continue
+		}
+		memberships = append(memberships, m.Clone())
+	}
+	return memberships[:limit(len(memberships), max)], nil
+}
+
+// Must be called with the lock held.
+func (f *FakeClient) roomMemberships(roomID string, moderatorsOnly bool) []*Membership {
+	memberships := []*Membership{}
+	for _, m := range f.memberships {
+		if m.RoomID != roomID || (moderatorsOnly && !m.IsModerator) {
+			continue
+		}
+		memberships = append(memberships, m.Clone())
+	}
+	return memberships
+}
+
+func (f *FakeClient) GetMessage(messageID string) (*Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetMessage"]; err != nil {
+		return nil, err
+	}
+	return f.getMessage(messageID)
+}
+
+// Must be called with the lock held.
+func (f *FakeClient) getMessage(messageID string) (*Message, error) {
+	if messageID == "" {
+		return nil, fmt.Errorf("no message ID specified")
+	}
+	for _, m := range f.messages {
+		if m.ID == messageID {
+			return m.Clone(), nil
+		}
+	}
+	return nil, notFound("message", messageID)
+}
+
+func (f *FakeClient) GetMessageRaw(messageID string) (json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetMessageRaw"]; err != nil {
+		return nil, err
+	}
+	m, err := f.getMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+func (f *FakeClient) ListMessages(max int, roomID string, params *MessageListParams) ([]*Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListMessages"]; err != nil {
+		return nil, err
+	}
+	return f.listMessages(max, roomID, params)
+}
+
+func (f *FakeClient) ListMessagesSingle(max int, roomID string, params *MessageListParams) ([]*Message, Links, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListMessagesSingle"]; err != nil {
+		return nil, Links{}, err
+	}
+	messages, err := f.listMessages(max, roomID, params)
+	return messages, Links{}, err
+}
+
+func (f *FakeClient) ListMessagesTruncated(max int, roomID string, params *MessageListParams) ([]*Message, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListMessagesTruncated"]; err != nil {
+		return nil, false, err
+	}
+	messages, err := f.listMessages(0, roomID, params)
+	if err != nil {
+		return nil, false, err
+	}
+	n := limit(len(messages), max)
+	return messages[:n], n < len(messages), nil
+}
+
+// Direct messages with a person are the ones in the direct room shared with them, if there is one.
+func (f *FakeClient) listMessages(max int, roomID string, params *MessageListParams) ([]*Message, error) {
+	if err := params.validate(roomID); err != nil {
+		return nil, err
+	}
+	if params.direct() {
+		roomID = f.findDirectRoom(params.PersonID, params.PersonEmail)
+		if roomID == "" {
+			return []*Message{}, nil
+		}
+	}
+	if params == nil {
+		params = &MessageListParams{}
+	}
+
+	messages := []*Message{}
+	seenBefore := params.BeforeMessageID == ""
+	for _, m := range f.messages {
+		if !seenBefore {
+			seenBefore = m.ID == params.BeforeMessageID
+			continue
+		}
+		if m.RoomID != roomID {
+			continue
+		}
+		if params.ParentID != "" && m.ParentID != params.ParentID {
+			continue
+		}
+		if !params.Before.IsZero() && !m.Created.Before(params.Before) {
+			continue
+		}
+		if !params.After.IsZero() && m.Created.Before(params.After) {
+			continue
+		}
+		messages = append(messages, m.Clone())
+	}
+	return messages[:limit(len(messages), max)], nil
+}
+
+func (f *FakeClient) ListMessagesBetween(roomID string, from, to time.Time) ([]*Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListMessagesBetween"]; err != nil {
+		return nil, err
+	}
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+	if !to.IsZero() && to.Before(from) {
+		return nil, fmt.Errorf("end of time window is before its start")
+	}
+
+	messages := []*Message{}
+	for _, m := range f.messages {
+		if m.RoomID != roomID || m.Created.Before(from) || (!to.IsZero() && !m.Created.Before(to)) {
+			continue
+		}
+		messages = append(messages, m.Clone())
+	}
+	return messages, nil
+}
+
+// The fake's messages don't record who they mention, so a message mentions the fake's me if its markdown does, ex.
+// through Mention.
+func (f *FakeClient) ListMyMentions(roomID string, since time.Time) ([]*Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListMyMentions"]; err != nil {
+		return nil, err
+	}
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+
+	mentions := []string{fmt.Sprintf("<@personId:%s", f.me.ID)}
+	for _, email := range f.me.Emails {
+		mentions = append(mentions, MentionEmail(email))
+	}
+
+	messages := []*Message{}
+	for _, m := range f.messages {
+		if m.RoomID != roomID || m.Created.Before(since) || !containsAny(m.Markdown, mentions) {
+			continue
+		}
+		messages = append(messages, m.Clone())
+	}
+	return messages, nil
+}
+
+// Reports whether s contains any of the substrings.
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FakeClient) GetThread(messageID string) (*Message, []*Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetThread"]; err != nil {
+		return nil, nil, err
+	}
+	parent, err := f.getMessage(messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parent.ParentID != "" {
+		if parent, err = f.getMessage(parent.ParentID); err != nil {
+			return nil, nil, err
+		}
+	}
+	replies, err := f.listMessages(0, parent.RoomID, &MessageListParams{ParentID: parent.ID})
+	if err != nil {
+		return nil, nil, err
+	}
+	return parent, replies, nil
+}
+
+func (f *FakeClient) FindMessages(roomID string, match func(m *Message) bool, limit int) ([]*Message, error) {
+	f.mu.Lock()
+	if err := f.errors["FindMessages"]; err != nil {
+		f.mu.Unlock()
+		return nil, err
+	}
+	messages, err := f.listMessages(0, roomID, nil)
+	f.mu.Unlock() // match may call back into the fake
+	if err != nil {
+		return nil, err
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no match function specified")
+	}
+
+	found := []*Message{}
+	for _, m := range messages {
+		if match(m) {
+			found = append(found, m)
+			if limit > 0 && len(found) >= limit {
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+func (f *FakeClient) ListAllRoomMessages(since time.Time) (map[string][]*Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListAllRoomMessages"]; err != nil {
+		return nil, err
+	}
+
+	messages := make(map[string][]*Message)
+	for _, m := range f.messages {
+		if m.Created.Before(since) {
+			continue
+		}
+		messages[m.RoomID] = append(messages[m.RoomID], m.Clone())
+	}
+	return messages, nil
+}
+
+// Messages sent directly to a person rather than to a room are stored in a direct room shared with that person, which
+// is created the first time it's needed.
+func (f *FakeClient) CreateMessage(m *NewMessage) (*Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["CreateMessage"]; err != nil {
+		return nil, err
+	}
+	return f.createMessage(m)
+}
+
+// Like the real client, messages created with an idempotency key that has already been used are returned again
+// instead of being created twice.
+func (f *FakeClient) CreateMessageWithOptions(m *NewMessage, opts *CreateMessageOptions) (*Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["CreateMessageWithOptions"]; err != nil {
+		return nil, err
+	}
+	if opts == nil || opts.IdempotencyKey == "" {
+		return f.createMessage(m)
+	}
+
+	if id, ok := f.idempotencyKeys[opts.IdempotencyKey]; ok {
+		if msg, err := f.getMessage(id); err == nil {
+			return msg, nil
+		}
+	}
+	msg, err := f.createMessage(m)
+	if err != nil {
+		return nil, err
+	}
+	if f.idempotencyKeys == nil {
+		f.idempotencyKeys = make(map[string]string)
+	}
+	f.idempotencyKeys[opts.IdempotencyKey] = msg.ID
+	return msg, nil
+}
+
+// The fake doesn't store the uploaded content.  Each upload is linked from the message's Files by a made up URL instead.
+func (f *FakeClient) CreateMessageWithFiles(m *NewMessage, files ...*FileUpload) (*Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["CreateMessageWithFiles"]; err != nil {
+		return nil, err
+	}
+	if err := validateUploads(files); err != nil {
+		return nil, err
+	}
+	if m == nil || len(files) == 0 {
+		return f.createMessage(m)
+	}
+
+	cp := *m
+	cp.Files = append(append([]string(nil), m.Files...), fmt.Sprintf("%s/contents/%s", BaseURL, f.newID()))
+	return f.createMessage(&cp)
+}
+
+// Must be called with the lock held.
+func (f *FakeClient) createMessage(m *NewMessage) (*Message, error) {
+	if m == nil {
+		return nil, fmt.Errorf("nil message")
+	}
+	if m.RoomID == "" && m.ToPersonEmail == "" && m.ToPersonID == "" {
+		return nil, fmt.Errorf("message requires a room ID, person ID, or email to send to")
+	}
+	if !m.hasContent() {
+		return nil, fmt.Errorf("message has no content")
+	}
+
+	roomID, roomType := m.RoomID, RoomTypeGroup
+	if roomID == "" {
+		roomID, roomType = f.directRoom(m.ToPersonID, m.ToPersonEmail), RoomTypeDirect
+	} else if _, err := f.getRoom(roomID); err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		ID:               f.newID(),
+		RoomID:           roomID,
+		RoomType:         roomType,
+		PersonID:         f.me.ID,
+		PersonEmail:      firstEmail(f.me),
+		Text:             m.Text,
+		Markdown:         m.Markdown,
+		Files:            append([]string(nil), m.Files...),
+		ClassificationID: m.ClassificationID,
+		ParentID:         m.ParentID,
+		Created:          Time{time.Now()},
+	}
+	f.messages = append([]*Message{msg}, f.messages...)
+	for _, r := range f.rooms {
+		if r.ID == roomID {
+			r.LastActivity = msg.Created
+		}
+	}
+
+	return msg.Clone(), nil
+}
+
+// Returns the ID of the direct room shared with the given person, creating it if necessary.  Must be called with the
+// lock held.
+func (f *FakeClient) directRoom(personID, personEmail string) string {
+	if id := f.findDirectRoom(personID, personEmail); id != "" {
+		return id
+	}
+	return f.createRoom(&Room{Title: directRoomTitle(personID, personEmail), Type: RoomTypeDirect}).ID
+}
+
+// Returns the ID of the direct room shared with the given person, or "" if there isn't one.  Must be called with the
+// lock held.
+func (f *FakeClient) findDirectRoom(personID, personEmail string) string {
+	title := directRoomTitle(personID, personEmail)
+	for _, r := range f.rooms {
+		if r.Type == RoomTypeDirect && r.Title == title {
+			return r.ID
+		}
+	}
+	return ""
+}
+
+// The fake titles direct rooms after the person they're shared with.
+func directRoomTitle(personID, personEmail string) string {
+	if personID != "" {
+		return personID
+	}
+	return personEmail
+}
+
+func (f *FakeClient) UpdateMessage(messageID string, m *NewMessage) (*Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["UpdateMessage"]; err != nil {
+		return nil, err
+	}
+	if messageID == "" {
+		return nil, fmt.Errorf("no message ID specified")
+	}
+	if m == nil {
+		return nil, fmt.Errorf("nil message")
+	}
+	if m.RoomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+	if !m.hasContent() {
+		return nil, fmt.Errorf("message has no content")
+	}
+	if m.Text == "" && m.Markdown == "" {
+		return nil, fmt.Errorf("message requires text or markdown")
+	}
+
+	for _, existing := range f.messages {
+		if existing.ID == messageID && existing.RoomID == m.RoomID {
+			existing.Text = m.Text
+			existing.Markdown = m.Markdown
+			return existing.Clone(), nil
+		}
+	}
+	return nil, notFound("message", messageID)
+}
+
+func (f *FakeClient) DeleteMessage(messageID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["DeleteMessage"]; err != nil {
+		return err
+	}
+	if messageID == "" {
+		return fmt.Errorf("no message ID specified")
+	}
+
+	for i, m := range f.messages {
+		if m.ID == messageID {
+			f.messages = append(f.messages[:i], f.messages[i+1:]...)
+			return nil
+		}
+	}
+	return notFound("message", messageID)
+}
+
+func (f *FakeClient) DeleteOwnMessage(messageID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["DeleteOwnMessage"]; err != nil {
+		return err
+	}
+	if messageID == "" {
+		return fmt.Errorf("no message ID specified")
+	}
+
+	for i, m := range f.messages {
+		if m.ID == messageID {
+			if m.PersonID != f.me.ID {
+				return fmt.Errorf("message %q was not sent by the client's identity, refusing to delete it", messageID)
+			}
+			f.messages = append(f.messages[:i], f.messages[i+1:]...)
+			return nil
+		}
+	}
+	return notFound("message", messageID)
+}
+
+func (f *FakeClient) DeleteOwnMessagesInRoom(roomID string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["DeleteOwnMessagesInRoom"]; err != nil {
+		return 0, err
+	}
+	if roomID == "" {
+		return 0, fmt.Errorf("no room ID specified")
+	}
+
+	kept := f.messages[:0]
+	for _, m := range f.messages {
+		if m.RoomID != roomID || m.PersonID != f.me.ID {
+			kept = append(kept, m)
+		}
+	}
+	deleted := len(f.messages) - len(kept)
+	f.messages = kept
+	return deleted, nil
+}
+
+func (f *FakeClient) GetWebhook(webhookID string) (*Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetWebhook"]; err != nil {
+		return nil, err
+	}
+	return f.getWebhook(webhookID)
+}
+
+// Must be called with the lock held.
+func (f *FakeClient) getWebhook(webhookID string) (*Webhook, error) {
+	if webhookID == "" {
+		return nil, fmt.Errorf("no webhook ID specified")
+	}
+	for _, w := range f.webhooks {
+		if w.ID == webhookID {
+			return w.Clone(), nil
+		}
+	}
+	return nil, notFound("webhook", webhookID)
+}
+
+func (f *FakeClient) GetWebhookRaw(webhookID string) (json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetWebhookRaw"]; err != nil {
+		return nil, err
+	}
+	w, err := f.getWebhook(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(w)
+}
+
+func (f *FakeClient) ListWebhooks(max int) ([]*Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListWebhooks"]; err != nil {
+		return nil, err
+	}
+	return f.listWebhooks(max, false)
+}
+
+func (f *FakeClient) ListOrgWebhooks(max int) ([]*Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListOrgWebhooks"]; err != nil {
+		return nil, err
+	}
+	return f.listWebhooks(max, true)
+}
+
+func (f *FakeClient) ListWebhooksSingle(max int) ([]*Webhook, Links, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["ListWebhooksSingle"]; err != nil {
+		return nil, Links{}, err
+	}
+	webhooks, err := f.listWebhooks(max, false)
+	return webhooks, Links{}, err
+}
+
+// Lists either the org-wide webhooks, or the rest, like the real API does.
+func (f *FakeClient) listWebhooks(max int, org bool) ([]*Webhook, error) {
+	webhooks := []*Webhook{}
+	for _, w := range f.webhooks {
+		if (w.OwnedBy == "org") != org {
+			continue
+		}
+		webhooks = append(webhooks, w.Clone())
+	}
+	return webhooks[:limit(len(webhooks), max)], nil
+}
+
+func (f *FakeClient) CreateWebhook(w *NewWebhook) (*Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["CreateWebhook"]; err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, fmt.Errorf("nil webhook")
+	}
+	if w.Name == "" {
+		return nil, fmt.Errorf("no webhook name specified")
+	}
+	if err := validateTargetURL(w.TargetURL); err != nil {
+		return nil, err
+	}
+	if w.Resource == "" {
+		return nil, fmt.Errorf("no webhook resource specified")
+	}
+	if w.Event == "" {
+		return nil, fmt.Errorf("no webhook event specified")
+	}
+	if err := validateWebhookOwner(w.OwnedBy); err != nil {
+		return nil, err
+	}
+
+	wh := &Webhook{
+		ID:        f.newID(),
+		Name:      w.Name,
+		TargetURL: w.TargetURL,
+		Resource:  w.Resource,
+		Event:     w.Event,
+		Filter:    w.Filter,
+		Secret:    w.Secret,
+		OrgID:     f.me.OrgId,
+		CreatedBy: f.me.ID,
+		OwnedBy:   w.OwnedBy,
+		Status:    WebhookActive,
+	}
+	if wh.OwnedBy == "" {
+		wh.OwnedBy = "creator"
+	}
+	f.webhooks = append(f.webhooks, wh)
+
+	return wh.Clone(), nil
+}
+
+func (f *FakeClient) UpdateWebhook(w *Webhook) (*Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["UpdateWebhook"]; err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, fmt.Errorf("nil webhook")
+	}
+	if w.ID == "" {
+		return nil, fmt.Errorf("no webhook ID specified")
+	}
+	if w.Name == "" {
+		return nil, fmt.Errorf("no webhook name specified")
+	}
+	if err := validateTargetURL(w.TargetURL); err != nil {
+		return nil, err
+	}
+
+	for i, existing := range f.webhooks {
+		if existing.ID == w.ID {
+			cp := w.Clone()
+			f.webhooks[i] = cp
+
+			return cp.Clone(), nil
+		}
+	}
+	return nil, notFound("webhook", w.ID)
+}
+
+func (f *FakeClient) DeleteWebhook(hookID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["DeleteWebhook"]; err != nil {
+		return err
+	}
+	if hookID == "" {
+		return fmt.Errorf("no webhook ID specified")
+	}
+
+	for i, w := range f.webhooks {
+		if w.ID == hookID {
+			f.webhooks = append(f.webhooks[:i], f.webhooks[i+1:]...)
+			return nil
+		}
+	}
+	return notFound("webhook", hookID)
+}
+
+// Like the real client, only the webhooks that ListWebhooks lists are searched.
+func (f *FakeClient) FindWebhooksByTarget(targetURL string) ([]*Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["FindWebhooksByTarget"]; err != nil {
+		return nil, err
+	}
+	if targetURL == "" {
+		return nil, fmt.Errorf("no webhook target URL specified")
+	}
+
+	webhooks, err := f.listWebhooks(0, false)
+	if err != nil {
+		return nil, err
+	}
+	return webhooksByTarget(webhooks, targetURL), nil
+}
+
+func (f *FakeClient) DeleteWebhooksByTarget(targetURL string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["DeleteWebhooksByTarget"]; err != nil {
+		return 0, err
+	}
+	if targetURL == "" {
+		return 0, fmt.Errorf("no webhook target URL specified")
+	}
+
+	var kept []*Webhook
+	deleted := 0
+	for _, w := range f.webhooks {
+		if w.TargetURL == targetURL && w.OwnedBy != "org" {
+			deleted++
+			continue
+		}
+		kept = append(kept, w)
+	}
+	f.webhooks = kept
+	return deleted, nil
+}
+
+// The fake doesn't send any requests, so it only validates the URL.
+func (f *FakeClient) PingWebhookTarget(targetURL string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["PingWebhookTarget"]; err != nil {
+		return err
+	}
+	return validateTargetURL(targetURL)
+}
+
+func (f *FakeClient) GetAttachmentAction(actionID string) (*AttachmentAction, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["GetAttachmentAction"]; err != nil {
+		return nil, err
+	}
+	return f.getAttachmentAction(actionID)
+}
+
+// Must be called with the lock held.
+func (f *FakeClient) getAttachmentAction(actionID string) (*AttachmentAction, error) {
+	if actionID == "" {
+		return nil, fmt.Errorf("no attachment action ID specified")
+	}
+	for _, a := range f.actions {
+		if a.ID == actionID {
+			cp := *a
+			return &cp, nil
+		}
+	}
+	return nil, notFound("attachment action", actionID)
+}
+
+func (f *FakeClient) HandleCardSubmit(event *WebhookEvent) (*AttachmentAction, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errors["HandleCardSubmit"]; err != nil {
+		return nil, err
+	}
+	id, err := cardSubmitID(event)
+	if err != nil {
+		return nil, err
+	}
+	return f.getAttachmentAction(id)
+}
@@ -0,0 +1,418 @@
+package spark
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FakeClient", func() {
+	var f *FakeClient
+
+	BeforeEach(func() {
+		f = NewFakeClient(&Person{ID: "me", Emails: []string{"me@world.com"}, DisplayName: "Me"})
+	})
+
+	It("returns its identity from GetMyself", func() {
+		me, err := f.GetMyself()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(me.ID).To(Equal("me"))
+
+		p, err := f.GetPersonByEmail("ME@world.com")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(p).To(Equal(me))
+	})
+
+	It("lists messages it created, newest first", func() {
+		room, err := f.CreateRoom("room", "")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		first, err := f.CreateMessage(&NewMessage{RoomID: room.ID, Text: "first"})
+		Expect(err).ShouldNot(HaveOccurred())
+		second, err := f.CreateMessage(&NewMessage{RoomID: room.ID, Text: "second"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(second.PersonID).To(Equal("me"))
+
+		messages, err := f.ListMessages(0, room.ID, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(messages).To(Equal([]*Message{second, first}))
+
+		messages, err = f.ListMessages(1, room.ID, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(messages).To(Equal([]*Message{second}))
+
+		self, err := f.IsSelfAuthored(context.Background(), first)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(self).To(BeTrue())
+	})
+
+	It("threads replies", func() {
+		room, err := f.CreateRoom("room", "")
+		Expect(err).ShouldNot(HaveOccurred())
+		parent, err := f.CreateMessage(&NewMessage{RoomID: room.ID, Text: "question"})
+		Expect(err).ShouldNot(HaveOccurred())
+		reply, err := f.CreateMessage(&NewMessage{RoomID: room.ID, ParentID: parent.ID, Text: "answer"})
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = f.CreateMessage(&NewMessage{RoomID: room.ID, Text: "unrelated"})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		p, replies, err := f.GetThread(reply.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(p).To(Equal(parent))
+		Expect(replies).To(Equal([]*Message{reply}))
+	})
+
+	It("doesn't create a message twice with the same idempotency key", func() {
+		opts := &CreateMessageOptions{IdempotencyKey: "key"}
+		m1, err := f.CreateMessageWithOptions(&NewMessage{ToPersonID: "you", Text: "hi"}, opts)
+		Expect(err).ShouldNot(HaveOccurred())
+		m2, err := f.CreateMessageWithOptions(&NewMessage{ToPersonID: "you", Text: "hi"}, opts)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(m2).To(Equal(m1))
+
+		messages, err := f.ListMessages(0, m1.RoomID, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(messages).To(HaveLen(1))
+	})
+
+	It("creates a direct room for messages sent to a person", func() {
+		m1, err := f.CreateMessage(&NewMessage{ToPersonEmail: "you@world.com", Text: "hi"})
+		Expect(err).ShouldNot(HaveOccurred())
+		m2, err := f.CreateMessage(&NewMessage{ToPersonEmail: "you@world.com", Text: "again"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(m2.RoomID).To(Equal(m1.RoomID))
+		Expect(m1.RoomType).To(Equal("direct"))
+
+		rooms, err := f.ListRooms(0, &RoomListParams{Type: "direct"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(rooms).To(HaveLen(1))
+	})
+
+	It("lists direct messages by person", func() {
+		m, err := f.CreateMessage(&NewMessage{ToPersonEmail: "you@world.com", Text: "hi"})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		messages, err := f.ListMessages(0, "", &MessageListParams{PersonEmail: "you@world.com"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(messages).To(Equal([]*Message{m}))
+
+		messages, err = f.ListMessages(0, "", &MessageListParams{PersonEmail: "other@world.com"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(messages).To(BeEmpty())
+	})
+
+	It("lists the messages that mention its identity", func() {
+		room, err := f.CreateRoom("room", "")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = f.CreateMessage(&NewMessage{RoomID: room.ID, Text: "hi"})
+		Expect(err).ShouldNot(HaveOccurred())
+		byID, err := f.CreateMessage((&NewMessage{RoomID: room.ID}).WithMention(&Person{ID: "me"}))
+		Expect(err).ShouldNot(HaveOccurred())
+		byEmail, err := f.CreateMessage(&NewMessage{RoomID: room.ID, Markdown: MentionEmail("me@world.com") + " hi"})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		mentions, err := f.ListMyMentions(room.ID, time.Time{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mentions).To(ConsistOf(byID, byEmail))
+
+		mentions, err = f.ListMyMentions(room.ID, time.Now().Add(time.Hour))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mentions).To(BeEmpty())
+	})
+
+	It("links uploaded files from the messages they're sent with", func() {
+		room, err := f.CreateRoom("room", "")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		m, err := f.CreateMessageWithFiles(&NewMessage{RoomID: room.ID, Files: []string{"https://example.com/a.png"}},
+			&FileUpload{Name: "b.txt", Content: strings.NewReader("b")})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(m.Files).To(HaveLen(2))
+		Expect(m.Files[0]).To(Equal("https://example.com/a.png"))
+
+		_, err = f.CreateMessageWithFiles(&NewMessage{RoomID: room.ID}, &FileUpload{Name: "a", Content: strings.NewReader("a")},
+			&FileUpload{Name: "b", Content: strings.NewReader("b")})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("updates the named fields of people", func() {
+		p, err := f.CreatePerson(&Person{Emails: []string{"a@world.com"}, DisplayName: "old", Roles: []string{"role"}})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		updated, err := f.UpdatePersonFields(p.ID, map[string]interface{}{"displayName": "new"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(updated.DisplayName).To(Equal("new"))
+		Expect(updated.Roles).To(Equal([]string{"role"}))
+		Expect(f.GetPerson(p.ID)).To(Equal(updated))
+
+		_, err = f.UpdatePersonFields("missing", map[string]interface{}{"displayName": "new"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("sets avatars", func() {
+		p, err := f.CreatePerson(&Person{Emails: []string{"a@world.com"}})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		updated, err := f.SetPersonAvatar(p.ID, strings.NewReader("image"), "image/png")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(updated.Avatar).ToNot(BeEmpty())
+		Expect(f.GetPerson(p.ID)).To(Equal(updated))
+
+		_, err = f.SetPersonAvatar(p.ID, strings.NewReader("text"), "text/plain")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("creates people in bulk", func() {
+		people, err := f.CreatePeople(context.Background(), []*Person{{Emails: []string{"a@world.com"}}, {Emails: []string{"b@world.com"}}}, 2)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(people).To(HaveLen(2))
+		Expect(f.GetPersonByEmail("b@world.com")).To(Equal(people[1]))
+
+		_, err = f.CreatePeople(context.Background(), []*Person{{}}, 2)
+		Expect(err).To(MatchError("person 0: no email specified"))
+	})
+
+	It("tracks room memberships", func() {
+		room, err := f.CreateRoom("room", "")
+		Expect(err).ShouldNot(HaveOccurred())
+		f.AddMembership(&Membership{RoomID: room.ID, PersonID: "you"})
+
+		count, err := f.CountRoomMembers(room.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(count).To(Equal(2))
+
+		mods, err := f.ListRoomModerators(room.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mods).To(HaveLen(1))
+		Expect(mods[0].PersonID).To(Equal("me"))
+	})
+
+	It("reports the read status of a room", func() {
+		room, err := f.CreateRoom("room", "")
+		Expect(err).ShouldNot(HaveOccurred())
+		f.AddMembership(&Membership{RoomID: room.ID, PersonID: "you", LastSeenID: "message"})
+
+		status, err := f.RoomReadStatus(room.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(status).To(Equal(map[string]string{"me": "", "you": "message"}))
+	})
+
+	It("lists its own memberships", func() {
+		room, err := f.CreateRoom("room", "")
+		Expect(err).ShouldNot(HaveOccurred())
+		f.AddMembership(&Membership{RoomID: room.ID, PersonID: "you"})
+
+		mine, err := f.ListMyMemberships()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mine).To(HaveLen(1))
+		Expect(mine[0].RoomID).To(Equal(room.ID))
+		Expect(mine[0].PersonID).To(Equal("me"))
+	})
+
+	It("creates memberships", func() {
+		room, err := f.CreateRoom("room", "")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		m, err := f.CreateMembership(&NewMembership{RoomID: room.ID, PersonEmail: "you@world.com", IsModerator: true})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(m.IsModerator).To(BeTrue())
+
+		mods, err := f.ListRoomModerators(room.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mods).To(HaveLen(2))
+
+		_, err = f.CreateMembership(&NewMembership{RoomID: "nope", PersonID: "you"})
+		Expect(IsNotFound(err)).To(BeTrue())
+	})
+
+	It("updates and deletes resources", func() {
+		hook, err := f.CreateWebhook(&NewWebhook{Name: "hook", TargetURL: "https://example.com/hook", Resource: "messages", Event: "created"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(hook.Status).To(Equal(WebhookActive))
+
+		hook.Name = "renamed"
+		_, err = f.UpdateWebhook(hook)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		got, err := f.GetWebhook(hook.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(got.Name).To(Equal("renamed"))
+
+		Expect(f.DeleteWebhook(hook.ID)).To(Succeed())
+		_, err = f.GetWebhook(hook.ID)
+		Expect(IsNotFound(err)).To(BeTrue())
+	})
+
+	It("finds and deletes webhooks by target URL", func() {
+		for _, target := range []string{"https://example.com/old", "https://example.com/new", "https://example.com/old"} {
+			_, err := f.CreateWebhook(&NewWebhook{Name: "hook", TargetURL: target, Resource: "messages", Event: "created"})
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+
+		Expect(f.FindWebhooksByTarget("https://example.com/old")).To(HaveLen(2))
+		Expect(f.DeleteWebhooksByTarget("https://example.com/old")).To(Equal(2))
+		Expect(f.FindWebhooksByTarget("https://example.com/old")).To(BeEmpty())
+		Expect(f.ListWebhooks(0)).To(HaveLen(1))
+	})
+
+	It("deletes its own messages in a room", func() {
+		room, err := f.CreateRoom("room", "")
+		Expect(err).ShouldNot(HaveOccurred())
+		other, err := f.CreateRoom("other", "")
+		Expect(err).ShouldNot(HaveOccurred())
+		for _, roomID := range []string{room.ID, room.ID, other.ID} {
+			_, err := f.CreateMessage(&NewMessage{RoomID: roomID, Text: "mine"})
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		f.messages = append(f.messages, &Message{ID: "theirs", RoomID: room.ID, PersonID: "someone"})
+
+		Expect(f.DeleteOwnMessagesInRoom(room.ID)).To(Equal(2))
+		Expect(f.ListMessages(0, room.ID, nil)).To(ConsistOf(HaveField("ID", "theirs")))
+		Expect(f.ListMessages(0, other.ID, nil)).To(HaveLen(1))
+	})
+
+	It("lists direct and group rooms separately", func() {
+		group, err := f.CreateRoom("room", "")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = f.CreateMessage(&NewMessage{ToPersonEmail: "you@world.com", Text: "hi"})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(f.ListGroupRooms(0)).To(ConsistOf(HaveField("ID", group.ID)))
+		Expect(f.ListDirectRooms()).To(ConsistOf(HaveField("Type", RoomTypeDirect)))
+		_, err = f.ListRooms(0, &RoomListParams{Type: "team"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("lists rooms by recent activity", func() {
+		_, err := f.CreateRoom("quiet", "")
+		Expect(err).ShouldNot(HaveOccurred())
+		busy, err := f.CreateRoom("busy", "")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		time.Sleep(time.Millisecond)
+		since := time.Now()
+		_, err = f.CreateMessage(&NewMessage{RoomID: busy.ID, Text: "hi"})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		rooms, err := f.ListActiveRooms(since)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(rooms).To(HaveLen(1))
+		Expect(rooms[0].ID).To(Equal(busy.ID))
+	})
+
+	It("handles card submissions", func() {
+		added := f.AddAttachmentAction(&AttachmentAction{Type: "submit", Inputs: map[string]interface{}{"choice": "yes"}})
+		data, err := json.Marshal(&AttachmentAction{ID: added.ID})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		a, err := f.HandleCardSubmit(&WebhookEvent{Resource: "attachmentActions", Event: "created", Data: data})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(a).To(Equal(added))
+
+		_, err = f.GetAttachmentAction("nope")
+		Expect(IsNotFound(err)).To(BeTrue())
+	})
+
+	It("returns copies, not its stored resources", func() {
+		room, err := f.CreateRoom("room", "")
+		Expect(err).ShouldNot(HaveOccurred())
+		room.Title = "changed"
+
+		got, err := f.GetRoom(room.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(got.Title).To(Equal("room"))
+
+		me, err := f.GetMyself()
+		Expect(err).ShouldNot(HaveOccurred())
+		email := me.Emails[0]
+		me.Emails[0] = "changed@example.com"
+		me, err = f.GetMyself()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(me.Emails).To(Equal([]string{email}))
+
+		files := []string{"https://example.com/a.png"}
+		m, err := f.CreateMessage(&NewMessage{RoomID: room.ID, Files: files})
+		Expect(err).ShouldNot(HaveOccurred())
+		files[0] = "changed"
+		m.Files[0] = "changed"
+		msgs, err := f.ListMessages(0, room.ID, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(msgs).To(HaveLen(1))
+		Expect(msgs[0].Files).To(Equal([]string{"https://example.com/a.png"}))
+
+		hook, err := f.CreateWebhook(&NewWebhook{Name: "hook", TargetURL: "https://example.com/hook", Resource: "messages", Event: "created"})
+		Expect(err).ShouldNot(HaveOccurred())
+		hook.Data = map[string]interface{}{"key": "value"}
+		hook, err = f.UpdateWebhook(hook)
+		Expect(err).ShouldNot(HaveOccurred())
+		hook.Data["key"] = "changed"
+		got2, err := f.GetWebhook(hook.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(got2.Data).To(Equal(map[string]interface{}{"key": "value"}))
+	})
+
+	It("reports unchanged rooms by ETag", func() {
+		room, err := f.CreateRoom("room", "")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		_, etag, changed, err := f.GetRoomIfChanged(room.ID, "")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+
+		_, _, changed, err = f.GetRoomIfChanged(room.ID, etag)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(changed).To(BeFalse())
+
+		_, err = f.UpdateRoomName(room.ID, "renamed")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, _, changed, err = f.GetRoomIfChanged(room.ID, etag)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+	})
+
+	It("returns a 404 APIError for missing resources", func() {
+		_, err := f.GetMessage("nope")
+		Expect(IsNotFound(err)).To(BeTrue())
+
+		_, err = f.CreateMessage(&NewMessage{RoomID: "nope", Text: "hi"})
+		Expect(IsNotFound(err)).To(BeTrue())
+
+		Expect(IsNotFound(f.DeleteRoom("nope"))).To(BeTrue())
+	})
+
+	It("returns the not found sentinels from lookups by name and email", func() {
+		_, err := f.GetRoomByName("nope")
+		Expect(errors.Is(err, ErrRoomNotFound)).To(BeTrue())
+
+		_, err = f.GetPersonByEmail("nope@world.com")
+		Expect(errors.Is(err, ErrPersonNotFound)).To(BeTrue())
+	})
+
+	It("injects errors per method", func() {
+		f.SetError("CreateMessage", mockErr)
+
+		_, err := f.CreateMessage(&NewMessage{ToPersonID: "you", Text: "hi"})
+		Expect(err).To(Equal(mockErr))
+
+		_, err = f.ListRooms(0, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		f.SetError("CreateMessage", nil)
+		_, err = f.CreateMessage(&NewMessage{ToPersonID: "you", Text: "hi"})
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("validates like the real client", func() {
+		_, err := f.GetRoom("")
+		Expect(err).To(MatchError(fmt.Errorf("no room ID specified")))
+
+		_, err = f.CreateMessage(&NewMessage{Text: "hi"})
+		Expect(err).To(MatchError(fmt.Errorf("message requires a room ID, person ID, or email to send to")))
+	})
+})
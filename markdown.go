@@ -0,0 +1,62 @@
+package spark
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdFence      = regexp.MustCompile("(?m)^\\s*```.*$\n?")
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink       = regexp.MustCompile(`\[([^\]]*)\]\(([^)]*)\)`)
+	mdHeader     = regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+`)
+	mdQuote      = regexp.MustCompile(`(?m)^\s{0,3}>\s?`)
+	mdBullet     = regexp.MustCompile(`(?m)^(\s*)[*+]\s+`)
+	mdStrong     = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)??)(\*\*|__)`)
+	mdEmphasis   = regexp.MustCompile(`(^|[^\w*])[*_](\S(?:.*?\S)??)[*_]([^\w*]|$)`)
+	mdStrike     = regexp.MustCompile(`~~(.+?)~~`)
+	mdInlineCode = regexp.MustCompile("`([^`]*)`")
+
+	htmlBreak  = regexp.MustCompile(`(?i)<br\s*/?>|</(?:p|div|li|h[1-6]|blockquote|pre|tr)>`)
+	htmlTag    = regexp.MustCompile(`<[^>]*>`)
+	blankLines = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// Strips the common markdown syntax out of s, leaving a plain text approximation of what it would render as.  This
+// isn't a full markdown parser, just enough to produce a readable fallback for clients that can't render markdown.
+// Links are rendered as "text (url)", and bullets are normalized to "-".
+func stripMarkdown(s string) string {
+	s = mdFence.ReplaceAllString(s, "")
+	s = mdImage.ReplaceAllString(s, "$1")
+	s = mdLink.ReplaceAllStringFunc(s, func(l string) string {
+		m := mdLink.FindStringSubmatch(l)
+		if m[1] == "" || m[1] == m[2] {
+			return m[2]
+		}
+		return m[1] + " (" + m[2] + ")"
+	})
+	s = mdHeader.ReplaceAllString(s, "")
+	s = mdQuote.ReplaceAllString(s, "")
+	s = mdBullet.ReplaceAllString(s, "$1- ")
+	s = mdStrong.ReplaceAllString(s, "$2")
+	// Each emphasis match includes the characters around its markers, so spans only a character apart (ex. "*a* *b*")
+	// can't both match in one pass
+	for prev := ""; prev != s; {
+		prev = s
+		s = mdEmphasis.ReplaceAllString(s, "$1$2$3")
+	}
+	s = mdStrike.ReplaceAllString(s, "$1")
+	s = mdInlineCode.ReplaceAllString(s, "$1")
+	return strings.TrimSpace(s)
+}
+
+// Strips the tags out of s, leaving the text it would render as.  Like stripMarkdown, this is only an approximation:
+// line breaks and the ends of block elements become newlines, every other tag is dropped, and entities are decoded.
+func stripHTML(s string) string {
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	s = htmlTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = blankLines.ReplaceAllString(s, "\n")
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,55 @@
+package spark
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("stripMarkdown", func() {
+	cases := []struct {
+		name, markdown, text string
+	}{
+		{"plain text", "hello world", "hello world"},
+		{"headers", "# Big Message right here!", "Big Message right here!"},
+		{"bold and italic", "this is **bold**, __also bold__, *italic* and _also italic_", "this is bold, also bold, italic and also italic"},
+		{"several bold spans", "**a** and **b**, __c__ __d__", "a and b, c d"},
+		{"several italic spans", "*a* *b*, _x_ and _y_", "a b, x and y"},
+		{"snake_case words", "leave snake_case_words alone", "leave snake_case_words alone"},
+		{"strikethrough", "~~gone~~ here", "gone here"},
+		{"inline code", "run `go test`", "run go test"},
+		{"code fences", "```go\nfmt.Println()\n```", "fmt.Println()"},
+		{"links", "see [the docs](https://developer.webex.com)", "see the docs (https://developer.webex.com)"},
+		{"bare links", "[https://example.com](https://example.com)", "https://example.com"},
+		{"images", "![a cat](https://example.com/cat.png)", "a cat"},
+		{"block quotes", "> quoted\n> text", "quoted\ntext"},
+		{"bullets", "* one\n+ two\n- three", "- one\n- two\n- three"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		It("strips "+tc.name, func() {
+			Expect(stripMarkdown(tc.markdown)).To(Equal(tc.text))
+		})
+	}
+})
+
+var _ = Describe("stripHTML", func() {
+	cases := []struct {
+		name, html, text string
+	}{
+		{"plain text", "hello world", "hello world"},
+		{"inline tags", "this is <strong>bold</strong> and <em>italic</em>", "this is bold and italic"},
+		{"entities", "fish &amp; chips &lt;3", "fish & chips <3"},
+		{"paragraphs", "<p>one</p>\n<p>two</p>", "one\ntwo"},
+		{"line breaks", "one<br>two<BR />three", "one\ntwo\nthree"},
+		{"lists", "<ul><li>one</li><li>two</li></ul>", "one\ntwo"},
+		{"mentions", "<p><spark-mention data-object-type=\"person\" data-object-id=\"1\">Bot</spark-mention> hi</p>", "Bot hi"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		It("strips "+tc.name, func() {
+			Expect(stripHTML(tc.html)).To(Equal(tc.text))
+		})
+	}
+})
@@ -0,0 +1,255 @@
+package spark
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+const MembershipsURL = BaseURL + "/memberships"
+
+type Membership struct {
+	ID                string `json:"id,omitempty"`
+	RoomID            string `json:"roomId,omitempty"`
+	PersonID          string `json:"personId,omitempty"`
+	PersonEmail       string `json:"personEmail,omitempty"`
+	PersonDisplayName string `json:"personDisplayName,omitempty"`
+	PersonOrgID       string `json:"personOrgId,omitempty"`
+	IsModerator       bool   `json:"isModerator,omitempty"`
+	IsMonitor         bool   `json:"isMonitor,omitempty"`
+	Created           Time   `json:"created,omitempty"`
+
+	// The ID of the last message the person has seen in the room, and when they saw it.  These are only included when
+	// listing the memberships of a room, and are empty if the person hasn't seen any messages there yet.
+	LastSeenID   string `json:"lastSeenId,omitempty"`
+	LastSeenDate Time   `json:"lastSeenDate,omitempty"`
+}
+
+type MembershipList struct {
+	Items []*Membership
+}
+
+// NOTE: RoomID and one and *only* one of PersonID or PersonEmail must be set for calls to CreateMembership.
+type NewMembership struct {
+	RoomID      string `json:"roomId"`                // required
+	PersonID    string `json:"personId,omitempty"`    // optional
+	PersonEmail string `json:"personEmail,omitempty"` // optional
+	IsModerator bool   `json:"isModerator,omitempty"` // optional
+}
+
+func (m *NewMembership) validate() error {
+	if m == nil {
+		return fmt.Errorf("nil membership")
+	}
+	if m.RoomID == "" {
+		return fmt.Errorf("no room ID specified")
+	}
+	if m.PersonID == "" && m.PersonEmail == "" {
+		return fmt.Errorf("membership requires a person ID or email to add")
+	}
+	if m.PersonID != "" && m.PersonEmail != "" {
+		return fmt.Errorf("person ID and person email can't both be specified")
+	}
+	return nil
+}
+
+// https://developer.webex.com/endpoint-memberships-post.html
+//
+// Setting IsModerator adds the person as a moderator directly, rather than adding and then promoting them.
+func (c *client) CreateMembership(m *NewMembership) (*Membership, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	b, err := c.encode(m)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.postRequest(MembershipsURL, b)
+	if err != nil {
+		return nil, err
+	}
+
+	var rm Membership
+	err = c.unmarshal(resp, &rm)
+	return &rm, err
+}
+
+// GetMembership is a helper method that looks up a single person's membership in a room, without listing every
+// membership in the room.
+func (c *client) GetMembership(roomID, personID string) (*Membership, error) {
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+	if personID == "" {
+		return nil, fmt.Errorf("no person ID specified")
+	}
+
+	memberships, err := c.listMemberships(1, url.Values{"roomId": {roomID}, "personId": {personID}})
+	if err != nil {
+		return nil, err
+	}
+	if len(memberships) == 0 {
+		return nil, fmt.Errorf("no membership found for person %q in room %q", personID, roomID)
+	}
+	return memberships[0], nil
+}
+
+// CountRoomMembers is a helper method that pages through all of the memberships of a room, and returns how many there
+// are.
+func (c *client) CountRoomMembers(roomID string) (int, error) {
+	if roomID == "" {
+		return 0, fmt.Errorf("no room ID specified")
+	}
+
+	memberships, err := c.listMemberships(0, url.Values{"roomId": {roomID}})
+	if err != nil {
+		return 0, err
+	}
+	return len(memberships), nil
+}
+
+// ListRoomModerators is a helper method that pages through all of the memberships of a room, and returns only the
+// memberships of the room's moderators.
+func (c *client) ListRoomModerators(roomID string) ([]*Membership, error) {
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+
+	moderators, err := c.ListMemberships(0, &MembershipListParams{RoomID: roomID, ModeratorsOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return moderators, nil
+}
+
+// RoomReadStatus is a helper method that pages through all of the memberships of a room, and returns the ID of the last
+// message each member has seen, keyed by their person ID.  Members that haven't seen any messages are included with an
+// empty ID, so the map also shows who hasn't caught up.
+//
+// Spark only returns read data to callers that can read the room itself: its members, or a compliance officer whose
+// token has the spark-compliance:memberships_read scope.  For anyone else the request fails.
+func (c *client) RoomReadStatus(roomID string) (map[string]string, error) {
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+
+	memberships, err := c.listMemberships(0, url.Values{"roomId": {roomID}})
+	if err != nil {
+		return nil, err
+	}
+	return readStatus(memberships), nil
+}
+
+// Returns the last seen message IDs of memberships, keyed by person ID.
+func readStatus(memberships []*Membership) map[string]string {
+	status := make(map[string]string, len(memberships))
+	for _, m := range memberships {
+		status[m.PersonID] = m.LastSeenID
+	}
+	return status
+}
+
+// https://developer.webex.com/endpoint-memberships-get.html
+//
+// The API can't filter by moderator status, so ModeratorsOnly is applied as the memberships are received.  In that
+// case max counts moderators, and as many pages as it takes to find that many are requested.  Like the unfiltered
+// listing, a negative max lists nothing.
+func (c *client) ListMemberships(max int, params *MembershipListParams) ([]*Membership, error) {
+	if params == nil || !params.ModeratorsOnly {
+		return c.listMemberships(max, params.values())
+	}
+	if max < 0 {
+		return []*Membership{}, nil
+	}
+
+	var moderators []*Membership
+	err := c.forEachPage(MembershipsURL, params.values(), 0, func(page []byte) (bool, error) {
+		var ml MembershipList
+		if err := c.unmarshal(page, &ml); err != nil {
+			return false, err
+		}
+		for _, m := range ml.Items {
+			if !m.IsModerator {
+				continue
+			}
+			if moderators = append(moderators, m); max > 0 && len(moderators) == max {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil && moderators == nil {
+		return nil, err
+	}
+	if c.dedupe {
+		moderators = DedupeMemberships(moderators)
+	}
+	if moderators == nil {
+		moderators = []*Membership{} // empty, not failed
+	}
+	return moderators, err
+}
+
+// ListMyMemberships is a helper method that lists every membership of the identity the client authenticates as, ie.
+// one for each room it's in, with its moderator status there.  The identity is looked up with GetMyself the first time
+// it's needed, and cached on the client after that, like IsSelfAuthored does.
+func (c *client) ListMyMemberships() ([]*Membership, error) {
+	me, err := c.myself(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return c.listMemberships(0, (&MembershipListParams{PersonID: me.ID}).values())
+}
+
+// Lists memberships without any client-side filtering.
+func (c *client) listMemberships(max int, uv url.Values) ([]*Membership, error) {
+	resp, reqErr := c.getRequestWithPaging(MembershipsURL, uv, max)
+	if reqErr != nil && len(resp) == 0 { // if we got an error *and* results, parse them and return them
+		return nil, reqErr
+	}
+
+	var memberships []*Membership
+	for _, r := range resp {
+		var ml MembershipList
+		if jsonErr := c.unmarshal(r, &ml); jsonErr != nil {
+			return memberships, fmt.Errorf("%v && %v", reqErr, jsonErr)
+		}
+		memberships = append(memberships, ml.Items...)
+	}
+	if c.dedupe {
+		memberships = DedupeMemberships(memberships)
+	}
+	if memberships == nil {
+		memberships = []*Membership{} // empty, not failed
+	}
+	return memberships, reqErr
+}
+
+type MembershipListParams struct {
+	RoomID      string
+	PersonID    string
+	PersonEmail string
+
+	// Only list the memberships of moderators.  This is filtered by the client, not the API.
+	ModeratorsOnly bool
+}
+
+func (m *MembershipListParams) values() url.Values {
+	uv := make(url.Values)
+	if m == nil {
+		return uv
+	}
+
+	if m.RoomID != "" {
+		uv.Add("roomId", m.RoomID)
+	}
+	if m.PersonID != "" {
+		uv.Add("personId", m.PersonID)
+	}
+	if m.PersonEmail != "" {
+		uv.Add("personEmail", m.PersonEmail)
+	}
+
+	return uv
+}
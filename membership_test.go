@@ -0,0 +1,417 @@
+package spark
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Membership (Mock)", func() {
+	var c Client
+	var mockCli *mockHTTPClient
+
+	var memberships MembershipList
+
+	BeforeEach(func() {
+		c = New("mock")
+		mockCli = new(mockHTTPClient)
+		httpCli = mockCli // set client global to a mock
+
+		memberships = MembershipList{
+			Items: []*Membership{
+				{
+					ID:          "1",
+					RoomID:      "room 1",
+					PersonID:    "person 1",
+					PersonEmail: "hello1@world.com",
+					IsModerator: true,
+				},
+				{
+					ID:          "2",
+					RoomID:      "room 1",
+					PersonID:    "person 2",
+					PersonEmail: "hello2@world.com",
+				},
+				{
+					ID:          "3",
+					RoomID:      "room 1",
+					PersonID:    "person 3",
+					PersonEmail: "hello3@world.com",
+					IsModerator: true,
+				},
+			},
+		}
+	})
+
+	// Serves the memberships one per page, to make sure the helpers follow pagination
+	pagedMemberships := func(roomID string, calls *int) func(req *http.Request) (*http.Response, error) {
+		return func(req *http.Request) (*http.Response, error) {
+			uri := strings.Split(req.URL.String(), "?")[0]
+			Expect(uri).To(Equal(MembershipsURL))
+			Expect(req.URL.Query().Get("roomId")).To(Equal(roomID))
+			Expect(req.Method).To(Equal("GET"))
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+			p := MembershipList{
+				Items: memberships.Items[*calls : *calls+1],
+			}
+
+			var b bytes.Buffer
+			Expect(json.NewEncoder(&b).Encode(p)).To(Succeed())
+			r := &http.Response{
+				Body:       closer(&b),
+				StatusCode: http.StatusOK,
+			}
+
+			if *calls++; *calls < len(memberships.Items) {
+				r.Header = map[string][]string{
+					"Link": {fmt.Sprintf("<%s?roomId=%s>; rel=\"next\"", MembershipsURL, roomID)},
+				}
+			}
+			return r, nil
+		}
+	}
+
+	Describe("CreateMembership", func() {
+		// Echoes the sent membership back, as the server would
+		respond := func(sent *map[string]interface{}) {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(MembershipsURL))
+				Expect(req.Method).To(Equal("POST"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				var body bytes.Buffer
+				Expect(json.NewDecoder(io.TeeReader(req.Body, &body)).Decode(sent)).To(Succeed())
+				return &http.Response{Body: closer(&body), StatusCode: http.StatusOK}, nil
+			}
+		}
+
+		It("adds a plain member", func() {
+			var sent map[string]interface{}
+			respond(&sent)
+
+			m, err := c.CreateMembership(&NewMembership{RoomID: "room 1", PersonEmail: "two@world.com"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sent).To(Equal(map[string]interface{}{"roomId": "room 1", "personEmail": "two@world.com"}))
+			Expect(m).To(Equal(&Membership{RoomID: "room 1", PersonEmail: "two@world.com"}))
+		})
+
+		It("adds a moderator", func() {
+			var sent map[string]interface{}
+			respond(&sent)
+
+			m, err := c.CreateMembership(&NewMembership{RoomID: "room 1", PersonID: "person 2", IsModerator: true})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sent).To(Equal(map[string]interface{}{"roomId": "room 1", "personId": "person 2", "isModerator": true}))
+			Expect(m.IsModerator).To(BeTrue())
+		})
+
+		It("fails if a nil argument is provided", func() {
+			m, err := c.CreateMembership(nil)
+			Expect(err).To(MatchError("nil membership"))
+			Expect(m).To(BeNil())
+		})
+
+		It("fails if no room ID is provided", func() {
+			m, err := c.CreateMembership(&NewMembership{PersonID: "person 2"})
+			Expect(err).To(MatchError("no room ID specified"))
+			Expect(m).To(BeNil())
+		})
+
+		It("requires exactly one of person ID or email", func() {
+			_, err := c.CreateMembership(&NewMembership{RoomID: "room 1"})
+			Expect(err).To(MatchError("membership requires a person ID or email to add"))
+
+			_, err = c.CreateMembership(&NewMembership{RoomID: "room 1", PersonID: "person 2", PersonEmail: "two@world.com"})
+			Expect(err).To(MatchError("person ID and person email can't both be specified"))
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			m, err := c.CreateMembership(&NewMembership{RoomID: "room 1", PersonID: "person 2"})
+			Expect(err).To(MatchError(mockErr))
+			Expect(m).To(BeNil())
+		})
+	})
+
+	Describe("GetMembership", func() {
+		It("gets a person's membership in a room", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				uri := strings.Split(req.URL.String(), "?")[0]
+				Expect(uri).To(Equal(MembershipsURL))
+				Expect(req.URL.Query().Get("roomId")).To(Equal("room 1"))
+				Expect(req.URL.Query().Get("personId")).To(Equal("person 2"))
+				Expect(req.URL.Query().Get("max")).To(Equal("1"))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(MembershipList{Items: memberships.Items[1:2]})).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.GetMembership("room 1", "person 2")).To(Equal(memberships.Items[1]))
+		})
+
+		It("fails if the person isn't a member of the room", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"items":[]}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			m, err := c.GetMembership("room 1", "person 4")
+			Expect(err).To(MatchError(`no membership found for person "person 4" in room "room 1"`))
+			Expect(m).To(BeNil())
+		})
+
+		It("fails if no room ID is specified", func() {
+			m, err := c.GetMembership("", "person 1")
+			Expect(err).To(MatchError("no room ID specified"))
+			Expect(m).To(BeNil())
+		})
+
+		It("fails if no person ID is specified", func() {
+			m, err := c.GetMembership("room 1", "")
+			Expect(err).To(MatchError("no person ID specified"))
+			Expect(m).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			m, err := c.GetMembership("room 1", "person 1")
+			Expect(err).To(MatchError(mockErr))
+			Expect(m).To(BeNil())
+		})
+	})
+
+	Describe("CountRoomMembers", func() {
+		It("counts the members of a room across pages", func() {
+			calls := 0
+			mockCli.DoFunc = pagedMemberships("room 1", &calls)
+
+			Expect(c.CountRoomMembers("room 1")).To(Equal(len(memberships.Items)))
+			Expect(calls).To(Equal(len(memberships.Items)))
+		})
+
+		It("fails if no room ID is specified", func() {
+			n, err := c.CountRoomMembers("")
+			Expect(err).To(MatchError("no room ID specified"))
+			Expect(n).To(BeZero())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			n, err := c.CountRoomMembers("room 1")
+			Expect(err).To(MatchError(mockErr))
+			Expect(n).To(BeZero())
+		})
+	})
+
+	Describe("RoomReadStatus", func() {
+		It("maps every member of a room to the last message they've seen, across pages", func() {
+			memberships.Items[0].LastSeenID = "message 3"
+			memberships.Items[2].LastSeenID = "message 1"
+
+			calls := 0
+			mockCli.DoFunc = pagedMemberships("room 1", &calls)
+
+			Expect(c.RoomReadStatus("room 1")).To(Equal(map[string]string{
+				"person 1": "message 3",
+				"person 2": "",
+				"person 3": "message 1",
+			}))
+			Expect(calls).To(Equal(len(memberships.Items)))
+		})
+
+		It("decodes the read data of memberships", func() {
+			var m Membership
+			Expect(json.Unmarshal([]byte(`{"id":"1","lastSeenId":"message 1","lastSeenDate":"2020-01-02T03:04:05.000Z"}`), &m)).To(Succeed())
+			Expect(m.LastSeenID).To(Equal("message 1"))
+			Expect(m.LastSeenDate.Year()).To(Equal(2020))
+		})
+
+		It("fails if no room ID is specified", func() {
+			status, err := c.RoomReadStatus("")
+			Expect(err).To(MatchError("no room ID specified"))
+			Expect(status).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			status, err := c.RoomReadStatus("room 1")
+			Expect(err).To(MatchError(mockErr))
+			Expect(status).To(BeNil())
+		})
+	})
+
+	Describe("ListRoomModerators", func() {
+		It("lists only the moderators of a room across pages", func() {
+			calls := 0
+			mockCli.DoFunc = pagedMemberships("room 1", &calls)
+
+			Expect(c.ListRoomModerators("room 1")).To(ConsistOf(memberships.Items[0], memberships.Items[2]))
+			Expect(calls).To(Equal(len(memberships.Items)))
+		})
+
+		It("returns an empty, non-nil slice when the room has no moderators", func() {
+			memberships.Items[0].IsModerator = false
+			memberships.Items[2].IsModerator = false
+
+			calls := 0
+			mockCli.DoFunc = pagedMemberships("room 1", &calls)
+
+			m, err := c.ListRoomModerators("room 1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m).ToNot(BeNil())
+			Expect(m).To(BeEmpty())
+		})
+
+		It("fails if no room ID is specified", func() {
+			m, err := c.ListRoomModerators("")
+			Expect(err).To(MatchError("no room ID specified"))
+			Expect(m).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			m, err := c.ListRoomModerators("room 1")
+			Expect(err).To(MatchError(mockErr))
+			Expect(m).To(BeNil())
+		})
+	})
+
+	Describe("ListMyMemberships", func() {
+		It("pages through the memberships of the client's identity, looking it up once", func() {
+			meCalls, pageCalls := 0, 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				if req.URL.String() == fmt.Sprintf("%s/me", PeopleURL) {
+					meCalls++
+					return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"person 1"}`)), StatusCode: http.StatusOK}, nil
+				}
+
+				Expect(strings.Split(req.URL.String(), "?")[0]).To(Equal(MembershipsURL))
+				Expect(req.URL.Query().Get("personId")).To(Equal("person 1"))
+				page := pageCalls % 2
+				pageCalls++
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(MembershipList{Items: memberships.Items[page : page+1]})).To(Succeed())
+				r := &http.Response{Body: closer(&b), StatusCode: http.StatusOK, Header: http.Header{}}
+				if page == 0 {
+					r.Header.Set("Link", fmt.Sprintf("<%s?personId=person+1&cursor=2>; rel=\"next\"", MembershipsURL))
+				}
+				return r, nil
+			}
+
+			for i := 0; i < 2; i++ {
+				Expect(c.ListMyMemberships()).To(Equal(memberships.Items[:2]))
+			}
+			Expect(meCalls).To(Equal(1))
+			Expect(pageCalls).To(Equal(4))
+		})
+
+		It("fails if the client's identity can't be looked up", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/me", PeopleURL)))
+				return nil, mockErr
+			}
+
+			m, err := c.ListMyMemberships()
+			Expect(err).To(MatchError(mockErr))
+			Expect(m).To(BeNil())
+		})
+	})
+
+	Describe("MembershipListParams", func() {
+		It("builds empty values from nil params", func() {
+			uv := (*MembershipListParams)(nil).values()
+			Expect(uv).ToNot(BeNil())
+			Expect(uv).To(BeEmpty())
+		})
+	})
+
+	Describe("ListMemberships", func() {
+		It("applies a parameter list", func() {
+			params := &MembershipListParams{RoomID: "room 1", PersonID: "person 1", PersonEmail: "hello1@world.com"}
+			Expect(params.values()).To(Equal(url.Values{
+				"roomId":      {"room 1"},
+				"personId":    {"person 1"},
+				"personEmail": {"hello1@world.com"},
+			}))
+			Expect((&MembershipListParams{ModeratorsOnly: true}).values()).To(BeEmpty())
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Query().Get("roomId")).To(Equal("room 1"))
+				Expect(req.URL.Query().Get("personId")).To(Equal("person 1"))
+				Expect(req.URL.Query().Get("personEmail")).To(Equal("hello1@world.com"))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(MembershipList{Items: memberships.Items[:1]})).To(Succeed())
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+			Expect(c.ListMemberships(0, params)).To(Equal(memberships.Items[:1]))
+		})
+
+		It("lists every membership without a filter", func() {
+			calls := 0
+			mockCli.DoFunc = pagedMemberships("room 1", &calls)
+
+			Expect(c.ListMemberships(0, &MembershipListParams{RoomID: "room 1"})).To(Equal(memberships.Items))
+		})
+
+		It("filters to moderators, counting only them against max", func() {
+			calls := 0
+			mockCli.DoFunc = pagedMemberships("room 1", &calls)
+
+			Expect(c.ListMemberships(2, &MembershipListParams{RoomID: "room 1", ModeratorsOnly: true})).
+				To(Equal([]*Membership{memberships.Items[0], memberships.Items[2]}))
+			Expect(calls).To(Equal(3))
+		})
+
+		It("stops paging once it has found max moderators", func() {
+			calls := 0
+			mockCli.DoFunc = pagedMemberships("room 1", &calls)
+
+			Expect(c.ListMemberships(1, &MembershipListParams{RoomID: "room 1", ModeratorsOnly: true})).
+				To(Equal(memberships.Items[:1]))
+			Expect(calls).To(Equal(1))
+		})
+
+		It("lists nothing for a negative max, with or without the moderator filter", func() {
+			calls := 0
+			mockCli.DoFunc = pagedMemberships("room 1", &calls)
+
+			for _, moderators := range []bool{false, true} {
+				ms, err := c.ListMemberships(-1, &MembershipListParams{RoomID: "room 1", ModeratorsOnly: moderators})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ms).ToNot(BeNil())
+				Expect(ms).To(BeEmpty())
+			}
+			Expect(calls).To(BeZero())
+		})
+	})
+})
@@ -2,25 +2,43 @@ package spark
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
 	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 )
 
-const MessagesURL = "https://api.ciscospark.com/v1/messages"
+const MessagesURL = BaseURL + "/messages"
+
+// DirectMessagesURL lists the 1:1 messages between the client's identity and one other person.
+const DirectMessagesURL = MessagesURL + "/direct"
 
 type Message struct {
-	ID          string    `json:"id"`
-	RoomID      string    `json:"roomId"`
-	RoomType    string    `json:"roomType"`
-	PersonID    string    `json:"personId"`
-	PersonEmail string    `json:"personEmail"`
-	Text        string    `json:"text"`
-	Markdown    string    `json:"markdown"`
-	Files       []string  `json:"files"`
-	HTML        string    `json:"html"`
-	Created     time.Time `json:"created"`
+	ID          string   `json:"id"`
+	RoomID      string   `json:"roomId"`
+	RoomType    string   `json:"roomType"`
+	PersonID    string   `json:"personId"`
+	PersonEmail string   `json:"personEmail"`
+	Text        string   `json:"text"`
+	Markdown    string   `json:"markdown"`
+	Files       []string `json:"files"`
+	HTML        string   `json:"html"`
+	Created     Time     `json:"created"`
+
+	// The classification of the message's content, in rooms that use classifications.  See Room.ClassificationID.
+	ClassificationID string `json:"classificationId,omitempty"`
+
+	// The ID of the message that this one is a threaded reply to, if it's a reply.  See GetThread.
+	ParentID string `json:"parentId,omitempty"`
 }
 
 type MessageList struct {
@@ -35,44 +53,444 @@ type NewMessage struct {
 	Text          string   `json:"text,omitempty"`
 	Markdown      string   `json:"markdown,omitempty"`
 	Files         []string `json:"files,omitempty"`
+
+	// Required for messages posted to classified rooms, when the client checks for it (see SetRequireClassification)
+	ClassificationID string `json:"classificationId,omitempty"`
+
+	// Posts the message as a threaded reply to the message with this ID, which must be in the same room
+	ParentID string `json:"parentId,omitempty"`
+
+	// Extra fields to send in the message's JSON body, for fields that Spark supports but NewMessage doesn't have yet
+	// (ex. beta features).  They're merged in when the message is marshaled, but never replace the fields above, even
+	// empty ones.  They aren't sent in the multipart forms of CreateMessageWithFiles.
+	Extra map[string]interface{} `json:"-"`
 }
 
-// https://developer.webex.com/endpoint-messages-messageId-get.html
-func (c *client) GetMessage(messageID string) (*Message, error) {
-	if messageID == "" {
-		return nil, fmt.Errorf("no message ID specified")
+// The JSON names of NewMessage's own fields, which Extra can't replace.
+var newMessageFields = jsonFieldNames(reflect.TypeOf(NewMessage{}))
+
+// MarshalJSON marshals the message's fields, along with any Extra fields that don't collide with them.
+func (m NewMessage) MarshalJSON() ([]byte, error) {
+	type plain NewMessage // without this method, so it doesn't recurse
+	b, err := json.Marshal(plain(m))
+	if err != nil || len(m.Extra) == 0 {
+		return b, err
 	}
 
-	resp, err := c.getRequest(fmt.Sprintf("%s/%s", MessagesURL, messageID), nil)
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range m.Extra {
+		if newMessageFields[k] {
+			continue
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("message extra field %q: %v", k, err)
+		}
+		fields[k] = raw
+	}
+	return json.Marshal(fields)
+}
+
+// Returns the names that a struct type's fields are marshaled to JSON as, skipping any that aren't marshaled.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// Reports whether the message has anything to send: text, markdown, or files.  Setting both Text and Markdown is fine,
+// and common: clients that can't render the markdown show the text instead.
+func (m *NewMessage) hasContent() bool {
+	return m.Text != "" || m.Markdown != "" || len(m.Files) > 0
+}
+
+// Mention returns the markdown that @-mentions the person in a message, in the form <@personId:ID|DisplayName>.  The
+// mention is only rendered if it's sent as part of a message's Markdown, not its Text.  Returns an empty string for a
+// nil person.  See https://developer.webex.com/docs/api/basics#formatting-messages
+func Mention(p *Person) string {
+	if p == nil {
+		return ""
+	}
+	if p.DisplayName == "" {
+		return fmt.Sprintf("<@personId:%s>", p.ID)
+	}
+	return fmt.Sprintf("<@personId:%s|%s>", p.ID, p.DisplayName)
+}
+
+// MentionEmail works like Mention, except it mentions a person by email address, in the form <@personEmail:email>.
+func MentionEmail(email string) string {
+	return fmt.Sprintf("<@personEmail:%s>", email)
+}
+
+// WithMention returns a *copy* of the message with a mention of the person appended to its Markdown, separated from
+// any existing markdown by a space.  A nil message gives a new message that holds just the mention.  Ex:
+//
+//	m := (&spark.NewMessage{RoomID: roomID, Markdown: "Hello"}).WithMention(p)
+func (m *NewMessage) WithMention(p *Person) *NewMessage {
+	var cp NewMessage
+	if m != nil {
+		cp = *m
+	}
+	if cp.Markdown != "" {
+		cp.Markdown += " "
+	}
+	cp.Markdown += Mention(p)
+	return &cp
+}
+
+// HasFiles reports whether the message has any attachments, without downloading them.
+func (m *Message) HasFiles() bool {
+	return m.AttachmentCount() > 0
+}
+
+// AttachmentCount returns the number of files attached to the message.
+func (m *Message) AttachmentCount() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.Files)
+}
+
+// PlainText returns the message's content as plain text.  That's its Text if it has any, and otherwise a stripped
+// version of its HTML (as rendered by Spark) or Markdown, for messages that were only sent with markup.
+func (m *Message) PlainText() string {
+	switch {
+	case m == nil:
+		return ""
+	case m.Text != "":
+		return m.Text
+	case m.HTML != "":
+		return stripHTML(m.HTML)
+	default:
+		return stripMarkdown(m.Markdown)
+	}
+}
+
+// Matches a mention at the start of a message's markdown, in either the form that Mention and MentionEmail produce, or
+// the <spark-mention> markup that Spark uses for received messages.  The first non-empty group is the mentioned ID or
+// email address.
+var leadingMention = regexp.MustCompile(`^(?:<@person(?:Id|Email):([^|>]+)(?:\|[^>]*)?>|` +
+	`<spark-mention[^>]*data-object-id="([^"]*)"[^>]*>.*?</spark-mention>)`)
+
+// StripMention returns the message's text with a leading mention of the given person removed, along with any space or
+// punctuation separating it from the rest of the text.  When a bot is mentioned in a group room, the message's Text
+// starts with the bot's name (ex. "BotName do the thing"), which this reduces to the command ("do the thing").  The
+// mention is matched by the person's display name, nickname, first name, or the first word of their display name,
+// without regard to case.  If the message has no Text, its Markdown is used instead, and the mention is matched by
+// the person's ID or email in the markup.  If the message doesn't start with a mention of the person, its text is
+// returned with only the surrounding space trimmed.
+func (m *Message) StripMention(self *Person) string {
+	if m == nil {
+		return ""
+	}
+	if m.Text == "" {
+		return stripMarkupMention(strings.TrimSpace(m.Markdown), self)
+	}
+
+	text := strings.TrimSpace(m.Text)
+	if self == nil {
+		return text
+	}
+	names := []string{self.DisplayName, self.NickName, self.FirstName}
+	if fields := strings.Fields(self.DisplayName); len(fields) > 1 {
+		names = append(names, fields[0])
+	}
+	rest := strings.TrimPrefix(text, "@")
+	for _, name := range names {
+		if name == "" || len(rest) < len(name) || !strings.EqualFold(rest[:len(name)], name) {
+			continue
+		}
+		if after := rest[len(name):]; after == "" || strings.ContainsAny(after[:1], " \t\n,:;") {
+			return trimMentionSeparator(after)
+		}
+		// otherwise it's only part of a word, ex. "Bo" in "Bob"
+	}
+	return text
+}
+
+// Removes a leading markup mention of the person from the markdown.
+func stripMarkupMention(markdown string, self *Person) string {
+	match := leadingMention.FindStringSubmatch(markdown)
+	if match == nil || self == nil {
+		return markdown
+	}
+	who := match[1] + match[2] // only one of them is set
+	if who != self.ID && !hasEmail(self, who) {
+		return markdown
+	}
+	return trimMentionSeparator(markdown[len(match[0]):])
+}
+
+func trimMentionSeparator(s string) string {
+	return strings.TrimLeft(strings.TrimSpace(s), ",:; \t\n")
+}
+
+// https://developer.webex.com/endpoint-messages-messageId-get.html
+func (c *client) GetMessage(messageID string) (*Message, error) {
+	resp, err := c.GetMessageRaw(messageID)
 	if err != nil {
 		return nil, err
 	}
 
 	var m Message
-	err = json.Unmarshal(resp, &m)
+	err = c.unmarshal(resp, &m)
 	return &m, err
 }
 
+// GetMessageRaw works like GetMessage, except it returns the raw JSON of the response instead of a parsed Message.
+// This allows callers to read fields that the Message struct does not (yet) model.
+func (c *client) GetMessageRaw(messageID string) (json.RawMessage, error) {
+	if messageID == "" {
+		return nil, fmt.Errorf("no message ID specified")
+	}
+
+	return c.getRequest(fmt.Sprintf("%s/%s", MessagesURL, messageID), nil)
+}
+
+// IsSelfAuthored reports whether the message was sent by the identity the client authenticates as.  Bots should check
+// this before responding to a message, to avoid endlessly replying to themselves.  The identity is looked up with
+// GetMyself the first time this is called, and cached on the client after that.
+func (c *client) IsSelfAuthored(ctx context.Context, msg *Message) (bool, error) {
+	if msg == nil {
+		return false, fmt.Errorf("nil message")
+	}
+
+	me, err := c.myself(ctx)
+	if err != nil {
+		return false, err
+	}
+	return msg.PersonID == me.ID, nil
+}
+
 // https://developer.webex.com/endpoint-messages-post.html
 func (c *client) CreateMessage(m *NewMessage) (*Message, error) {
+	return c.CreateMessageWithOptions(m, nil)
+}
+
+// CreateMessageOptions holds the optional settings for CreateMessageWithOptions.
+type CreateMessageOptions struct {
+	// A caller-generated key that identifies this message, ex. a UUID.  It's sent as the Idempotency-Key header, and if
+	// a message was already successfully created with the same key by this client (or a copy of it), that message is
+	// returned again instead of being resent.  This makes it safe to retry a CreateMessage whose outcome is unknown.
+	IdempotencyKey string
+}
+
+// CreateMessageWithOptions works like CreateMessage, with the additional settings in opts.  A nil opts is the same as
+// calling CreateMessage.  Note that idempotency keys are only remembered for the most recent messages created by the
+// process, and that two concurrent calls with the same key may both be sent.
+func (c *client) CreateMessageWithOptions(m *NewMessage, opts *CreateMessageOptions) (*Message, error) {
+	m, err := c.prepareMessage(m, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var key string
+	if opts != nil {
+		key = opts.IdempotencyKey
+	}
+	resp, sent := c.sent.get(key)
+	if !sent {
+		b, err := c.encode(m)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest("POST", MessagesURL, b)
+		if err != nil {
+			return nil, err
+		}
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		if resp, err = c.request(req); err != nil {
+			return nil, err
+		}
+		c.sent.add(key, resp)
+	}
+
+	var rm Message
+	err = c.unmarshal(resp, &rm)
+	return &rm, err
+}
+
+// Checks that a new message can be sent, and applies the client's message settings to it (see SetMarkdownFallback and
+// SetRequireClassification).  The message is copied rather than modified if it needs to change.  An uploaded file
+// counts as content, so a message with one doesn't need any text.
+func (c *client) prepareMessage(m *NewMessage, upload bool) (*NewMessage, error) {
 	if m == nil {
 		return nil, fmt.Errorf("nil message")
 	}
 	if m.RoomID == "" && m.ToPersonEmail == "" && m.ToPersonID == "" {
 		return nil, fmt.Errorf("message requires a room ID, person ID, or email to send to")
 	}
+	if !m.hasContent() && !upload {
+		return nil, fmt.Errorf("message has no content")
+	}
+	if c.requireClassification && m.RoomID != "" && m.ClassificationID == "" {
+		room, err := c.GetRoom(m.RoomID)
+		if err != nil {
+			return nil, err
+		}
+		if room.ClassificationID != "" {
+			return nil, fmt.Errorf("room %q is classified, so messages posted to it require a classification ID", m.RoomID)
+		}
+	}
+	if c.markdownFallback && m.Markdown != "" && m.Text == "" {
+		cp := *m
+		cp.Text = stripMarkdown(m.Markdown)
+		m = &cp
+	}
+	return m, nil
+}
+
+// FileUpload is a local file to attach to a message sent with CreateMessageWithFiles.  ContentType defaults to
+// application/octet-stream.
+type FileUpload struct {
+	Name        string
+	ContentType string
+	Content     io.Reader
+}
+
+// Spark accepts at most one uploaded file per message.  Messages aren't limited in how many files they link to by URL.
+const maxFileUploads = 1
+
+// Checks the files to upload with a message.
+func validateUploads(files []*FileUpload) error {
+	if len(files) > maxFileUploads {
+		return fmt.Errorf("messages can only have %d uploaded file, not %d; link the others with NewMessage.Files URLs, or send them in separate messages",
+			maxFileUploads, len(files))
+	}
+	for _, f := range files {
+		if f == nil {
+			return fmt.Errorf("nil file upload")
+		}
+		if f.Name == "" {
+			return fmt.Errorf("file upload has no name")
+		}
+		if f.Content == nil {
+			return fmt.Errorf("file upload %q has no content", f.Name)
+		}
+	}
+	return nil
+}
+
+// CreateMessageWithFiles works like CreateMessage, except that it also uploads files from the caller, rather than only
+// linking to them by URL like NewMessage.Files does.  The message is sent as a multipart form.  Spark only allows one
+// uploaded file per message, so more than one fails up front, but any number of NewMessage.Files URLs can be sent
+// alongside it.  Without any uploads, this is the same as CreateMessage.
+func (c *client) CreateMessageWithFiles(m *NewMessage, files ...*FileUpload) (*Message, error) {
+	if err := validateUploads(files); err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return c.CreateMessage(m)
+	}
+	m, err := c.prepareMessage(m, true)
+	if err != nil {
+		return nil, err
+	}
 
 	b := new(bytes.Buffer)
-	if err := json.NewEncoder(b).Encode(m); err != nil {
+	w := multipart.NewWriter(b)
+	if err := writeMessageForm(w, m, files[0]); err != nil {
+		return nil, err
+	}
+	resp, err := c.postRequestSized(MessagesURL, b, int64(b.Len()), w.FormDataContentType())
+	if err != nil {
+		return nil, err
+	}
+
+	var rm Message
+	err = c.unmarshal(resp, &rm)
+	return &rm, err
+}
+
+// Escapes a file name for a Content-Disposition header, the way mime/multipart does for CreateFormFile.
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// Writes a new message and a file to upload with it as a multipart form, the way the messages endpoint accepts them.
+func writeMessageForm(w *multipart.Writer, m *NewMessage, upload *FileUpload) error {
+	fields := []struct{ name, value string }{
+		{"roomId", m.RoomID},
+		{"toPersonId", m.ToPersonID},
+		{"toPersonEmail", m.ToPersonEmail},
+		{"text", m.Text},
+		{"markdown", m.Markdown},
+		{"classificationId", m.ClassificationID},
+		{"parentId", m.ParentID},
+	}
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		if err := w.WriteField(f.name, f.value); err != nil {
+			return err
+		}
+	}
+	for _, u := range m.Files {
+		if err := w.WriteField("files", u); err != nil {
+			return err
+		}
+	}
+
+	contentType := upload.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files"; filename="%s"`, quoteEscaper.Replace(upload.Name)))
+	h.Set("Content-Type", contentType)
+	part, err := w.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, upload.Content); err != nil {
+		return fmt.Errorf("reading file upload %q: %v", upload.Name, err)
+	}
+	return w.Close()
+}
+
+// https://developer.webex.com/docs/api/v1/messages/edit-a-message
+func (c *client) UpdateMessage(messageID string, m *NewMessage) (*Message, error) {
+	if messageID == "" {
+		return nil, fmt.Errorf("no message ID specified")
+	}
+	if m == nil {
+		return nil, fmt.Errorf("nil message")
+	}
+	if m.RoomID == "" { // the room has to match the original message's room
+		return nil, fmt.Errorf("no room ID specified")
+	}
+	if !m.hasContent() {
+		return nil, fmt.Errorf("message has no content")
+	}
+	if m.Text == "" && m.Markdown == "" { // a message's files can't be edited
+		return nil, fmt.Errorf("message requires text or markdown")
+	}
+
+	b, err := c.encode(m)
+	if err != nil {
 		return nil, err
 	}
-	resp, err := c.postRequest(MessagesURL, b)
+	resp, err := c.putRequest(fmt.Sprintf("%s/%s", MessagesURL, messageID), b)
 	if err != nil {
 		return nil, err
 	}
 
 	var rm Message
-	err = json.Unmarshal(resp, &rm)
+	err = c.unmarshal(resp, &rm)
 	return &rm, err
 }
 
@@ -86,36 +504,451 @@ func (c *client) DeleteMessage(messageID string) error {
 	return err
 }
 
+// DeleteOwnMessage works like DeleteMessage, except that it only deletes messages sent by the identity the client
+// authenticates as.  It fetches the message first, and refuses to delete it if someone else sent it.  This guards
+// against a broadly scoped token deleting other people's messages by mistake.  Like IsSelfAuthored, the identity is
+// looked up once, and cached on the client after that.
+func (c *client) DeleteOwnMessage(messageID string) error {
+	msg, err := c.GetMessage(messageID)
+	if err != nil {
+		return err
+	}
+
+	self, err := c.IsSelfAuthored(context.Background(), msg)
+	if err != nil {
+		return err
+	}
+	if !self {
+		return fmt.Errorf("message %q was not sent by the client's identity, refusing to delete it", messageID)
+	}
+	return c.DeleteMessage(messageID)
+}
+
+// The number of messages that DeleteOwnMessagesInRoom deletes at once.
+const deleteConcurrency = 4
+
+// DeleteOwnMessagesInRoom is a helper method for cleaning up after a bot: it deletes every message in a room that was
+// sent by the identity the client authenticates as, and returns how many were deleted.  The room is paged through
+// first, and the messages are only deleted once every page has been read, since deleting them as it goes would pull
+// the rug out from under the paging cursor.  They're then deleted a few at a time, in parallel.  If some can't be
+// deleted, the others still are, and a MessageErrors keyed by message ID describes the failures.  Like
+// IsSelfAuthored, the identity is looked up once, and cached on the client after that.
+func (c *client) DeleteOwnMessagesInRoom(roomID string) (int, error) {
+	if roomID == "" {
+		return 0, fmt.Errorf("no room ID specified")
+	}
+
+	me, err := c.myself(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []string
+	_, err = c.forEachMessagePage(MessagesURL, url.Values{"roomId": {roomID}}, 0, func(ml *MessageList) bool {
+		for _, m := range ml.Items {
+			if m.PersonID == me.ID {
+				ids = append(ids, m.ID)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		deleted int
+		errs    = make(MessageErrors)
+		sem     = make(chan struct{}, deleteConcurrency)
+	)
+	for _, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(messageID string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			err := c.DeleteMessage(messageID)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[messageID] = err
+				return
+			}
+			deleted++
+		}(id)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return deleted, errs
+	}
+	return deleted, nil
+}
+
 // https://developer.ciscospark.com/endpoint-messages-get.html
+//
+// Messages are normally listed by room.  To list the 1:1 messages with a person instead, pass an empty roomID and set
+// the PersonID or PersonEmail of params, which lists them from the messages/direct endpoint.  That endpoint doesn't
+// support any other filters, and a room ID can't be combined with a person.
 func (c *client) ListMessages(max int, roomID string, params *MessageListParams) ([]*Message, error) {
+	messages, _, err := c.ListMessagesTruncated(max, roomID, params)
+	return messages, err
+}
+
+// ListMessagesTruncated works like ListMessages, but also reports whether the list was cut short by max, ie. whether
+// the server had more messages to give when max was reached.  If it returns false, every matching message was listed.
+func (c *client) ListMessagesTruncated(max int, roomID string, params *MessageListParams) ([]*Message, bool, error) {
+	if err := params.validate(roomID); err != nil {
+		return nil, false, err
+	}
+
+	var messages []*Message
+	truncated, err := c.forEachMessagePage(params.endpoint(), params.values(roomID), max, func(ml *MessageList) bool {
+		messages = append(messages, ml.Items...)
+		return true
+	})
+	if err != nil && messages == nil {
+		return nil, false, err
+	}
+	if c.dedupe {
+		messages = DedupeMessages(messages)
+	}
+	if messages == nil {
+		messages = []*Message{} // empty, not failed
+	}
+	return messages, truncated, err
+}
+
+// Pages through the messages endpoint, handing each decoded page to fn until fn returns false.  Messages are listed
+// newest first, and the endpoint pages backward in time, so rather than trusting the next links' cursors (which can
+// skip messages when they are mixed with a before time), every page after the first is requested with the
+// beforeMessage of the oldest message received so far.  The messages/direct endpoint doesn't accept beforeMessage, so
+// its next links are followed as the server sent them.  Like forEachPageWithCursor, this reports whether max was
+// reached while the server still had more messages.
+func (c *client) forEachMessagePage(uri string, uv url.Values, max int, fn func(ml *MessageList) bool) (bool, error) {
+	var oldest string
+	cursor := func(params url.Values) {
+		continueBefore(params, oldest)
+	}
+	if uri == DirectMessagesURL {
+		cursor = nil
+	}
+
+	return c.forEachPageWithCursor(uri, uv, max, func(page []byte) (bool, error) {
+		var ml MessageList
+		if err := c.unmarshal(page, &ml); err != nil {
+			return false, err
+		}
+		if n := len(ml.Items); n > 0 {
+			oldest = ml.Items[n-1].ID
+		}
+		return fn(&ml), nil
+	}, cursor)
+}
+
+// Points a messages query at the page before the given message, replacing whatever cursor it had.  before and
+// beforeMessage can't both be sent, so before is dropped.  An empty oldest (from an empty page) leaves the query alone.
+func continueBefore(params url.Values, oldest string) {
+	if oldest != "" {
+		params.Set("beforeMessage", oldest)
+		params.Del("before")
+	}
+}
+
+// ListMessagesSingle works like ListMessages, except that it makes exactly one request, for exactly max messages,
+// regardless of the client's page size.  Along with the messages, it returns the pagination links the server sent.
+// Links.Next is the next (older) page, and is empty if there are no more messages.  Like ListMessages, it continues
+// from the oldest message returned, except for direct messages, whose links are all passed along as the server sent
+// them, like the other links are.
+func (c *client) ListMessagesSingle(max int, roomID string, params *MessageListParams) ([]*Message, Links, error) {
+	if err := params.validate(roomID); err != nil {
+		return nil, Links{}, err
+	}
+
+	page, links, err := c.getSinglePage(params.endpoint(), params.values(roomID), max)
+	if err != nil {
+		return nil, Links{}, err
+	}
+
+	var ml MessageList
+	if err := c.unmarshal(page, &ml); err != nil {
+		return nil, Links{}, err
+	}
+	if ml.Items == nil {
+		ml.Items = []*Message{} // empty, not failed
+	}
+	if n := len(ml.Items); links.Next != "" && n > 0 && !params.direct() {
+		if u, err := url.Parse(links.Next); err == nil {
+			params := u.Query()
+			continueBefore(params, ml.Items[n-1].ID)
+			u.RawQuery = params.Encode()
+			links.Next = u.String()
+		}
+	}
+	return ml.Items, links, nil
+}
+
+// ListMessagesBetween is a helper method that lists every message in a room that was sent at or after from, and before
+// to.  Messages are listed newest first, so this pages backward from to, and stops requesting pages as soon as it
+// reaches a message older than from.  A zero to means "up to now".
+func (c *client) ListMessagesBetween(roomID string, from, to time.Time) ([]*Message, error) {
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+	if !to.IsZero() && to.Before(from) {
+		return nil, fmt.Errorf("end of time window is before its start")
+	}
+
+	// The server may not honor After, so the window's start is also enforced while paging
+	params := &MessageListParams{Before: to, After: from}
+
+	var messages []*Message
+	_, err := c.forEachMessagePage(MessagesURL, params.values(roomID), 0, func(ml *MessageList) bool {
+		for _, m := range ml.Items {
+			if m.Created.Before(from) {
+				return false // everything after this is older still
+			}
+			messages = append(messages, m)
+		}
+		return true
+	})
+	if messages == nil && err == nil {
+		messages = []*Message{} // empty, not failed
+	}
+	return messages, err
+}
+
+// ListMyMentions is a helper method that lists the messages in a room that @-mention the identity the client
+// authenticates as, and were sent at or after since, newest first.  This is what a bot polls for to catch up on what
+// was asked of it since it last checked.  A zero since lists every mention.  Like ListMessagesBetween, paging stops as
+// soon as it reaches a message older than since.
+func (c *client) ListMyMentions(roomID string, since time.Time) ([]*Message, error) {
 	if roomID == "" {
 		return nil, fmt.Errorf("no room ID specified")
 	}
 
-	resp, reqErr := c.getRequestWithPaging(MessagesURL, params.values(roomID), max)
-	if reqErr != nil && len(resp) == 0 { // if we got an error *and* results, parse them and return them
-		return nil, reqErr
+	// Like ListMessagesBetween, since is also enforced while paging, in case the server doesn't honor After
+	params := &MessageListParams{MentionedPeople: "me", After: since}
+
+	var messages []*Message
+	_, err := c.forEachMessagePage(MessagesURL, params.values(roomID), 0, func(ml *MessageList) bool {
+		for _, m := range ml.Items {
+			if m.Created.Before(since) {
+				return false
+			}
+			messages = append(messages, m)
+		}
+		return true
+	})
+	if c.dedupe {
+		messages = DedupeMessages(messages)
+	}
+	if messages == nil && err == nil {
+		messages = []*Message{} // empty, not failed
+	}
+	return messages, err
+}
+
+// GetThread is a helper method that gets the whole thread a message is part of: the thread's parent message, and all
+// of its replies, newest first like ListMessages.  The message can be the parent or any of the replies.  A message
+// that isn't part of a thread is its own parent, with no replies.
+func (c *client) GetThread(messageID string) (*Message, []*Message, error) {
+	msg, err := c.GetMessage(messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parent := msg
+	if msg.ParentID != "" {
+		if parent, err = c.GetMessage(msg.ParentID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	replies, err := c.ListMessages(0, parent.RoomID, &MessageListParams{ParentID: parent.ID})
+	if err != nil {
+		return nil, nil, err
+	}
+	return parent, replies, nil
+}
+
+// FindMessages is a helper method that searches a room's messages, since the API has no server-side search.  It pages
+// backward through the room, newest first, and returns the messages that match, stopping after limit matches, or
+// when it runs out of messages.  A limit of 0 or less searches the whole room.  Only one page is held at a time, so
+// searching a long room doesn't buffer all of it.
+func (c *client) FindMessages(roomID string, match func(m *Message) bool, limit int) ([]*Message, error) {
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no match function specified")
 	}
 
 	var messages []*Message
-	for _, r := range resp {
-		var ml MessageList
-		if jsonErr := json.Unmarshal(r, &ml); reqErr != nil {
-			return messages, fmt.Errorf("%v && %v", reqErr, jsonErr)
+	_, err := c.forEachMessagePage(MessagesURL, url.Values{"roomId": {roomID}}, 0, func(ml *MessageList) bool {
+		for _, m := range ml.Items {
+			if !match(m) {
+				continue
+			}
+			messages = append(messages, m)
+			if limit > 0 && len(messages) >= limit {
+				return false
+			}
 		}
-		messages = append(messages, ml.Items...)
+		return true
+	})
+	if err != nil && messages == nil {
+		return nil, err
+	}
+	if c.dedupe {
+		messages = DedupeMessages(messages)
 	}
-	return messages, reqErr
+	if messages == nil {
+		messages = []*Message{} // empty, not failed
+	}
+	return messages, err
 }
 
+// The number of rooms that ListAllRoomMessages queries at once.
+const roomConcurrency = 4
+
+// ListAllRoomMessages is a helper method that lists the messages newer than since in every room the client is in,
+// keyed by room ID.  Rooms without any such messages are left out.  This is meant for catching up after downtime.
+// Rooms are queried a few at a time, in parallel.  If some of the rooms can't be queried, the messages of the others
+// are still returned, along with a RoomErrors describing the failures.
+func (c *client) ListAllRoomMessages(since time.Time) (map[string][]*Message, error) {
+	rooms, err := c.ListRooms(0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		messages = make(map[string][]*Message)
+		errs     = make(RoomErrors)
+		sem      = make(chan struct{}, roomConcurrency)
+	)
+	for _, r := range rooms {
+		// A room that hasn't been active since then can't have any newer messages
+		if !r.LastActivity.IsZero() && r.LastActivity.Before(since) {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(roomID string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			ms, err := c.ListMessagesBetween(roomID, since, time.Time{})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[roomID] = err
+			}
+			if len(ms) > 0 {
+				messages[roomID] = ms
+			}
+		}(r.ID)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return messages, errs
+	}
+	return messages, nil
+}
+
+// Before and BeforeMessageID are mutually exclusive, and listing fails if both are set.  BeforeTime and BeforeMessage
+// set one of them while clearing the other, so that can't happen by accident.  After bounds the results from below,
+// and can be combined with either of them.
 type MessageListParams struct {
 	MentionedPeople string
 	Before          time.Time
 	BeforeMessageID string
+	After           time.Time
+
+	// Setting one of these lists the 1:1 messages with the person instead of a room's messages; see ListMessages
+	PersonID    string
+	PersonEmail string
+
+	// Lists only the replies to the message with this ID
+	ParentID string
+}
+
+// BeforeTime bounds the results from above by time, clearing BeforeMessageID.  It returns the params, for chaining.
+func (m *MessageListParams) BeforeTime(t time.Time) *MessageListParams {
+	m.Before = t
+	m.BeforeMessageID = ""
+	return m
+}
+
+// BeforeMessage bounds the results from above by a message, listing only those sent before it, and clears Before.  It
+// returns the params, for chaining.
+func (m *MessageListParams) BeforeMessage(messageID string) *MessageListParams {
+	m.BeforeMessageID = messageID
+	m.Before = time.Time{}
+	return m
+}
+
+// Reports whether the params list direct messages with a person, rather than a room's messages.
+func (m *MessageListParams) direct() bool {
+	return m != nil && (m.PersonID != "" || m.PersonEmail != "")
+}
+
+// Returns the URL that messages matching the params are listed from.
+func (m *MessageListParams) endpoint() string {
+	if m.direct() {
+		return DirectMessagesURL
+	}
+	return MessagesURL
+}
+
+func (m *MessageListParams) validate(roomID string) error {
+	if m.direct() {
+		if roomID != "" {
+			return fmt.Errorf("a room ID can't be combined with a person ID or email")
+		}
+		if m.PersonID != "" && m.PersonEmail != "" {
+			return fmt.Errorf("person ID and person email can't both be specified")
+		}
+		if m.MentionedPeople != "" || !m.Before.IsZero() || m.BeforeMessageID != "" || !m.After.IsZero() {
+			return fmt.Errorf("direct messages can only be filtered by person and parent")
+		}
+		return nil
+	}
+
+	if roomID == "" {
+		return fmt.Errorf("no room ID specified")
+	}
+	if m != nil && !m.Before.IsZero() && m.BeforeMessageID != "" {
+		return fmt.Errorf("before and beforeMessage can't both be specified")
+	}
+	return nil
 }
 
 func (m *MessageListParams) values(roomID string) url.Values {
 	uv := make(url.Values)
+	if m.direct() {
+		if m.PersonID != "" {
+			uv.Add("personId", m.PersonID)
+		}
+		if m.PersonEmail != "" {
+			uv.Add("personEmail", m.PersonEmail)
+		}
+		if m.ParentID != "" {
+			uv.Add("parentId", m.ParentID)
+		}
+		return uv
+	}
 	uv.Add("roomId", roomID)
 
 	if m == nil {
@@ -125,11 +958,17 @@ func (m *MessageListParams) values(roomID string) url.Values {
 	if m.MentionedPeople != "" {
 		uv.Add("mentionedPeople", m.MentionedPeople)
 	}
-	if m.Before != (time.Time{}) { // zero value
-		uv.Add("before", m.Before.Format(time.RFC3339))
-	}
+	// validate rejects setting both, but if it's skipped, the message wins, since it's the more precise bound
 	if m.BeforeMessageID != "" {
 		uv.Add("beforeMessage", m.BeforeMessageID)
+	} else if !m.Before.IsZero() {
+		uv.Add("before", m.Before.Format(time.RFC3339))
+	}
+	if !m.After.IsZero() {
+		uv.Add("after", m.After.Format(time.RFC3339))
+	}
+	if m.ParentID != "" {
+		uv.Add("parentId", m.ParentID)
 	}
 
 	return uv
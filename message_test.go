@@ -2,11 +2,16 @@ package spark
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 
 	"strings"
+	"sync"
 
 	"time"
 
@@ -76,6 +81,18 @@ var _ = Describe("Message (Mock)", func() {
 			Expect(c.GetMessage(messageID)).To(Equal(messages.Items[0]))
 		})
 
+		It("treats an empty 200 body as a successful empty result", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(&bytes.Buffer{}), // empty body
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.GetMessage("1")).To(Equal(&Message{}))
+		})
+
 		It("fails if no message ID is specified", func() {
 			p, err := c.GetMessage("")
 			Expect(err).To(MatchError("no message ID specified"))
@@ -92,6 +109,45 @@ var _ = Describe("Message (Mock)", func() {
 		})
 	})
 
+	Describe("GetMessageRaw", func() {
+		It("returns fields the Message struct doesn't model", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/%s", MessagesURL, "1")))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"id":"1","unmodeledField":"hello"}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			raw, err := c.GetMessageRaw("1")
+			Expect(err).ToNot(HaveOccurred())
+
+			var fields map[string]interface{}
+			Expect(json.Unmarshal(raw, &fields)).To(Succeed())
+			Expect(fields["id"]).To(Equal("1"))
+			Expect(fields["unmodeledField"]).To(Equal("hello"))
+		})
+
+		It("fails if no message ID is specified", func() {
+			raw, err := c.GetMessageRaw("")
+			Expect(err).To(MatchError("no message ID specified"))
+			Expect(raw).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			raw, err := c.GetMessageRaw("1")
+			Expect(err).To(MatchError(mockErr))
+			Expect(raw).To(BeNil())
+		})
+	})
+
 	Describe("ListMessages", func() {
 		It("gets a list of messages", func() {
 			max := len(messages.Items)
@@ -251,12 +307,54 @@ var _ = Describe("Message (Mock)", func() {
 			Expect(calls).To(BeEquivalentTo(10))
 		})
 
+		It("pages backward by the oldest message received, without skipping or repeating any", func() {
+			// The room's history, newest first
+			var history []*Message
+			for i := 7; i > 0; i-- {
+				history = append(history, &Message{ID: fmt.Sprintf("%d", i), RoomID: "123"})
+			}
+			perPage := 3
+			c = c.SetMaxPerPage(perPage)
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				// Serve the messages older than beforeMessage, like the messages endpoint does
+				start := 0
+				if before := req.URL.Query().Get("beforeMessage"); before != "" {
+					for i, m := range history {
+						if m.ID == before {
+							start = i + 1
+						}
+					}
+				}
+				end := start + perPage
+				if end > len(history) {
+					end = len(history)
+				}
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(MessageList{Items: history[start:end]})).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				if end < len(history) {
+					// A cursor that doesn't line up with the page boundaries, and would skip messages if trusted
+					r.Header = map[string][]string{
+						"Link": {fmt.Sprintf("<%s?roomId=123&beforeMessage=%s>; rel=\"next\"", MessagesURL, history[end].ID)},
+					}
+				}
+				return r, nil
+			}
+
+			Expect(c.ListMessages(0, "123", nil)).To(Equal(history))
+		})
+
 		It("applies a parameter list", func() {
 			max := len(messages.Items)
 			params := MessageListParams{
 				MentionedPeople: "mentioned",
-				Before:          time.Now(),
 				BeforeMessageID: "befoire",
+				After:           time.Now(),
 			}
 			roomID := "123"
 
@@ -284,12 +382,86 @@ var _ = Describe("Message (Mock)", func() {
 			Expect(c.ListMessages(max, roomID, &params)).To(ConsistOf(messages.Items))
 		})
 
+		It("serializes the after parameter", func() {
+			after := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+			uv := (&MessageListParams{After: after}).values("123")
+			Expect(uv.Get("after")).To(Equal("2018-06-01T12:00:00Z"))
+			Expect(uv).ToNot(HaveKey("before"))
+		})
+
+		It("fails if both before and beforeMessage are provided", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Fail("unexpected call to http.Client.Do()")
+				return nil, nil
+			}
+
+			m, err := c.ListMessages(0, "123", &MessageListParams{Before: time.Now(), BeforeMessageID: "1"})
+			Expect(err).To(MatchError("before and beforeMessage can't both be specified"))
+			Expect(m).To(BeNil())
+		})
+
+		It("sets one upper bound at a time with the builders", func() {
+			before := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+			params := (&MessageListParams{}).BeforeMessage("1")
+			Expect(params.validate("123")).To(Succeed())
+			uv := params.values("123")
+			Expect(uv.Get("beforeMessage")).To(Equal("1"))
+			Expect(uv).ToNot(HaveKey("before"))
+
+			params = params.BeforeTime(before)
+			Expect(params.BeforeMessageID).To(BeEmpty())
+			Expect(params.validate("123")).To(Succeed())
+			uv = params.values("123")
+			Expect(uv.Get("before")).To(Equal("2018-06-01T12:00:00Z"))
+			Expect(uv).ToNot(HaveKey("beforeMessage"))
+
+			params = params.BeforeMessage("2")
+			Expect(params.Before.IsZero()).To(BeTrue())
+			Expect(params.values("123").Get("beforeMessage")).To(Equal("2"))
+		})
+
+		It("prefers beforeMessage if both bounds are set anyway", func() {
+			uv := (&MessageListParams{Before: time.Now(), BeforeMessageID: "1"}).values("123")
+			Expect(uv.Get("beforeMessage")).To(Equal("1"))
+			Expect(uv).ToNot(HaveKey("before"))
+		})
+
 		It("fails if an empty room ID is provided", func() {
 			p, err := c.ListMessages(0, "", nil)
 			Expect(err).To(MatchError("no room ID specified"))
 			Expect(p).To(BeNil())
 		})
 
+		It("returns an empty, non-nil slice when there are no results", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"items":[]}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			p, err := c.ListMessages(0, "123", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p).ToNot(BeNil())
+			Expect(p).To(BeEmpty())
+		})
+
+		It("fails with a nil slice if the results can't be decoded", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString("not json")),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			p, err := c.ListMessages(0, "123", nil)
+			Expect(err).To(HaveOccurred())
+			Expect(p).To(BeNil())
+		})
+
 		It("passes through errors encountered during the request", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
 				return nil, mockErr
@@ -300,158 +472,1302 @@ var _ = Describe("Message (Mock)", func() {
 		})
 	})
 
-	Describe("CreateMessage", func() {
-		var n NewMessage
+	Describe("MessageListParams", func() {
+		It("builds values with only the room ID from nil params", func() {
+			var params *MessageListParams
+			Expect(params.values("123")).To(Equal(url.Values{"roomId": {"123"}}))
+			Expect(params.validate("123")).To(Succeed())
+		})
 
-		BeforeEach(func() {
-			// Wash it through the json package, because honestly that's the easiest way to copy a struct to
-			// a struct with a subset of the same fields
-			var b bytes.Buffer
-			Expect(json.NewEncoder(&b).Encode(messages.Items[0])).To(Succeed())
-			Expect(json.NewDecoder(&b).Decode(&n)).To(Succeed())
-			n.ToPersonID = messages.Items[0].PersonID
-			n.ToPersonEmail = messages.Items[0].PersonEmail
+		It("builds values with only the person for direct messages", func() {
+			Expect((&MessageListParams{PersonID: "456"}).values("")).To(Equal(url.Values{"personId": {"456"}}))
+			Expect((&MessageListParams{PersonEmail: "you@world.com"}).values("")).To(Equal(url.Values{"personEmail": {"you@world.com"}}))
+		})
+	})
 
-			Expect(n.RoomID).To(Equal(messages.Items[0].RoomID))
-			Expect(n.ToPersonID).To(Equal(messages.Items[0].PersonID))
-			Expect(n.ToPersonEmail).To(Equal(messages.Items[0].PersonEmail))
-			Expect(n.Text).To(Equal(messages.Items[0].Text))
-			Expect(n.Markdown).To(Equal(messages.Items[0].Markdown))
-			Expect(n.Files).To(Equal(messages.Items[0].Files))
+	Describe("direct messages", func() {
+		It("lists the messages with a person from the direct endpoint", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(strings.Split(req.URL.String(), "?")[0]).To(Equal(DirectMessagesURL))
+				Expect(req.URL.Query().Get("personEmail")).To(Equal("you@world.com"))
+				Expect(req.URL.Query()).ShouldNot(HaveKey("roomId"))
+				Expect(req.Method).To(Equal("GET"))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(messages)).To(Succeed())
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+
+			Expect(c.ListMessages(0, "", &MessageListParams{PersonEmail: "you@world.com"})).To(Equal(messages.Items))
 		})
 
-		It("creates a message", func() {
+		It("follows the direct endpoint's next links as is, since it doesn't take beforeMessage", func() {
+			c = c.SetMaxPerPage(1)
+			next := fmt.Sprintf("%s?personEmail=you%%40world.com&cursor=abc&max=1", DirectMessagesURL)
+
+			var queries []url.Values
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				Expect(req.URL.String()).To(Equal(MessagesURL))
-				Expect(req.Method).To(Equal("POST"))
-				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				Expect(strings.Split(req.URL.String(), "?")[0]).To(Equal(DirectMessagesURL))
+				queries = append(queries, req.URL.Query())
 
-				var p NewMessage
-				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
-				Expect(p).To(Equal(n))
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(MessageList{Items: messages.Items[len(queries)-1 : len(queries)]})).To(Succeed())
+				r := &http.Response{Body: closer(&b), StatusCode: http.StatusOK, Header: http.Header{}}
+				if len(queries) == 1 {
+					r.Header.Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next))
+				}
+				return r, nil
+			}
+
+			Expect(c.ListMessages(0, "", &MessageListParams{PersonEmail: "you@world.com"})).To(Equal(messages.Items[:2]))
+			Expect(queries).To(HaveLen(2))
+			Expect(queries[1]).To(Equal(url.Values{"personEmail": {"you@world.com"}, "cursor": {"abc"}, "max": {"1"}}))
+
+			queries = nil
+			_, links, err := c.ListMessagesSingle(1, "", &MessageListParams{PersonEmail: "you@world.com"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(links.Next).To(Equal(next))
+		})
+
+		It("still lists a room's messages from the messages endpoint", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(strings.Split(req.URL.String(), "?")[0]).To(Equal(MessagesURL))
+				Expect(req.URL.Query().Get("roomId")).To(Equal("123"))
+				Expect(req.URL.Query()).ShouldNot(HaveKey("personId"))
 
 				var b bytes.Buffer
-				Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+				Expect(json.NewEncoder(&b).Encode(messages)).To(Succeed())
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+
+			Expect(c.ListMessages(0, "123", &MessageListParams{})).To(Equal(messages.Items))
+		})
+
+		It("fails if a room ID is combined with a person", func() {
+			m, err := c.ListMessages(0, "123", &MessageListParams{PersonID: "456"})
+			Expect(err).To(MatchError("a room ID can't be combined with a person ID or email"))
+			Expect(m).To(BeNil())
+		})
+
+		It("fails if both a person ID and email are provided", func() {
+			m, err := c.ListMessages(0, "", &MessageListParams{PersonID: "456", PersonEmail: "you@world.com"})
+			Expect(err).To(MatchError("person ID and person email can't both be specified"))
+			Expect(m).To(BeNil())
+		})
+
+		It("fails if room filters are combined with a person", func() {
+			m, err := c.ListMessages(0, "", &MessageListParams{PersonID: "456", Before: time.Now()})
+			Expect(err).To(MatchError("direct messages can only be filtered by person and parent"))
+			Expect(m).To(BeNil())
+
+			_, _, err = c.ListMessagesSingle(1, "", &MessageListParams{PersonID: "456", MentionedPeople: "me"})
+			Expect(err).To(MatchError("direct messages can only be filtered by person and parent"))
+		})
+	})
+
+	Describe("ListMessagesTruncated", func() {
+		var calls int
+
+		// Serves the fixture's messages one per page, with a next link on every page but the last
+		BeforeEach(func() {
+			c = c.SetMaxPerPage(1)
+
+			calls = 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(MessageList{Items: messages.Items[calls : calls+1]})).To(Succeed())
 				r := &http.Response{
 					Body:       closer(&b),
 					StatusCode: http.StatusOK,
 				}
+				calls++
+				if calls < len(messages.Items) {
+					r.Header = map[string][]string{
+						"Link": {fmt.Sprintf("<%s?roomId=123>; rel=\"next\"", MessagesURL)},
+					}
+				}
 				return r, nil
 			}
+		})
 
-			Expect(c.CreateMessage(&n)).To(Equal(messages.Items[1]))
+		It("reports a list that hit max while more messages were available", func() {
+			ms, truncated, err := c.ListMessagesTruncated(2, "123", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ms).To(Equal(messages.Items[:2]))
+			Expect(truncated).To(BeTrue())
+			Expect(calls).To(Equal(2))
 		})
 
-		It("allows an empty room ID", func() {
-			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				Expect(req.URL.String()).To(Equal(MessagesURL))
-				Expect(req.Method).To(Equal("POST"))
-				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+		It("doesn't report a list that the server ran out of", func() {
+			ms, truncated, err := c.ListMessagesTruncated(5, "123", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ms).To(Equal(messages.Items))
+			Expect(truncated).To(BeFalse())
+			Expect(calls).To(Equal(3))
+		})
 
-				var p NewMessage
-				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
-				Expect(p).To(Equal(n))
+		It("doesn't report a list that ran out exactly at max", func() {
+			ms, truncated, err := c.ListMessagesTruncated(3, "123", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ms).To(Equal(messages.Items))
+			Expect(truncated).To(BeFalse())
+		})
+
+		It("doesn't report a list of every message", func() {
+			ms, truncated, err := c.ListMessagesTruncated(0, "123", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ms).To(Equal(messages.Items))
+			Expect(truncated).To(BeFalse())
+		})
+	})
+
+	Describe("ListMessagesSingle", func() {
+		It("requests exactly max messages in a single request, and continues from the oldest one", func() {
+			c = c.SetMaxPerPage(1)
+			before := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				uri := strings.Split(req.URL.String(), "?")[0]
+				Expect(uri).To(Equal(MessagesURL))
+				Expect(req.URL.Query().Get("max")).To(Equal("3"))
+				Expect(req.URL.Query().Get("roomId")).To(Equal("123"))
+				calls++
 
 				var b bytes.Buffer
-				Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+				Expect(json.NewEncoder(&b).Encode(messages)).To(Succeed())
 				r := &http.Response{
 					Body:       closer(&b),
 					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s?roomId=123&max=3&before=%s>; rel=\"next\"", MessagesURL, before.Format(time.RFC3339))},
+					},
 				}
 				return r, nil
 			}
-			n.RoomID = ""
 
-			Expect(c.CreateMessage(&n)).To(Equal(messages.Items[1]))
+			ms, links, err := c.ListMessagesSingle(3, "123", &MessageListParams{Before: before})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ms).To(Equal(messages.Items))
+			Expect(calls).To(Equal(1))
+
+			next, err := url.Parse(links.Next)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(next.Query().Get("beforeMessage")).To(Equal("3"))
+			Expect(next.Query()).ShouldNot(HaveKey("before"))
+			Expect(next.Query().Get("roomId")).To(Equal("123"))
 		})
 
-		It("allows an empty person email", func() {
+		It("fails if no room ID is specified", func() {
+			_, _, err := c.ListMessagesSingle(3, "", nil)
+			Expect(err).To(MatchError(fmt.Errorf("no room ID specified")))
+		})
+	})
+
+	Describe("ListMessagesBetween", func() {
+		var (
+			base time.Time
+			from time.Time
+			to   time.Time
+		)
+
+		BeforeEach(func() {
+			base = time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+			from = base.Add(-90 * time.Minute)
+			to = base.Add(time.Hour)
+
+			// Newest first, an hour apart, from base back to 5 hours before it
+			messages.Items = nil
+			for i := 0; i < 6; i++ {
+				messages.Items = append(messages.Items, &Message{
+					ID:      fmt.Sprintf("%d", i),
+					RoomID:  "123",
+					Created: Time{base.Add(time.Duration(-i) * time.Hour)},
+				})
+			}
+		})
+
+		It("pages backward until it passes the start of the window", func() {
+			perPage := 2
+
+			calls := 0
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				Expect(req.URL.String()).To(Equal(MessagesURL))
-				Expect(req.Method).To(Equal("POST"))
+				uri := strings.Split(req.URL.String(), "?")[0]
+				Expect(uri).To(Equal(MessagesURL))
+				Expect(req.URL.Query().Get("roomId")).To(Equal("123"))
+				if calls == 0 {
+					Expect(req.URL.Query().Get("before")).To(Equal(to.Format(time.RFC3339)))
+				} else {
+					// later pages continue from the oldest message received instead
+					Expect(req.URL.Query()).ShouldNot(HaveKey("before"))
+					Expect(req.URL.Query().Get("beforeMessage")).To(Equal(messages.Items[calls*perPage-1].ID))
+				}
+				Expect(req.URL.Query().Get("after")).To(Equal(from.Format(time.RFC3339)))
+				Expect(req.Method).To(Equal("GET"))
 				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
 
-				var p NewMessage
-				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
-				Expect(p).To(Equal(n))
+				p := MessageList{
+					Items: messages.Items[calls*perPage : (calls+1)*perPage],
+				}
 
 				var b bytes.Buffer
-				Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+				Expect(json.NewEncoder(&b).Encode(p)).To(Succeed())
 				r := &http.Response{
 					Body:       closer(&b),
 					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s?roomId=123>; rel=\"next\"", MessagesURL)},
+					},
 				}
+				calls++
 				return r, nil
 			}
-			n.ToPersonEmail = ""
 
-			Expect(c.CreateMessage(&n)).To(Equal(messages.Items[1]))
+			// base, base-1h (page 1), then base-2h is older than from, so paging stops on page 2
+			Expect(c.ListMessagesBetween("123", from, to)).To(Equal(messages.Items[:2]))
+			Expect(calls).To(Equal(2))
 		})
 
-		It("allows an empty person ID", func() {
+		It("handles a room that runs out of messages before the window starts", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				Expect(req.URL.String()).To(Equal(MessagesURL))
-				Expect(req.Method).To(Equal("POST"))
-				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
-
-				var p NewMessage
-				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
-				Expect(p).To(Equal(n))
-
 				var b bytes.Buffer
-				Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+				Expect(json.NewEncoder(&b).Encode(messages)).To(Succeed())
 				r := &http.Response{
 					Body:       closer(&b),
 					StatusCode: http.StatusOK,
 				}
 				return r, nil
 			}
-			n.ToPersonID = ""
 
-			Expect(c.CreateMessage(&n)).To(Equal(messages.Items[1]))
+			Expect(c.ListMessagesBetween("123", base.Add(-24*time.Hour), time.Time{})).To(Equal(messages.Items))
 		})
 
-		It("fails if a nil argument is provided", func() {
-			p, err := c.CreateMessage(nil)
-			Expect(err).To(MatchError("nil message"))
-			Expect(p).To(BeNil())
+		It("fails if an empty room ID is provided", func() {
+			m, err := c.ListMessagesBetween("", from, to)
+			Expect(err).To(MatchError("no room ID specified"))
+			Expect(m).To(BeNil())
 		})
 
-		It("fails if room ID, person ID, *and* person email are all empty", func() {
-			n.RoomID = ""
-			n.ToPersonEmail = ""
-			n.ToPersonID = ""
-
-			p, err := c.CreateMessage(&n)
-			Expect(err).To(MatchError("message requires a room ID, person ID, or email to send to"))
-			Expect(p).To(BeNil())
+		It("fails if the window ends before it starts", func() {
+			m, err := c.ListMessagesBetween("123", to, from)
+			Expect(err).To(MatchError("end of time window is before its start"))
+			Expect(m).To(BeNil())
 		})
 
 		It("passes through errors encountered during the request", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
 				return nil, mockErr
 			}
-			p, err := c.CreateMessage(&n)
+			m, err := c.ListMessagesBetween("123", from, to)
 			Expect(err).To(MatchError(mockErr))
-			Expect(p).To(BeNil())
+			Expect(m).To(BeNil())
 		})
 	})
 
-	Describe("DeleteMessage", func() {
-		It("deletes a message", func() {
+	Describe("ListMyMentions", func() {
+		var base time.Time
+
+		BeforeEach(func() {
+			base = time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+			// Newest first, an hour apart, from base back to 3 hours before it
+			messages.Items = nil
+			for i := 0; i < 4; i++ {
+				messages.Items = append(messages.Items, &Message{
+					ID:      fmt.Sprintf("%d", i),
+					RoomID:  "123",
+					Created: Time{base.Add(time.Duration(-i) * time.Hour)},
+				})
+			}
+		})
+
+		It("lists the messages mentioning the client since a time, stopping once it passes it", func() {
+			calls := 0
+			since := base.Add(-90 * time.Minute)
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
-				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/%s", MessagesURL, messages.Items[0].ID)))
-				Expect(req.Method).To(Equal("DELETE"))
-				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				Expect(strings.Split(req.URL.String(), "?")[0]).To(Equal(MessagesURL))
+				Expect(req.URL.Query().Get("roomId")).To(Equal("123"))
+				Expect(req.URL.Query().Get("mentionedPeople")).To(Equal("me"))
+				Expect(req.URL.Query().Get("after")).To(Equal(since.Format(time.RFC3339)))
 
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(MessageList{Items: messages.Items[calls*2 : (calls+1)*2]})).To(Succeed())
 				r := &http.Response{
-					Body:       closer(&bytes.Buffer{}), // empty body
-					StatusCode: http.StatusNoContent,    // deletes are weird and return 204 instead of 200
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Link": {fmt.Sprintf("<%s?roomId=123&mentionedPeople=me>; rel=\"next\"", MessagesURL)}},
 				}
+				calls++
 				return r, nil
 			}
 
-			Expect(c.DeleteMessage(messages.Items[0].ID)).To(Succeed())
+			Expect(c.ListMyMentions("123", since)).To(Equal(messages.Items[:2]))
+			Expect(calls).To(Equal(2))
+		})
+
+		It("de-duplicates mentions repeated across pages, if enabled", func() {
+			pages := [][]*Message{messages.Items[:2], messages.Items[1:3]} // the data shifted
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(MessageList{Items: pages[calls]})).To(Succeed())
+				r := &http.Response{Body: closer(&b), StatusCode: http.StatusOK, Header: http.Header{}}
+				if calls++; calls < len(pages) {
+					r.Header.Set("Link", fmt.Sprintf("<%s?roomId=123&mentionedPeople=me>; rel=\"next\"", MessagesURL))
+				}
+				return r, nil
+			}
+
+			Expect(c.SetDeduplication(true).ListMyMentions("123", time.Time{})).To(Equal(messages.Items[:3]))
+		})
+
+		It("lists every mention for a zero time", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Query().Get("mentionedPeople")).To(Equal("me"))
+				Expect(req.URL.Query()).ToNot(HaveKey("after"))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(messages)).To(Succeed())
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+
+			Expect(c.ListMyMentions("123", time.Time{})).To(Equal(messages.Items))
+		})
+
+		It("fails if an empty room ID is provided", func() {
+			m, err := c.ListMyMentions("", base)
+			Expect(err).To(MatchError("no room ID specified"))
+			Expect(m).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			m, err := c.ListMyMentions("123", base)
+			Expect(err).To(MatchError(mockErr))
+			Expect(m).To(BeNil())
+		})
+	})
+
+	Describe("GetThread", func() {
+		var (
+			parent  *Message
+			replies []*Message
+			solo    *Message
+		)
+
+		BeforeEach(func() {
+			parent = &Message{ID: "parent", RoomID: "room", Text: "question"}
+			replies = []*Message{
+				{ID: "reply-2", RoomID: "room", ParentID: "parent", Text: "second answer"},
+				{ID: "reply-1", RoomID: "room", ParentID: "parent", Text: "first answer"},
+			}
+			solo = &Message{ID: "solo", RoomID: "room", Text: "hello"}
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.Method).To(Equal("GET"))
+
+				var v interface{}
+				switch uri := strings.Split(req.URL.String(), "?")[0]; uri {
+				case MessagesURL:
+					Expect(req.URL.Query().Get("roomId")).To(Equal("room"))
+					if req.URL.Query().Get("parentId") == "parent" {
+						v = MessageList{Items: replies}
+					} else {
+						v = MessageList{}
+					}
+				case MessagesURL + "/parent":
+					v = parent
+				case MessagesURL + "/reply-1":
+					v = replies[1]
+				case MessagesURL + "/solo":
+					v = solo
+				default:
+					Fail("unexpected request to " + uri)
+				}
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(v)).To(Succeed())
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+		})
+
+		It("gets the thread of a parent message", func() {
+			p, r, err := c.GetThread("parent")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(p).To(Equal(parent))
+			Expect(r).To(Equal(replies))
+		})
+
+		It("gets the whole thread of a reply", func() {
+			p, r, err := c.GetThread("reply-1")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(p).To(Equal(parent))
+			Expect(r).To(Equal(replies))
+		})
+
+		It("treats a message that isn't in a thread as its own parent", func() {
+			p, r, err := c.GetThread("solo")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(p).To(Equal(solo))
+			Expect(r).ToNot(BeNil())
+			Expect(r).To(BeEmpty())
+		})
+
+		It("sends the parent ID of a reply", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var n NewMessage
+				Expect(json.NewDecoder(req.Body).Decode(&n)).To(Succeed())
+				Expect(n.ParentID).To(Equal("parent"))
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"reply-3","parentId":"parent"}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			m, err := c.CreateMessage(&NewMessage{RoomID: "room", ParentID: "parent", Text: "third answer"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(m.ParentID).To(Equal("parent"))
+		})
+
+		It("fails if no message ID is specified", func() {
+			p, r, err := c.GetThread("")
+			Expect(err).To(MatchError("no message ID specified"))
+			Expect(p).To(BeNil())
+			Expect(r).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			p, r, err := c.GetThread("reply-1")
+			Expect(err).To(MatchError(mockErr))
+			Expect(p).To(BeNil())
+			Expect(r).To(BeNil())
+		})
+	})
+
+	Describe("FindMessages", func() {
+		var calls int
+
+		BeforeEach(func() {
+			// Newest first, with every third message mentioning a deploy
+			messages.Items = nil
+			for i := 0; i < 7; i++ {
+				text := fmt.Sprintf("message %d", i)
+				if i%3 == 0 {
+					text += " about the deploy"
+				}
+				messages.Items = append(messages.Items, &Message{ID: fmt.Sprintf("%d", i), RoomID: "123", Text: text})
+			}
+
+			// Pages of 2, continuing from the beforeMessage each request asks for
+			calls = 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Query().Get("roomId")).To(Equal("123"))
+				start := 0
+				if before := req.URL.Query().Get("beforeMessage"); before != "" {
+					Expect(calls).To(BeNumerically(">", 0))
+					fmt.Sscanf(before, "%d", &start)
+					start++
+				}
+				end := start + 2
+				if end > len(messages.Items) {
+					end = len(messages.Items)
+				}
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(MessageList{Items: messages.Items[start:end]})).To(Succeed())
+				r := &http.Response{Body: closer(&b), StatusCode: http.StatusOK, Header: http.Header{}}
+				if end < len(messages.Items) {
+					r.Header.Set("Link", fmt.Sprintf("<%s?roomId=123&cursor=%d>; rel=\"next\"", MessagesURL, end))
+				}
+				calls++
+				return r, nil
+			}
+		})
+
+		aboutDeploy := func(m *Message) bool { return strings.Contains(m.Text, "deploy") }
+
+		It("pages backward through the room, stopping once it has enough matches", func() {
+			found, err := c.FindMessages("123", aboutDeploy, 2)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(found).To(Equal([]*Message{messages.Items[0], messages.Items[3]}))
+			Expect(calls).To(Equal(2)) // message 3 is on the second page, so the rest of the room isn't requested
+		})
+
+		It("searches the whole room if there's no limit, or not enough matches", func() {
+			all := []*Message{messages.Items[0], messages.Items[3], messages.Items[6]}
+
+			found, err := c.FindMessages("123", aboutDeploy, 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(found).To(Equal(all))
+			Expect(calls).To(Equal(4))
+
+			found, err = c.FindMessages("123", aboutDeploy, 10)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(found).To(Equal(all))
+		})
+
+		It("returns an empty, non-nil slice when nothing matches", func() {
+			found, err := c.FindMessages("123", func(*Message) bool { return false }, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(found).ToNot(BeNil())
+			Expect(found).To(BeEmpty())
+		})
+
+		It("fails if an empty room ID or no match function is provided", func() {
+			_, err := c.FindMessages("", aboutDeploy, 1)
+			Expect(err).To(MatchError("no room ID specified"))
+			_, err = c.FindMessages("123", nil, 1)
+			Expect(err).To(MatchError("no match function specified"))
+			Expect(calls).To(Equal(0))
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			found, err := c.FindMessages("123", aboutDeploy, 1)
+			Expect(err).To(MatchError(mockErr))
+			Expect(found).To(BeNil())
+		})
+	})
+
+	Describe("ListAllRoomMessages", func() {
+		var (
+			since    time.Time
+			roomMsgs map[string][]*Message
+			mu       sync.Mutex
+			queried  []string
+		)
+
+		BeforeEach(func() {
+			since = time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+			roomMsgs = map[string][]*Message{
+				"room 1": {
+					{ID: "1", RoomID: "room 1", Created: Time{since.Add(2 * time.Hour)}},
+					{ID: "2", RoomID: "room 1", Created: Time{since.Add(time.Hour)}},
+					{ID: "3", RoomID: "room 1", Created: Time{since.Add(-time.Hour)}},
+				},
+				"room 2": {
+					{ID: "4", RoomID: "room 2", Created: Time{since.Add(time.Minute)}},
+				},
+			}
+			queried = nil
+
+			// Called from several goroutines at once, so it can't make assertions
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var v interface{}
+				switch strings.Split(req.URL.String(), "?")[0] {
+				case RoomsURL:
+					v = RoomList{Items: []*Room{
+						{ID: "room 1", LastActivity: Time{since.Add(2 * time.Hour)}},
+						{ID: "room 2"},
+						{ID: "room 3", LastActivity: Time{since.Add(-time.Hour)}}, // inactive, so shouldn't be queried
+						{ID: "room 4", LastActivity: Time{since.Add(time.Hour)}},
+					}}
+				case MessagesURL:
+					roomID := req.URL.Query().Get("roomId")
+					mu.Lock()
+					queried = append(queried, roomID)
+					mu.Unlock()
+					v = MessageList{Items: roomMsgs[roomID]}
+				}
+
+				var b bytes.Buffer
+				if err := json.NewEncoder(&b).Encode(v); err != nil {
+					return nil, err
+				}
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+		})
+
+		It("lists the new messages in every room, keyed by room ID", func() {
+			all, err := c.ListAllRoomMessages(since)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(all).To(Equal(map[string][]*Message{
+				"room 1": roomMsgs["room 1"][:2],
+				"room 2": roomMsgs["room 2"],
+			}))
+			Expect(queried).To(ConsistOf("room 1", "room 2", "room 4"))
+		})
+
+		It("returns the rooms that succeeded along with the errors of those that failed", func() {
+			succeed := mockCli.DoFunc
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				if req.URL.Query().Get("roomId") == "room 2" {
+					return nil, mockErr
+				}
+				return succeed(req)
+			}
+
+			all, err := c.ListAllRoomMessages(since)
+			var errs RoomErrors
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs).To(HaveLen(1))
+			Expect(errs["room 2"]).To(MatchError(mockErr))
+			Expect(err.Error()).To(Equal("room room 2: sending request: mock error"))
+			Expect(all).To(Equal(map[string][]*Message{
+				"room 1": roomMsgs["room 1"][:2],
+			}))
+		})
+
+		It("passes through errors encountered listing the rooms", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			all, err := c.ListAllRoomMessages(since)
+			Expect(err).To(MatchError(mockErr))
+			Expect(all).To(BeNil())
+		})
+	})
+
+	Describe("IsSelfAuthored", func() {
+		var calls int
+
+		BeforeEach(func() {
+			calls = 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/me", PeopleURL)))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(Person{ID: messages.Items[0].PersonID})).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+		})
+
+		It("reports a message sent by the authenticated identity", func() {
+			Expect(c.IsSelfAuthored(context.Background(), messages.Items[0])).To(BeTrue())
+		})
+
+		It("reports a message sent by someone else", func() {
+			Expect(c.IsSelfAuthored(context.Background(), messages.Items[1])).To(BeFalse())
+		})
+
+		It("only looks up the authenticated identity once", func() {
+			Expect(c.IsSelfAuthored(context.Background(), messages.Items[0])).To(BeTrue())
+			Expect(c.IsSelfAuthored(context.Background(), messages.Items[1])).To(BeFalse())
+			Expect(c.SetMaxPerPage(10).IsSelfAuthored(context.Background(), messages.Items[0])).To(BeTrue())
+			Expect(calls).To(Equal(1))
+		})
+
+		It("fails if a nil message is provided", func() {
+			self, err := c.IsSelfAuthored(context.Background(), nil)
+			Expect(err).To(MatchError("nil message"))
+			Expect(self).To(BeFalse())
+		})
+
+		It("passes through errors encountered during the request, and doesn't cache them", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				return nil, mockErr
+			}
+			self, err := c.IsSelfAuthored(context.Background(), messages.Items[0])
+			Expect(err).To(MatchError(mockErr))
+			Expect(self).To(BeFalse())
+
+			_, err = c.IsSelfAuthored(context.Background(), messages.Items[0])
+			Expect(err).To(MatchError(mockErr))
+			Expect(calls).To(Equal(2))
+		})
+	})
+
+	Describe("CreateMessage", func() {
+		var n NewMessage
+
+		BeforeEach(func() {
+			// Wash it through the json package, because honestly that's the easiest way to copy a struct to
+			// a struct with a subset of the same fields
+			var b bytes.Buffer
+			Expect(json.NewEncoder(&b).Encode(messages.Items[0])).To(Succeed())
+			Expect(json.NewDecoder(&b).Decode(&n)).To(Succeed())
+			n.ToPersonID = messages.Items[0].PersonID
+			n.ToPersonEmail = messages.Items[0].PersonEmail
+
+			Expect(n.RoomID).To(Equal(messages.Items[0].RoomID))
+			Expect(n.ToPersonID).To(Equal(messages.Items[0].PersonID))
+			Expect(n.ToPersonEmail).To(Equal(messages.Items[0].PersonEmail))
+			Expect(n.Text).To(Equal(messages.Items[0].Text))
+			Expect(n.Markdown).To(Equal(messages.Items[0].Markdown))
+			Expect(n.Files).To(Equal(messages.Items[0].Files))
+		})
+
+		It("creates a message", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(MessagesURL))
+				Expect(req.Method).To(Equal("POST"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				var p NewMessage
+				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
+				Expect(p).To(Equal(n))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.CreateMessage(&n)).To(Equal(messages.Items[1]))
+		})
+
+		It("merges extra fields into the body, without replacing known ones", func() {
+			extra := n // BeforeEach decodes over n, which wouldn't clear Extra for the next test
+			extra.Extra = map[string]interface{}{
+				"beta":     map[string]interface{}{"enabled": true},
+				"roomId":   "another room",
+				"markdown": "replaced",
+			}
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var p map[string]interface{}
+				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
+				Expect(p).To(HaveKeyWithValue("roomId", n.RoomID))
+				Expect(p).To(HaveKeyWithValue("markdown", n.Markdown))
+				Expect(p).To(HaveKeyWithValue("beta", map[string]interface{}{"enabled": true}))
+				Expect(p).ToNot(HaveKey("Extra"))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+
+			Expect(c.CreateMessage(&extra)).To(Equal(messages.Items[1]))
+		})
+
+		It("doesn't let extra fields fill in empty known ones", func() {
+			b, err := json.Marshal(&NewMessage{RoomID: "1", Text: "hi", Extra: map[string]interface{}{"parentId": "2", "beta": 1}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(MatchJSON(`{"roomId":"1","text":"hi","beta":1}`))
+		})
+
+		It("fails if an extra field can't be marshaled", func() {
+			_, err := c.CreateMessage(&NewMessage{RoomID: "1", Text: "hi", Extra: map[string]interface{}{"beta": func() {}}})
+			Expect(err).To(MatchError(ContainSubstring(`message extra field "beta"`)))
+		})
+
+		It("allows an empty room ID", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(MessagesURL))
+				Expect(req.Method).To(Equal("POST"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				var p NewMessage
+				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
+				Expect(p).To(Equal(n))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+			n.RoomID = ""
+
+			Expect(c.CreateMessage(&n)).To(Equal(messages.Items[1]))
+		})
+
+		It("allows an empty person email", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(MessagesURL))
+				Expect(req.Method).To(Equal("POST"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				var p NewMessage
+				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
+				Expect(p).To(Equal(n))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+			n.ToPersonEmail = ""
+
+			Expect(c.CreateMessage(&n)).To(Equal(messages.Items[1]))
+		})
+
+		It("allows an empty person ID", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(MessagesURL))
+				Expect(req.Method).To(Equal("POST"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				var p NewMessage
+				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
+				Expect(p).To(Equal(n))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+			n.ToPersonID = ""
+
+			Expect(c.CreateMessage(&n)).To(Equal(messages.Items[1]))
+		})
+
+		Describe("with markdown fallback", func() {
+			var sent NewMessage
+
+			BeforeEach(func() {
+				c = c.SetMarkdownFallback(true)
+				mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+					sent = NewMessage{}
+					Expect(json.NewDecoder(req.Body).Decode(&sent)).To(Succeed())
+
+					var b bytes.Buffer
+					Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+					r := &http.Response{
+						Body:       closer(&b),
+						StatusCode: http.StatusOK,
+					}
+					return r, nil
+				}
+			})
+
+			It("fills in the text of a markdown-only message", func() {
+				n.Text = ""
+				n.Markdown = "# Hello **world**"
+
+				Expect(c.CreateMessage(&n)).To(Equal(messages.Items[1]))
+				Expect(sent.Text).To(Equal("Hello world"))
+				Expect(sent.Markdown).To(Equal("# Hello **world**"))
+				Expect(n.Text).To(BeEmpty()) // the caller's message isn't modified
+			})
+
+			It("leaves existing text alone", func() {
+				n.Text = "my own fallback"
+				n.Markdown = "# Hello"
+
+				Expect(c.CreateMessage(&n)).To(Equal(messages.Items[1]))
+				Expect(sent.Text).To(Equal("my own fallback"))
+			})
+
+			It("can be disabled again", func() {
+				n.Text = ""
+				n.Markdown = "# Hello"
+
+				Expect(c.SetMarkdownFallback(false).CreateMessage(&n)).To(Equal(messages.Items[1]))
+				Expect(sent.Text).To(BeEmpty())
+			})
+		})
+
+		It("fails if a nil argument is provided", func() {
+			p, err := c.CreateMessage(nil)
+			Expect(err).To(MatchError("nil message"))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails if room ID, person ID, *and* person email are all empty", func() {
+			n.RoomID = ""
+			n.ToPersonEmail = ""
+			n.ToPersonID = ""
+
+			p, err := c.CreateMessage(&n)
+			Expect(err).To(MatchError("message requires a room ID, person ID, or email to send to"))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails if the message has no text, markdown, or files", func() {
+			p, err := c.CreateMessage(&NewMessage{RoomID: "123"})
+			Expect(err).To(MatchError("message has no content"))
+			Expect(p).To(BeNil())
+		})
+
+		for _, tc := range []struct {
+			name    string
+			content NewMessage
+		}{
+			{"text", NewMessage{Text: "text"}},
+			{"markdown", NewMessage{Markdown: "**markdown**"}},
+			{"files", NewMessage{Files: []string{"https://example.com/file.png"}}},
+		} {
+			content := tc.content
+			It(fmt.Sprintf("sends a message with only %s", tc.name), func() {
+				mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+					var p NewMessage
+					Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
+					Expect(p.Text).To(Equal(content.Text))
+					Expect(p.Markdown).To(Equal(content.Markdown))
+					Expect(p.Files).To(Equal(content.Files))
+
+					var b bytes.Buffer
+					Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+					return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+				}
+
+				m := content
+				m.RoomID = "123"
+				Expect(c.CreateMessage(&m)).To(Equal(messages.Items[1]))
+			})
+		}
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			p, err := c.CreateMessage(&n)
+			Expect(err).To(MatchError(mockErr))
+			Expect(p).To(BeNil())
+		})
+	})
+
+	Describe("classifications", func() {
+		var rooms map[string]*Room
+		var bodies []map[string]interface{}
+
+		BeforeEach(func() {
+			rooms = map[string]*Room{
+				"classified": {ID: "classified", ClassificationID: "secret"},
+				"open":       {ID: "open"},
+			}
+			bodies = nil
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var b bytes.Buffer
+				if req.Method == "GET" {
+					Expect(json.NewEncoder(&b).Encode(rooms[strings.TrimPrefix(req.URL.Path, "/v1/rooms/")])).To(Succeed())
+				} else {
+					var body map[string]interface{}
+					Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+					bodies = append(bodies, body)
+					Expect(json.NewEncoder(&b).Encode(messages.Items[0])).To(Succeed())
+				}
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+		})
+
+		It("sends the classification ID, if there is one", func() {
+			_, err := c.CreateMessage(&NewMessage{RoomID: "classified", Text: "hi", ClassificationID: "secret"})
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = c.CreateMessage(&NewMessage{RoomID: "open", Text: "hi"})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(bodies).To(HaveLen(2))
+			Expect(bodies[0]).To(HaveKeyWithValue("classificationId", "secret"))
+			Expect(bodies[1]).ToNot(HaveKey("classificationId"))
+		})
+
+		It("decodes a message's classification ID", func() {
+			var m Message
+			Expect(json.Unmarshal([]byte(`{"id":"1","classificationId":"secret"}`), &m)).To(Succeed())
+			Expect(m.ClassificationID).To(Equal("secret"))
+		})
+
+		It("doesn't check rooms' classifications by default", func() {
+			_, err := c.CreateMessage(&NewMessage{RoomID: "classified", Text: "hi"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(bodies).To(HaveLen(1))
+		})
+
+		It("refuses to post an unclassified message to a classified room, if enabled", func() {
+			c = c.SetRequireClassification(true)
+
+			_, err := c.CreateMessage(&NewMessage{RoomID: "classified", Text: "hi"})
+			Expect(err).To(MatchError(`room "classified" is classified, so messages posted to it require a classification ID`))
+			Expect(bodies).To(BeEmpty())
+
+			_, err = c.CreateMessage(&NewMessage{RoomID: "classified", Text: "hi", ClassificationID: "secret"})
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = c.CreateMessage(&NewMessage{RoomID: "open", Text: "hi"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(bodies).To(HaveLen(2))
+		})
+	})
+
+	Describe("CreateMessageWithFiles", func() {
+		It("uploads one local file alongside any number of file URLs", func() {
+			m := &NewMessage{
+				RoomID:   "room",
+				Markdown: "**report**",
+				Files:    []string{"https://example.com/a.png", "https://example.com/b.png"},
+			}
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(MessagesURL))
+				Expect(req.Method).To(Equal("POST"))
+				Expect(req.Header.Get("Content-Type")).To(HavePrefix("multipart/form-data; boundary="))
+				Expect(req.ContentLength).To(BeNumerically(">", 0))
+
+				Expect(req.ParseMultipartForm(1 << 20)).To(Succeed())
+				Expect(req.MultipartForm.Value).To(Equal(map[string][]string{
+					"roomId":   {"room"},
+					"markdown": {"**report**"},
+					"files":    {"https://example.com/a.png", "https://example.com/b.png"},
+				}))
+				Expect(req.MultipartForm.File["files"]).To(HaveLen(1))
+				fh := req.MultipartForm.File["files"][0]
+				Expect(fh.Filename).To(Equal(`report "final".csv`))
+				Expect(fh.Header.Get("Content-Type")).To(Equal("text/csv"))
+				f, err := fh.Open()
+				Expect(err).ShouldNot(HaveOccurred())
+				content, err := ioutil.ReadAll(f)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(content)).To(Equal("a,b\n1,2\n"))
+
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1","roomId":"room"}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			upload := &FileUpload{Name: `report "final".csv`, ContentType: "text/csv", Content: strings.NewReader("a,b\n1,2\n")}
+			Expect(c.CreateMessageWithFiles(m, upload)).To(Equal(&Message{ID: "1", RoomID: "room"}))
+		})
+
+		It("doesn't need any text with an upload, and defaults its content type", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.ParseMultipartForm(1 << 20)).To(Succeed())
+				Expect(req.MultipartForm.Value).To(Equal(map[string][]string{"toPersonEmail": {"you@world.com"}}))
+				Expect(req.MultipartForm.File["files"][0].Header.Get("Content-Type")).To(Equal("application/octet-stream"))
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1"}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			_, err := c.CreateMessageWithFiles(&NewMessage{ToPersonEmail: "you@world.com"}, &FileUpload{Name: "blob", Content: strings.NewReader("data")})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("rejects more than one local file", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Fail("unexpected call to http.Client.Do()")
+				return nil, nil
+			}
+
+			a := &FileUpload{Name: "a.txt", Content: strings.NewReader("a")}
+			b := &FileUpload{Name: "b.txt", Content: strings.NewReader("b")}
+			msg, err := c.CreateMessageWithFiles(&NewMessage{RoomID: "room", Text: "two files"}, a, b)
+			Expect(err).To(MatchError(ContainSubstring("messages can only have 1 uploaded file, not 2")))
+			Expect(msg).To(BeNil())
+		})
+
+		It("rejects uploads without a name or content", func() {
+			for _, tc := range []struct {
+				upload *FileUpload
+				err    string
+			}{
+				{nil, "nil file upload"},
+				{&FileUpload{Content: strings.NewReader("a")}, "file upload has no name"},
+				{&FileUpload{Name: "a.txt"}, `file upload "a.txt" has no content`},
+			} {
+				tc := tc
+				_, err := c.CreateMessageWithFiles(&NewMessage{RoomID: "room"}, tc.upload)
+				Expect(err).To(MatchError(tc.err))
+			}
+		})
+
+		It("sends a plain JSON message without any uploads", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.Header.Get("Content-Type")).To(HavePrefix("application/json"))
+				var sent NewMessage
+				Expect(json.NewDecoder(req.Body).Decode(&sent)).To(Succeed())
+				Expect(sent.Files).To(Equal([]string{"https://example.com/a.png"}))
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1"}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			_, err := c.CreateMessageWithFiles(&NewMessage{RoomID: "room", Files: []string{"https://example.com/a.png"}})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("fails if the message has no destination", func() {
+			_, err := c.CreateMessageWithFiles(&NewMessage{}, &FileUpload{Name: "a.txt", Content: strings.NewReader("a")})
+			Expect(err).To(MatchError("message requires a room ID, person ID, or email to send to"))
+		})
+	})
+
+	Describe("CreateMessageWithOptions", func() {
+		var calls int
+		var keys []string
+
+		BeforeEach(func() {
+			calls, keys = 0, nil
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(MessagesURL))
+				Expect(req.Method).To(Equal("POST"))
+				calls++
+				keys = append(keys, req.Header.Get("Idempotency-Key"))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+		})
+
+		It("sends the idempotency key as a header", func() {
+			n := &NewMessage{RoomID: "123", Text: "hello"}
+			Expect(c.CreateMessageWithOptions(n, &CreateMessageOptions{IdempotencyKey: "key 1"})).To(Equal(messages.Items[1]))
+			Expect(keys).To(Equal([]string{"key 1"}))
+		})
+
+		It("doesn't resend a message whose key already succeeded", func() {
+			n := &NewMessage{RoomID: "123", Text: "hello"}
+			opts := &CreateMessageOptions{IdempotencyKey: "key 1"}
+
+			Expect(c.CreateMessageWithOptions(n, opts)).To(Equal(messages.Items[1]))
+			Expect(c.SetMaxRetries(2).CreateMessageWithOptions(n, opts)).To(Equal(messages.Items[1])) // copies share keys
+			Expect(calls).To(Equal(1))
+
+			Expect(c.CreateMessageWithOptions(n, &CreateMessageOptions{IdempotencyKey: "key 2"})).To(Equal(messages.Items[1]))
+			Expect(calls).To(Equal(2))
+		})
+
+		It("resends a message whose key failed", func() {
+			n := &NewMessage{RoomID: "123", Text: "hello"}
+			opts := &CreateMessageOptions{IdempotencyKey: "key 1"}
+
+			succeed := mockCli.DoFunc
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				return nil, mockErr
+			}
+			_, err := c.CreateMessageWithOptions(n, opts)
+			Expect(err).To(MatchError(mockErr))
+
+			mockCli.DoFunc = succeed
+			Expect(c.CreateMessageWithOptions(n, opts)).To(Equal(messages.Items[1]))
+			Expect(calls).To(Equal(2))
+		})
+
+		It("always sends messages without a key", func() {
+			n := &NewMessage{RoomID: "123", Text: "hello"}
+			Expect(c.CreateMessageWithOptions(n, nil)).To(Equal(messages.Items[1]))
+			Expect(c.CreateMessageWithOptions(n, &CreateMessageOptions{})).To(Equal(messages.Items[1]))
+			Expect(calls).To(Equal(2))
+			Expect(keys).To(Equal([]string{"", ""}))
+		})
+
+		It("forgets the oldest keys once it has too many", func() {
+			sc := newSentCache(2)
+			sc.add("1", []byte("a"))
+			sc.add("2", []byte("b"))
+			sc.add("3", []byte("c"))
+
+			_, ok := sc.get("1")
+			Expect(ok).To(BeFalse())
+			resp, ok := sc.get("3")
+			Expect(ok).To(BeTrue())
+			Expect(resp).To(Equal([]byte("c")))
+		})
+	})
+
+	Describe("UpdateMessage", func() {
+		var n NewMessage
+
+		BeforeEach(func() {
+			n = NewMessage{
+				RoomID:   messages.Items[0].RoomID,
+				Markdown: "edited markdown",
+			}
+		})
+
+		It("updates a message", func() {
+			messageID := messages.Items[0].ID
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/%s", MessagesURL, messageID)))
+				Expect(req.Method).To(Equal("PUT"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				var p NewMessage
+				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
+				Expect(p).To(Equal(n))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(messages.Items[1])).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.UpdateMessage(messageID, &n)).To(Equal(messages.Items[1]))
+		})
+
+		It("fails if the message ID is empty", func() {
+			p, err := c.UpdateMessage("", &n)
+			Expect(err).To(MatchError("no message ID specified"))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails if a nil argument is provided", func() {
+			p, err := c.UpdateMessage("1", nil)
+			Expect(err).To(MatchError("nil message"))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails if the room ID is empty", func() {
+			n.RoomID = ""
+
+			p, err := c.UpdateMessage("1", &n)
+			Expect(err).To(MatchError("no room ID specified"))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails if both text and markdown are empty", func() {
+			n.Markdown = ""
+
+			p, err := c.UpdateMessage("1", &n)
+			Expect(err).To(MatchError("message has no content"))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails if only files are provided, since they can't be edited", func() {
+			n.Markdown = ""
+			n.Files = []string{"file"}
+
+			p, err := c.UpdateMessage("1", &n)
+			Expect(err).To(MatchError("message requires text or markdown"))
+			Expect(p).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			p, err := c.UpdateMessage("1", &n)
+			Expect(err).To(MatchError(mockErr))
+			Expect(p).To(BeNil())
+		})
+	})
+
+	Describe("DeleteMessage", func() {
+		It("deletes a message", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/%s", MessagesURL, messages.Items[0].ID)))
+				Expect(req.Method).To(Equal("DELETE"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				r := &http.Response{
+					Body:       closer(&bytes.Buffer{}), // empty body
+					StatusCode: http.StatusNoContent,    // deletes are weird and return 204 instead of 200
+				}
+				return r, nil
+			}
+
+			Expect(c.DeleteMessage(messages.Items[0].ID)).To(Succeed())
 		})
 
 		It("fails if the message ID is empty", func() {
@@ -465,4 +1781,239 @@ var _ = Describe("Message (Mock)", func() {
 			Expect(c.DeleteMessage("1")).To(MatchError(mockErr))
 		})
 	})
+
+	Describe("DeleteOwnMessage", func() {
+		var deleted bool
+
+		BeforeEach(func() {
+			deleted = false
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var b bytes.Buffer
+				switch {
+				case req.Method == "GET" && req.URL.String() == fmt.Sprintf("%s/me", PeopleURL):
+					Expect(json.NewEncoder(&b).Encode(Person{ID: messages.Items[0].PersonID})).To(Succeed())
+				case req.Method == "GET":
+					id := strings.TrimPrefix(req.URL.String(), MessagesURL+"/")
+					for _, m := range messages.Items {
+						if m.ID == id {
+							Expect(json.NewEncoder(&b).Encode(m)).To(Succeed())
+						}
+					}
+				case req.Method == "DELETE":
+					Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/%s", MessagesURL, messages.Items[0].ID)))
+					deleted = true
+					return &http.Response{Body: closer(&b), StatusCode: http.StatusNoContent}, nil
+				}
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+		})
+
+		It("deletes a message sent by the authenticated identity", func() {
+			Expect(c.DeleteOwnMessage(messages.Items[0].ID)).To(Succeed())
+			Expect(deleted).To(BeTrue())
+		})
+
+		It("refuses to delete a message sent by someone else", func() {
+			err := c.DeleteOwnMessage(messages.Items[1].ID)
+			Expect(err).To(MatchError(`message "2" was not sent by the client's identity, refusing to delete it`))
+			Expect(deleted).To(BeFalse())
+		})
+
+		It("fails if the message ID is empty", func() {
+			Expect(c.DeleteOwnMessage("")).To(MatchError("no message ID specified"))
+			Expect(deleted).To(BeFalse())
+		})
+
+		It("passes through errors encountered fetching the message", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			Expect(c.DeleteOwnMessage("1")).To(MatchError(mockErr))
+		})
+	})
+
+	Describe("DeleteOwnMessagesInRoom", func() {
+		var mu sync.Mutex
+		var deleted []string
+		var pages [][]*Message
+
+		BeforeEach(func() {
+			deleted = nil
+			pages = [][]*Message{
+				{{ID: "5", PersonID: "me"}, {ID: "4", PersonID: "other"}, {ID: "3", PersonID: "me"}},
+				{{ID: "2", PersonID: "another"}, {ID: "1", PersonID: "me"}},
+			}
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{Body: closer(new(bytes.Buffer)), StatusCode: http.StatusOK, Header: http.Header{}}
+				switch {
+				case req.URL.String() == fmt.Sprintf("%s/me", PeopleURL):
+					r.Body = closer(bytes.NewBufferString(`{"id":"me"}`))
+				case req.Method == "GET":
+					Expect(req.URL.Query().Get("roomId")).To(Equal("room"))
+					page := pages[0]
+					if req.URL.Query().Get("beforeMessage") == "3" {
+						page = pages[1]
+					} else {
+						r.Header.Set("Link", fmt.Sprintf("<%s?roomId=room>; rel=\"next\"", MessagesURL))
+					}
+					b, err := json.Marshal(MessageList{Items: page})
+					Expect(err).ToNot(HaveOccurred())
+					r.Body = closer(bytes.NewBuffer(b))
+				case req.Method == "DELETE":
+					id := strings.TrimPrefix(req.URL.Path, "/v1/messages/")
+					if id == "3" {
+						r.StatusCode = http.StatusInternalServerError
+						return r, nil
+					}
+					mu.Lock()
+					deleted = append(deleted, id)
+					mu.Unlock()
+					r.StatusCode = http.StatusNoContent
+				}
+				return r, nil
+			}
+		})
+
+		It("deletes only the messages sent by the authenticated identity, across pages", func() {
+			pages[0][2].PersonID = "someone" // so none of the deletes fail
+
+			n, err := c.DeleteOwnMessagesInRoom("room")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(2))
+			Expect(deleted).To(ConsistOf("5", "1"))
+		})
+
+		It("keeps deleting when some of the deletes fail, and reports those failures", func() {
+			n, err := c.DeleteOwnMessagesInRoom("room")
+			Expect(n).To(Equal(2))
+			Expect(deleted).To(ConsistOf("5", "1"))
+
+			var errs MessageErrors
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs).To(HaveLen(1))
+			Expect(hasStatus(errs["3"], http.StatusInternalServerError)).To(BeTrue())
+		})
+
+		It("fails if the room ID is empty", func() {
+			n, err := c.DeleteOwnMessagesInRoom("")
+			Expect(err).To(MatchError("no room ID specified"))
+			Expect(n).To(BeZero())
+		})
+
+		It("doesn't delete anything if the room can't be listed", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				if req.URL.String() == fmt.Sprintf("%s/me", PeopleURL) {
+					return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"me"}`)), StatusCode: http.StatusOK}, nil
+				}
+				Expect(req.Method).To(Equal("GET"))
+				return nil, mockErr
+			}
+			n, err := c.DeleteOwnMessagesInRoom("room")
+			Expect(err).To(MatchError(mockErr))
+			Expect(n).To(BeZero())
+		})
+	})
+
+	Describe("mentions", func() {
+		p := &Person{ID: "person 1", DisplayName: "Person One"}
+
+		It("mentions a person by ID", func() {
+			Expect(Mention(p)).To(Equal("<@personId:person 1|Person One>"))
+		})
+
+		It("omits the display name if the person doesn't have one", func() {
+			Expect(Mention(&Person{ID: "person 1"})).To(Equal("<@personId:person 1>"))
+		})
+
+		It("returns an empty string for a nil person", func() {
+			Expect(Mention(nil)).To(BeEmpty())
+		})
+
+		It("mentions a person by email", func() {
+			Expect(MentionEmail("hello@world.com")).To(Equal("<@personEmail:hello@world.com>"))
+		})
+
+		It("appends a mention to a message's markdown", func() {
+			n := &NewMessage{RoomID: "room 1", Markdown: "Hello"}
+
+			m := n.WithMention(p)
+			Expect(m.Markdown).To(Equal("Hello <@personId:person 1|Person One>"))
+			Expect(m.RoomID).To(Equal("room 1"))
+			Expect(n.Markdown).To(Equal("Hello")) // the original message isn't modified
+
+			Expect((&NewMessage{}).WithMention(p).Markdown).To(Equal("<@personId:person 1|Person One>"))
+			Expect((*NewMessage)(nil).WithMention(p)).To(Equal(&NewMessage{Markdown: "<@personId:person 1|Person One>"}))
+		})
+
+		Describe("StripMention", func() {
+			bot := &Person{ID: "bot 1", Emails: []string{"bot@world.com"}, DisplayName: "Helper Bot", NickName: "Helpy"}
+
+			for _, tc := range []struct {
+				name string
+				msg  Message
+				want string
+			}{
+				{"the display name", Message{Text: "Helper Bot do the thing"}, "do the thing"},
+				{"the display name, in another case", Message{Text: "helper bot do the thing"}, "do the thing"},
+				{"the first word of the display name", Message{Text: "Helper do the thing"}, "do the thing"},
+				{"the nickname, with an @ and a comma", Message{Text: "@Helpy, do the thing"}, "do the thing"},
+				{"a name with nothing after it", Message{Text: "Helper Bot"}, ""},
+				{"a mention by ID", Message{Markdown: "<@personId:bot 1|Helper Bot> do the thing"}, "do the thing"},
+				{"a mention by email", Message{Markdown: "<@personEmail:BOT@world.com> do the thing"}, "do the thing"},
+				{
+					"a <spark-mention>",
+					Message{Markdown: `<spark-mention data-object-type="person" data-object-id="bot 1">Helper Bot</spark-mention>: do the thing`},
+					"do the thing",
+				},
+			} {
+				tc := tc
+				It(fmt.Sprintf("strips %s", tc.name), func() {
+					Expect(tc.msg.StripMention(bot)).To(Equal(tc.want))
+				})
+			}
+
+			It("leaves a message that doesn't start with a mention of the person alone", func() {
+				Expect((&Message{Text: " do the thing, Helper Bot "}).StripMention(bot)).To(Equal("do the thing, Helper Bot"))
+				Expect((&Message{Text: "Helpful people do the thing"}).StripMention(bot)).To(Equal("Helpful people do the thing"))
+				Expect((&Message{Markdown: "<@personId:someone|Someone> do the thing"}).StripMention(bot)).
+					To(Equal("<@personId:someone|Someone> do the thing"))
+			})
+
+			It("handles nils", func() {
+				Expect((*Message)(nil).StripMention(bot)).To(BeEmpty())
+				Expect((&Message{Text: "Helper Bot do the thing"}).StripMention(nil)).To(Equal("Helper Bot do the thing"))
+			})
+		})
+	})
+
+	Describe("content helpers", func() {
+		It("counts a message's attachments", func() {
+			m := &Message{Files: []string{"https://example.com/1", "https://example.com/2"}}
+			Expect(m.HasFiles()).To(BeTrue())
+			Expect(m.AttachmentCount()).To(Equal(2))
+
+			Expect((&Message{}).HasFiles()).To(BeFalse())
+			Expect((&Message{}).AttachmentCount()).To(Equal(0))
+			Expect((*Message)(nil).HasFiles()).To(BeFalse())
+		})
+
+		It("prefers the message's text", func() {
+			m := &Message{Text: "hello", Markdown: "**hello**", HTML: "<p><strong>hello</strong></p>"}
+			Expect(m.PlainText()).To(Equal("hello"))
+		})
+
+		It("falls back to the message's HTML, then its markdown", func() {
+			m := &Message{
+				Markdown: "**hello** & [docs](https://example.com)",
+				HTML:     "<p><strong>hello</strong> &amp; <a href=\"https://example.com\">docs</a></p><p>second<br/>line</p>",
+			}
+			Expect(m.PlainText()).To(Equal("hello & docs\nsecond\nline"))
+
+			m.HTML = ""
+			Expect(m.PlainText()).To(Equal("hello & docs (https://example.com)"))
+
+			Expect((&Message{}).PlainText()).To(Equal(""))
+			Expect((*Message)(nil).PlainText()).To(Equal(""))
+		})
+	})
 })
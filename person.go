@@ -2,32 +2,45 @@ package spark
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
 	"net/url"
-	"time"
+	"strings"
+	"sync"
 )
 
-const PeopleURL = "https://api.ciscospark.com/v1/people"
+const PeopleURL = BaseURL + "/people"
 
 type Person struct {
-	ID            string    `json:"id,omitempty"`
-	Emails        []string  `json:"emails,omitempty"`
-	DisplayName   string    `json:"displayName,omitempty"`
-	NickName      string    `json:"nickName,omitempty"`
-	FirstName     string    `json:"firstName,omitempty"`
-	LastName      string    `json:"lastName,omitempty"`
-	Avatar        string    `json:"avatar,omitempty"`
-	OrgId         string    `json:"orgId,omitempty"`
-	Roles         []string  `json:"roles,omitempty"`
-	Licenses      []string  `json:"licenses,omitempty"`
-	Created       time.Time `json:"created,omitempty"`
-	Timezone      string    `json:"timezone,omitempty"`
-	LastActivity  time.Time `json:"lastActivity,omitempty"`
-	Status        string    `json:"status,omitempty"`
-	InvitePending bool      `json:"invitePending,omitempty"`
-	LoginEnabled  bool      `json:"loginEnabled,omitempty"`
-	Type          string    `json:"type,omitempty"`
+	ID            string   `json:"id,omitempty"`
+	Emails        []string `json:"emails,omitempty"`
+	DisplayName   string   `json:"displayName,omitempty"`
+	NickName      string   `json:"nickName,omitempty"`
+	FirstName     string   `json:"firstName,omitempty"`
+	LastName      string   `json:"lastName,omitempty"`
+	Avatar        string   `json:"avatar,omitempty"`
+	OrgId         string   `json:"orgId,omitempty"`
+	Roles         []string `json:"roles,omitempty"`
+	Licenses      []string `json:"licenses,omitempty"`
+	Created       Time     `json:"created,omitempty"`
+	Timezone      string   `json:"timezone,omitempty"`
+	LastActivity  Time     `json:"lastActivity,omitempty"`
+	Status        string   `json:"status,omitempty"`
+	InvitePending bool     `json:"invitePending,omitempty"`
+	LoginEnabled  bool     `json:"loginEnabled,omitempty"`
+	Type          string   `json:"type,omitempty"`
+}
+
+// IsProvisioned reports whether the person's account is fully set up, meaning they've accepted their invite and are
+// allowed to log in.
+func (p *Person) IsProvisioned() bool {
+	return p != nil && !p.InvitePending && p.LoginEnabled
 }
 
 type People struct {
@@ -36,50 +49,202 @@ type People struct {
 
 // https://developer.webex.com/endpoint-people-personId-get.html
 func (c *client) GetPerson(personID string) (*Person, error) {
-	if personID == "" {
-		return nil, fmt.Errorf("no person ID specified")
-	}
-
-	resp, err := c.getRequest(fmt.Sprintf("%s/%s", PeopleURL, personID), nil)
+	resp, err := c.GetPersonRaw(personID)
 	if err != nil {
 		return nil, err
 	}
 
 	var person Person
-	if err := json.Unmarshal(resp, &person); err != nil {
+	if err := c.unmarshal(resp, &person); err != nil {
 		return nil, err
 	}
 	return &person, err
 }
 
+// GetPersonRaw works like GetPerson, except it returns the raw JSON of the response instead of a parsed Person.  This
+// allows callers to read fields that the Person struct does not (yet) model.
+func (c *client) GetPersonRaw(personID string) (json.RawMessage, error) {
+	if personID == "" {
+		return nil, fmt.Errorf("no person ID specified")
+	}
+
+	return c.getRequest(fmt.Sprintf("%s/%s", PeopleURL, personID), nil)
+}
+
 // https://developer.webex.com/endpoint-people-me-get.html
 func (c *client) GetMyself() (*Person, error) {
 	return c.GetPerson("me")
 }
 
-// https://developer.webex.com/endpoint-people-post.html
-func (c *client) CreatePerson(p *Person) (*Person, error) {
-	if p == nil {
-		return nil, fmt.Errorf("nil person")
+// Ping checks that the client can reach Spark and that its token is accepted, by fetching the authenticated person.
+// It's meant for health checks, like readiness probes, so it's bounded by ctx and always goes to the server, rather
+// than using the identity cached by IsSelfAuthored.  Any failure is returned as a *PingError.
+func (c *client) Ping(ctx context.Context) error {
+	if _, err := c.getRequestContext(ctx, fmt.Sprintf("%s/me", PeopleURL), nil); err != nil {
+		return &PingError{Unauthorized: IsUnauthorized(err), Err: err}
 	}
-	if len(p.Emails) == 0 { // strangely, the only required field
+	return nil
+}
+
+// Works like GetMyself, except the result is cached on the client after the first successful call, and the request is
+// bound to the provided context.  Helpers that need to know who the client is (ex. to avoid responding to its own
+// messages) use this to avoid looking the identity up over and over.
+func (c *client) myself(ctx context.Context) (*Person, error) {
+	c.self.mu.Lock()
+	defer c.self.mu.Unlock()
+
+	if c.self.me != nil {
+		return c.self.me, nil
+	}
+
+	resp, err := c.getRequestContext(ctx, fmt.Sprintf("%s/me", PeopleURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var me Person
+	if err := c.unmarshal(resp, &me); err != nil {
+		return nil, err
+	}
+	c.self.me = &me
+	return &me, nil
+}
+
+// GetPersonByEmail is a helper method that wraps ListPeople.  It will query for people with the provided email address,
+// and return the first match.  Multiple people can match the same address (ex. shared mailboxes), in which case only
+// the first one returned by the server is provided.  If no such person exists, an error will be returned instead.
+func (c *client) GetPersonByEmail(email string) (*Person, error) {
+	if email == "" {
 		return nil, fmt.Errorf("no email specified")
 	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return nil, fmt.Errorf("invalid email %q", email)
+	}
 
-	b := new(bytes.Buffer)
-	if err := json.NewEncoder(b).Encode(p); err != nil {
+	people, err := c.ListPeople(1, &PeopleListParams{Email: email})
+	if err != nil {
 		return nil, err
 	}
-	resp, err := c.postRequest(PeopleURL, b)
+	if len(people) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrPersonNotFound, email)
+	}
+
+	return people[0], nil
+}
+
+// https://developer.webex.com/endpoint-people-post.html
+func (c *client) CreatePerson(p *Person) (*Person, error) {
+	return c.createPerson(context.Background(), p)
+}
+
+// Works like CreatePerson, except the request is bound to the provided context.
+func (c *client) createPerson(ctx context.Context, p *Person) (*Person, error) {
+	if err := validateNewPerson(p); err != nil {
+		return nil, err
+	}
+
+	b, err := c.encode(p)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.postRequestContext(ctx, PeopleURL, b)
 	if err != nil {
 		return nil, err
 	}
 
 	var rp Person
-	err = json.Unmarshal(resp, &rp)
+	err = c.unmarshal(resp, &rp)
 	return &rp, err
 }
 
+// Checks that every person in a bulk creation can be created, and that none of them shares a first email (which keys
+// their failures) with another.  Emails are compared case-insensitively, like Spark does.
+func validateNewPeople(people []*Person) error {
+	seen := make(map[string]int, len(people))
+	for i, p := range people {
+		if err := validateNewPerson(p); err != nil {
+			return fmt.Errorf("person %d: %v", i, err)
+		}
+		email := strings.ToLower(p.Emails[0])
+		if j, ok := seen[email]; ok {
+			return fmt.Errorf("person %d: email %q is also the first email of person %d", i, p.Emails[0], j)
+		}
+		seen[email] = i
+	}
+	return nil
+}
+
+// Checks that a person can be created.
+func validateNewPerson(p *Person) error {
+	if p == nil {
+		return fmt.Errorf("nil person")
+	}
+	if len(p.Emails) == 0 { // strangely, the only required field
+		return fmt.Errorf("no email specified")
+	}
+	return nil
+}
+
+// CreatePeople is a helper method for bulk provisioning (admin only).  It creates the people concurrently, at most
+// concurrency at a time (at least 1), paced by the client's rate limit if it has one (see SetRateLimit).  Every person
+// is checked for an email before any are created, and no two may share a first email, so each failure can be
+// reported separately.  The people that were created are returned in the order they were given.  If some couldn't be
+// created, including those that weren't started before ctx was done, a PeopleErrors keyed by their first email
+// describes the failures.
+func (c *client) CreatePeople(ctx context.Context, people []*Person, concurrency int) ([]*Person, error) {
+	if err := validateNewPeople(people); err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		created = make([]*Person, len(people))
+		errs    = make(PeopleErrors)
+		sem     = make(chan struct{}, concurrency)
+	)
+	for i, p := range people {
+		if err := acquire(ctx, sem); err != nil {
+			mu.Lock()
+			errs[p.Emails[0]] = err
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, p *Person) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			rp, err := c.createPerson(ctx, p)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[p.Emails[0]] = err
+				return
+			}
+			created[i] = rp
+		}(i, p)
+	}
+	wg.Wait()
+
+	result := []*Person{}
+	for _, p := range created {
+		if p != nil {
+			result = append(result, p)
+		}
+	}
+	if len(errs) > 0 {
+		return result, errs
+	}
+	return result, nil
+}
+
 // https://developer.webex.com/endpoint-people-personId-put.html
 func (c *client) UpdatePerson(p *Person) (*Person, error) {
 	if p == nil {
@@ -90,8 +255,8 @@ func (c *client) UpdatePerson(p *Person) (*Person, error) {
 	}
 	// weirdly, Emails isn't required, despite the fact that it's required for a *new* person
 
-	b := new(bytes.Buffer)
-	if err := json.NewEncoder(b).Encode(p); err != nil {
+	b, err := c.encode(p)
+	if err != nil {
 		return nil, err
 	}
 	resp, err := c.putRequest(fmt.Sprintf("%s/%s", PeopleURL, p.ID), b)
@@ -100,10 +265,115 @@ func (c *client) UpdatePerson(p *Person) (*Person, error) {
 	}
 
 	var rp Person
-	err = json.Unmarshal(resp, &rp)
+	err = c.unmarshal(resp, &rp)
 	return &rp, err
 }
 
+// UpdatePersonFields is a helper method that updates only the named fields of a person, keyed by their JSON names (ex.
+// "displayName"), rather than resending a whole Person that may be out of date.  Spark replaces a person with whatever
+// is PUT, so this fetches the person's current state first, merges the fields onto it, and sends the result.  Fields
+// that this package doesn't model are preserved.
+//
+// This is a read-modify-write, so it narrows the window for lost updates without closing it: a change made by someone
+// else between the fetch and the update is still overwritten.
+func (c *client) UpdatePersonFields(personID string, fields map[string]interface{}) (*Person, error) {
+	if personID == "" {
+		return nil, fmt.Errorf("no person ID specified")
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no person fields specified")
+	}
+
+	current, err := c.getRequestContext(context.Background(), fmt.Sprintf("%s/%s", PeopleURL, personID), nil) // never cached
+	if err != nil {
+		return nil, err
+	}
+	body, err := mergeFields(c.jsonCodec(), current, fields)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.putRequest(fmt.Sprintf("%s/%s", PeopleURL, personID), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	var rp Person
+	err = c.unmarshal(resp, &rp)
+	return &rp, err
+}
+
+// Returns the JSON object current with fields set on it, replacing any that it already had, using codec for the JSON.
+func mergeFields(codec Codec, current []byte, fields map[string]interface{}) ([]byte, error) {
+	merged := make(map[string]json.RawMessage)
+	if err := codec.Unmarshal(current, &merged); err != nil {
+		return nil, err
+	}
+	for k, v := range fields {
+		raw, err := codec.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("person field %q: %v", k, err)
+		}
+		merged[k] = raw
+	}
+	return codec.Marshal(merged)
+}
+
+// SetPersonAvatar uploads a new avatar image for a person, replacing the one that Person.Avatar links to.  The image is
+// sent as a multipart form, with contentType (ex. "image/png") as the type of the image, which must be an image type.
+// Like UpdatePerson, this generally requires an admin token, unless the person is the client's own identity.
+func (c *client) SetPersonAvatar(personID string, image io.Reader, contentType string) (*Person, error) {
+	if personID == "" {
+		return nil, fmt.Errorf("no person ID specified")
+	}
+	if image == nil {
+		return nil, fmt.Errorf("no avatar image specified")
+	}
+	if err := validateImageType(contentType); err != nil {
+		return nil, err
+	}
+
+	b := new(bytes.Buffer)
+	w := multipart.NewWriter(b)
+	if err := writeAvatarForm(w, image, contentType); err != nil {
+		return nil, err
+	}
+	resp, err := c.putRequestSized(fmt.Sprintf("%s/%s", PeopleURL, personID), b, int64(b.Len()), w.FormDataContentType())
+	if err != nil {
+		return nil, err
+	}
+
+	var rp Person
+	err = c.unmarshal(resp, &rp)
+	return &rp, err
+}
+
+// Checks that a content type is for an image, ex. "image/jpeg".
+func validateImageType(contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid avatar content type %q: %v", contentType, err)
+	}
+	if !strings.HasPrefix(mediaType, "image/") {
+		return fmt.Errorf("avatar content type must be an image type (ex. image/png), not %q", contentType)
+	}
+	return nil
+}
+
+// Writes an avatar image to upload as a multipart form, as its only part.
+func writeAvatarForm(w *multipart.Writer, image io.Reader, contentType string) error {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="avatar"; filename="avatar"`)
+	h.Set("Content-Type", contentType)
+	part, err := w.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, image); err != nil {
+		return fmt.Errorf("reading avatar image: %v", err)
+	}
+	return w.Close()
+}
+
 // https://developer.webex.com/endpoint-people-personId-delete.html
 func (c *client) DeletePerson(ID string) error {
 	if ID == "" {
@@ -116,6 +386,10 @@ func (c *client) DeletePerson(ID string) error {
 
 // https://developer.webex.com/endpoint-people-get.html
 func (c *client) ListPeople(max int, params *PeopleListParams) ([]*Person, error) {
+	if !c.admin && !params.filtered() {
+		return nil, fmt.Errorf("ListPeople requires at least one of email, displayName, id, or orgId, unless the client has an admin token (see SetAdminToken)")
+	}
+
 	resp, reqErr := c.getRequestWithPaging(PeopleURL, params.values(), max)
 	if reqErr != nil && len(resp) == 0 { // if we got an error *and* results, parse them and return them
 		return nil, reqErr
@@ -124,12 +398,95 @@ func (c *client) ListPeople(max int, params *PeopleListParams) ([]*Person, error
 	var people []*Person
 	for _, r := range resp {
 		var pl People
-		if jsonErr := json.Unmarshal(r, &pl); jsonErr != nil {
+		if jsonErr := c.unmarshal(r, &pl); jsonErr != nil {
 			return people, fmt.Errorf("%v && %v", reqErr, jsonErr)
 		}
 		people = append(people, pl.Items...)
 	}
-	return people, nil
+	if c.dedupe {
+		people = DedupePeople(people)
+	}
+	if people == nil {
+		people = []*Person{} // empty, not failed
+	}
+	return people, reqErr
+}
+
+// ListOrgPeople is a helper method that lists every person in the org with the provided ID, which requires an admin
+// token for most orgs.  Each page is decoded as soon as it's received, rather than holding on to every raw page until
+// the end like ListPeople does, which keeps memory down for orgs with many thousands of people.
+func (c *client) ListOrgPeople(orgID string) ([]*Person, error) {
+	if orgID == "" {
+		return nil, fmt.Errorf("no org ID specified")
+	}
+
+	params := &PeopleListParams{OrgID: orgID}
+
+	var people []*Person
+	err := c.forEachPage(PeopleURL, params.values(), 0, func(page []byte) (bool, error) {
+		var pl People
+		if err := c.unmarshal(page, &pl); err != nil {
+			return false, err
+		}
+		people = append(people, pl.Items...)
+		return true, nil
+	})
+	if err != nil && people == nil {
+		return nil, err
+	}
+	if c.dedupe {
+		people = DedupePeople(people)
+	}
+	if people == nil {
+		people = []*Person{} // empty, not failed
+	}
+	return people, err
+}
+
+// ListPeopleSingle works like ListPeople, except that it makes exactly one request, for exactly max people, regardless
+// of the client's page size.  Along with the people, it returns the pagination links the server sent.  Links.Next is
+// empty if there are no more people.
+func (c *client) ListPeopleSingle(max int, params *PeopleListParams) ([]*Person, Links, error) {
+	if !c.admin && !params.filtered() {
+		return nil, Links{}, fmt.Errorf("ListPeopleSingle requires at least one of email, displayName, id, or orgId, unless the client has an admin token (see SetAdminToken)")
+	}
+
+	page, links, err := c.getSinglePage(PeopleURL, params.values(), max)
+	if err != nil {
+		return nil, Links{}, err
+	}
+
+	var pl People
+	if err := c.unmarshal(page, &pl); err != nil {
+		return nil, Links{}, err
+	}
+	if pl.Items == nil {
+		pl.Items = []*Person{} // empty, not failed
+	}
+	return pl.Items, links, nil
+}
+
+// Waits for a free slot in sem, unless ctx is done first.
+func acquire(ctx context.Context, sem chan struct{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reports whether the email is one of the person's, without regard to case.
+func hasEmail(p *Person, email string) bool {
+	for _, e := range p.Emails {
+		if strings.EqualFold(e, email) {
+			return true
+		}
+	}
+	return false
 }
 
 type PeopleListParams struct {
@@ -137,6 +494,15 @@ type PeopleListParams struct {
 	DisplayName string
 	ID          string
 	OrgID       string
+
+	// IncludeInactive asks Spark to include people of every type and status (ex. deactivated accounts), which it
+	// otherwise leaves out.  Sent as showAllTypes.
+	IncludeInactive bool
+}
+
+// Reports whether any of the filters that non-admin tokens require are set.
+func (p *PeopleListParams) filtered() bool {
+	return p != nil && (p.Email != "" || p.DisplayName != "" || p.ID != "" || p.OrgID != "")
 }
 
 func (p *PeopleListParams) values() url.Values {
@@ -157,6 +523,9 @@ func (p *PeopleListParams) values() url.Values {
 	if p.OrgID != "" {
 		uv.Add("orgId", p.OrgID)
 	}
+	if p.IncludeInactive {
+		uv.Add("showAllTypes", "true")
+	}
 
 	return uv
 }
@@ -2,10 +2,16 @@ package spark
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -122,6 +128,18 @@ var _ = Describe("Person (Mock)", func() {
 			Expect(c.GetPerson(personID)).To(Equal(people.Items[0]))
 		})
 
+		It("treats an empty 200 body as a successful empty result", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(&bytes.Buffer{}), // empty body
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.GetPerson("1")).To(Equal(&Person{}))
+		})
+
 		It("fails if no person ID is specified", func() {
 			p, err := c.GetPerson("")
 			Expect(err).To(MatchError("no person ID specified"))
@@ -138,7 +156,190 @@ var _ = Describe("Person (Mock)", func() {
 		})
 	})
 
+	Describe("GetPersonRaw", func() {
+		It("returns fields the Person struct doesn't model", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/%s", PeopleURL, "1")))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"id":"1","unmodeledField":"hello"}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			raw, err := c.GetPersonRaw("1")
+			Expect(err).ToNot(HaveOccurred())
+
+			var fields map[string]interface{}
+			Expect(json.Unmarshal(raw, &fields)).To(Succeed())
+			Expect(fields["id"]).To(Equal("1"))
+			Expect(fields["unmodeledField"]).To(Equal("hello"))
+		})
+
+		It("fails if no person ID is specified", func() {
+			raw, err := c.GetPersonRaw("")
+			Expect(err).To(MatchError("no person ID specified"))
+			Expect(raw).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			raw, err := c.GetPersonRaw("1")
+			Expect(err).To(MatchError(mockErr))
+			Expect(raw).To(BeNil())
+		})
+	})
+
+	Describe("GetPersonByEmail", func() {
+		It("gets a person by email", func() {
+			email := people.Items[0].Emails[0]
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				uri := strings.Split(req.URL.String(), "?")[0]
+				Expect(uri).To(Equal(PeopleURL))
+				Expect(req.URL.Query().Get("email")).To(Equal(email))
+				Expect(req.URL.Query().Get("max")).To(Equal("1"))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				p := People{Items: people.Items[:1]}
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(p)).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.GetPersonByEmail(email)).To(Equal(people.Items[0]))
+		})
+
+		It("returns the first person if multiple people match", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(people)).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.GetPersonByEmail("shared@world.com")).To(Equal(people.Items[0]))
+		})
+
+		It("fails if no person has the email", func() {
+			email := "nobody@world.com"
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(People{})).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			p, err := c.GetPersonByEmail(email)
+			Expect(errors.Is(err, ErrPersonNotFound)).To(BeTrue())
+			Expect(err).To(MatchError(fmt.Sprintf("person not found: %q", email)))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails if no email is specified", func() {
+			p, err := c.GetPersonByEmail("")
+			Expect(err).To(MatchError("no email specified"))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails if the email is malformed", func() {
+			for _, email := range []string{"not an email", "Name <name@world.com>", "@world.com"} {
+				p, err := c.GetPersonByEmail(email)
+				Expect(err).To(MatchError(fmt.Sprintf("invalid email %q", email)))
+				Expect(p).To(BeNil())
+			}
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			p, err := c.GetPersonByEmail("hello1@world.com")
+			Expect(err).To(MatchError(mockErr))
+			Expect(p).To(BeNil())
+		})
+	})
+
+	Describe("Ping", func() {
+		var calls int
+
+		BeforeEach(func() {
+			calls = 0
+		})
+
+		respond := func(status int) func(req *http.Request) (*http.Response, error) {
+			return func(req *http.Request) (*http.Response, error) {
+				calls++
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/me", PeopleURL)))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"id":"1"}`)),
+					StatusCode: status,
+				}
+				return r, nil
+			}
+		}
+
+		It("succeeds if the token is accepted, without caching the result", func() {
+			mockCli.DoFunc = respond(http.StatusOK)
+
+			Expect(c.Ping(context.Background())).To(Succeed())
+			Expect(c.Ping(context.Background())).To(Succeed())
+			Expect(calls).To(Equal(2))
+		})
+
+		It("reports a rejected token", func() {
+			mockCli.DoFunc = respond(http.StatusUnauthorized)
+
+			err := c.Ping(context.Background())
+			var pingErr *PingError
+			Expect(errors.As(err, &pingErr)).To(BeTrue())
+			Expect(pingErr.Unauthorized).To(BeTrue())
+			Expect(IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("reports a timeout as a network failure", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			err := c.Ping(ctx)
+			var pingErr *PingError
+			Expect(errors.As(err, &pingErr)).To(BeTrue())
+			Expect(pingErr.Unauthorized).To(BeFalse())
+			Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		})
+	})
+
 	Describe("ListPeople", func() {
+		BeforeEach(func() {
+			c = c.SetAdminToken(true) // most of these tests list people without filters
+		})
+
 		It("gets a list of people", func() {
 			max := len(people.Items)
 
@@ -294,6 +495,8 @@ var _ = Describe("Person (Mock)", func() {
 				DisplayName: "test name",
 				ID:          "test ID",
 				OrgID:       "test org ID",
+
+				IncludeInactive: true,
 			}
 
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
@@ -319,6 +522,65 @@ var _ = Describe("Person (Mock)", func() {
 			Expect(c.ListPeople(max, &params)).To(ConsistOf(people.Items))
 		})
 
+		It("requires a filter for non-admin tokens", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Fail("unexpected call to http.Client.Do()")
+				return nil, nil
+			}
+			c = c.SetAdminToken(false)
+
+			for _, params := range []*PeopleListParams{nil, {}} {
+				p, err := c.ListPeople(0, params)
+				Expect(err).To(MatchError(ContainSubstring("ListPeople requires at least one of email, displayName, id, or orgId")))
+				Expect(p).To(BeNil())
+			}
+		})
+
+		It("allows any one filter for non-admin tokens", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"items":[]}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+			c = c.SetAdminToken(false)
+
+			for _, params := range []*PeopleListParams{{Email: "a@b.com"}, {DisplayName: "a"}, {ID: "1"}, {OrgID: "1"}} {
+				_, err := c.ListPeople(0, params)
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		It("returns an empty, non-nil slice when there are no results", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"items":[]}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			p, err := c.ListPeople(0, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p).ToNot(BeNil())
+			Expect(p).To(BeEmpty())
+		})
+
+		It("fails with a nil slice if the results can't be decoded", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString("not json")),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			p, err := c.ListPeople(0, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(p).To(BeNil())
+		})
+
 		It("passes through errors encountered during the request", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
 				return nil, mockErr
@@ -329,6 +591,142 @@ var _ = Describe("Person (Mock)", func() {
 		})
 	})
 
+	Describe("PeopleListParams", func() {
+		It("builds empty values from nil params", func() {
+			var params *PeopleListParams
+			uv := params.values()
+			Expect(uv).ToNot(BeNil())
+			Expect(uv).To(BeEmpty())
+			Expect(params.filtered()).To(BeFalse())
+		})
+
+		It("only asks for inactive people when requested", func() {
+			params := &PeopleListParams{Email: "a@b.com"}
+			Expect(params.values()).ToNot(HaveKey("showAllTypes"))
+
+			params.IncludeInactive = true
+			Expect(params.values().Get("showAllTypes")).To(Equal("true"))
+			Expect((&PeopleListParams{IncludeInactive: true}).filtered()).To(BeFalse())
+		})
+	})
+
+	Describe("IsProvisioned", func() {
+		It("requires an accepted invite and login access", func() {
+			for _, tc := range []struct {
+				person *Person
+				want   bool
+			}{
+				{&Person{LoginEnabled: true}, true},
+				{&Person{LoginEnabled: true, InvitePending: true}, false},
+				{&Person{}, false},
+				{&Person{InvitePending: true}, false},
+				{nil, false},
+			} {
+				tc := tc
+				Expect(tc.person.IsProvisioned()).To(Equal(tc.want), fmt.Sprintf("%+v", tc.person))
+			}
+		})
+	})
+
+	Describe("ListOrgPeople", func() {
+		It("pages through every person in the org", func() {
+			var org []*Person
+			for i := 0; i < 350; i++ {
+				org = append(org, &Person{ID: fmt.Sprintf("person-%d", i), OrgId: "org", DisplayName: fmt.Sprintf("person %d", i)})
+			}
+
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				Expect(strings.Split(req.URL.String(), "?")[0]).To(Equal(PeopleURL))
+				Expect(req.URL.Query().Get("orgId")).To(Equal("org"))
+
+				max, err := strconv.Atoi(req.URL.Query().Get("max"))
+				Expect(err).ShouldNot(HaveOccurred())
+				start, _ := strconv.Atoi(req.URL.Query().Get("start"))
+				end := start + max
+				if end > len(org) {
+					end = len(org)
+				}
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(People{Items: org[start:end]})).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+					Header:     http.Header{},
+				}
+				if end < len(org) {
+					r.Header.Set("Link", fmt.Sprintf("<%s?orgId=org&max=%d&start=%d>; rel=\"next\"", PeopleURL, max, end))
+				}
+				return r, nil
+			}
+
+			people, err := c.SetMaxPerPage(100).ListOrgPeople("org")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(people).To(Equal(org))
+			Expect(calls).To(Equal(4))
+		})
+
+		It("fails if no org ID is specified", func() {
+			p, err := c.ListOrgPeople("")
+			Expect(err).To(MatchError("no org ID specified"))
+			Expect(p).To(BeNil())
+		})
+
+		It("returns the people of the pages received before an error", func() {
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				if calls++; calls > 1 {
+					return nil, mockErr
+				}
+				return &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"items":[{"id":"1"}]}`)),
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Link": {fmt.Sprintf("<%s?orgId=org&start=1>; rel=\"next\"", PeopleURL)}},
+				}, nil
+			}
+
+			p, err := c.ListOrgPeople("org")
+			Expect(err).To(MatchError(mockErr))
+			Expect(p).To(Equal([]*Person{{ID: "1"}}))
+		})
+	})
+
+	Describe("ListPeopleSingle", func() {
+		It("requests exactly max people in a single request, regardless of the client's page size", func() {
+			c = c.SetMaxPerPage(2)
+
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				uri := strings.Split(req.URL.String(), "?")[0]
+				Expect(uri).To(Equal(PeopleURL))
+				Expect(req.URL.Query().Get("max")).To(Equal("7"))
+				Expect(req.URL.Query().Get("displayName")).To(Equal("person"))
+				calls++
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(people)).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			ps, links, err := c.ListPeopleSingle(7, &PeopleListParams{DisplayName: "person"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ps).To(Equal(people.Items))
+			Expect(links.Next).To(BeEmpty())
+			Expect(calls).To(Equal(1))
+		})
+
+		It("fails without a filter unless the client has an admin token", func() {
+			_, _, err := c.ListPeopleSingle(7, nil)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
 	Describe("CreatePerson", func() {
 		It("creates a person", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
@@ -375,6 +773,113 @@ var _ = Describe("Person (Mock)", func() {
 		})
 	})
 
+	Describe("CreatePeople", func() {
+		var (
+			mu                sync.Mutex
+			calls             int
+			inFlight, maxSeen int
+		)
+
+		BeforeEach(func() {
+			calls, inFlight, maxSeen = 0, 0, 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var p Person
+				if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+					return nil, err
+				}
+
+				mu.Lock()
+				calls++
+				if inFlight++; inFlight > maxSeen {
+					maxSeen = inFlight
+				}
+				mu.Unlock()
+				time.Sleep(5 * time.Millisecond) // long enough for the others to pile up
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+
+				if strings.HasPrefix(p.Emails[0], "taken") {
+					return &http.Response{Body: closer(bytes.NewBufferString(`{"message":"taken"}`)), StatusCode: http.StatusConflict}, nil
+				}
+				p.ID = "id-" + p.Emails[0]
+				var b bytes.Buffer
+				if err := json.NewEncoder(&b).Encode(&p); err != nil {
+					return nil, err
+				}
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+		})
+
+		newPeople := func(emails ...string) []*Person {
+			people := make([]*Person, len(emails))
+			for i, e := range emails {
+				people[i] = &Person{Emails: []string{e}}
+			}
+			return people
+		}
+
+		It("creates everyone it can, and reports the rest by email", func() {
+			people := newPeople("a@world.com", "taken1@world.com", "b@world.com", "taken2@world.com", "c@world.com", "d@world.com")
+
+			created, err := c.CreatePeople(context.Background(), people, 2)
+			Expect(calls).To(Equal(6))
+			Expect(maxSeen).To(BeNumerically("<=", 2))
+
+			ids := make([]string, len(created))
+			for i, p := range created {
+				ids[i] = p.ID
+			}
+			Expect(ids).To(Equal([]string{"id-a@world.com", "id-b@world.com", "id-c@world.com", "id-d@world.com"}))
+
+			var errs PeopleErrors
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs).To(HaveLen(2))
+			Expect(IsConflict(errs["taken1@world.com"])).To(BeTrue())
+			Expect(IsConflict(errs["taken2@world.com"])).To(BeTrue())
+			Expect(err.Error()).To(HavePrefix("person taken1@world.com: "))
+		})
+
+		It("creates one at a time for a concurrency below 1", func() {
+			created, err := c.CreatePeople(context.Background(), newPeople("a@world.com", "b@world.com", "c@world.com"), 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(created).To(HaveLen(3))
+			Expect(maxSeen).To(Equal(1))
+		})
+
+		It("checks every person for an email before creating any", func() {
+			people := newPeople("a@world.com", "b@world.com")
+			people = append(people, &Person{DisplayName: "no email"})
+
+			created, err := c.CreatePeople(context.Background(), people, 2)
+			Expect(err).To(MatchError("person 2: no email specified"))
+			Expect(created).To(BeNil())
+			Expect(calls).To(BeZero())
+		})
+
+		It("rejects people sharing a first email before creating any", func() {
+			people := newPeople("a@world.com", "b@world.com", "A@World.com")
+
+			created, err := c.CreatePeople(context.Background(), people, 2)
+			Expect(err).To(MatchError(`person 2: email "A@World.com" is also the first email of person 0`))
+			Expect(created).To(BeNil())
+			Expect(calls).To(BeZero())
+		})
+
+		It("fails the people it hasn't created once the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			created, err := c.CreatePeople(ctx, newPeople("a@world.com", "b@world.com"), 1)
+			Expect(created).To(BeEmpty())
+			var errs PeopleErrors
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs).To(HaveLen(2))
+			Expect(errs["a@world.com"]).To(MatchError(ContainSubstring(context.Canceled.Error())))
+			Expect(calls).To(BeZero())
+		})
+	})
+
 	Describe("UpdatePerson", func() {
 		It("updates a person", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
@@ -421,6 +926,117 @@ var _ = Describe("Person (Mock)", func() {
 		})
 	})
 
+	Describe("UpdatePersonFields", func() {
+		It("sends only the named fields, merged onto the person's current state", func() {
+			current := `{"id":"1","displayName":"old name","emails":["a@world.com"],"roles":["role"],"unmodeled":{"kept":true}}`
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/1", PeopleURL)))
+				if req.Method == "GET" {
+					return &http.Response{Body: closer(bytes.NewBufferString(current)), StatusCode: http.StatusOK}, nil
+				}
+
+				Expect(req.Method).To(Equal("PUT"))
+				b, err := ioutil.ReadAll(req.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b).To(MatchJSON(`{"id":"1","displayName":"new name","emails":["a@world.com"],"roles":[],"unmodeled":{"kept":true}}`))
+
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1","displayName":"new name"}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			p, err := c.UpdatePersonFields("1", map[string]interface{}{"displayName": "new name", "roles": []string{}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p).To(Equal(&Person{ID: "1", DisplayName: "new name"}))
+			Expect(calls).To(Equal(2))
+		})
+
+		It("fails if no person ID or fields are specified", func() {
+			p, err := c.UpdatePersonFields("", map[string]interface{}{"displayName": "name"})
+			Expect(err).To(MatchError("no person ID specified"))
+			Expect(p).To(BeNil())
+
+			p, err = c.UpdatePersonFields("1", nil)
+			Expect(err).To(MatchError("no person fields specified"))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails without updating if a field can't be marshaled", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.Method).To(Equal("GET"))
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1"}`)), StatusCode: http.StatusOK}, nil
+			}
+			p, err := c.UpdatePersonFields("1", map[string]interface{}{"displayName": func() {}})
+			Expect(err).To(MatchError(ContainSubstring(`person field "displayName"`)))
+			Expect(p).To(BeNil())
+		})
+
+		It("passes through errors encountered fetching the person", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			p, err := c.UpdatePersonFields("1", map[string]interface{}{"displayName": "name"})
+			Expect(err).To(MatchError(mockErr))
+			Expect(p).To(BeNil())
+		})
+	})
+
+	Describe("SetPersonAvatar", func() {
+		It("uploads the image as the only part of a multipart form", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/1", PeopleURL)))
+				Expect(req.Method).To(Equal("PUT"))
+				Expect(req.Header.Get("Content-Type")).To(HavePrefix("multipart/form-data; boundary="))
+				Expect(req.ContentLength).To(BeNumerically(">", 0))
+
+				Expect(req.ParseMultipartForm(1 << 20)).To(Succeed())
+				Expect(req.MultipartForm.Value).To(BeEmpty())
+				Expect(req.MultipartForm.File).To(HaveLen(1))
+				Expect(req.MultipartForm.File["avatar"]).To(HaveLen(1))
+				fh := req.MultipartForm.File["avatar"][0]
+				Expect(fh.Header.Get("Content-Type")).To(Equal("image/png"))
+				f, err := fh.Open()
+				Expect(err).ShouldNot(HaveOccurred())
+				content, err := ioutil.ReadAll(f)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(content)).To(Equal("\x89PNG"))
+
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1","avatar":"https://avatars/1"}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			Expect(c.SetPersonAvatar("1", strings.NewReader("\x89PNG"), "image/png")).To(Equal(&Person{ID: "1", Avatar: "https://avatars/1"}))
+		})
+
+		It("fails without a person ID or image, or with a content type that isn't an image", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Fail("unexpected call to http.Client.Do()")
+				return nil, nil
+			}
+
+			_, err := c.SetPersonAvatar("", strings.NewReader("x"), "image/png")
+			Expect(err).To(MatchError("no person ID specified"))
+			_, err = c.SetPersonAvatar("1", nil, "image/png")
+			Expect(err).To(MatchError("no avatar image specified"))
+			_, err = c.SetPersonAvatar("1", strings.NewReader("x"), "text/plain")
+			Expect(err).To(MatchError(`avatar content type must be an image type (ex. image/png), not "text/plain"`))
+			_, err = c.SetPersonAvatar("1", strings.NewReader("x"), "")
+			Expect(err).To(MatchError(HavePrefix(`invalid avatar content type ""`)))
+		})
+
+		It("accepts content types with parameters", func() {
+			Expect(validateImageType("image/svg+xml; charset=utf-8")).To(Succeed())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			p, err := c.SetPersonAvatar("1", strings.NewReader("x"), "image/jpeg")
+			Expect(err).To(MatchError(mockErr))
+			Expect(p).To(BeNil())
+		})
+	})
+
 	Describe("DeletePerson", func() {
 		It("deletes a person", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
@@ -0,0 +1,74 @@
+package spark
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A token bucket that paces requests to perSecond on average, with bursts of up to burst.  A client and its copies
+// share one, so it's safe for concurrent use.
+type rateLimiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     int
+	tokens    float64 // negative when waiters have claimed tokens that haven't accrued yet
+	last      time.Time
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	return &rateLimiter{perSecond: perSecond, burst: burst, tokens: float64(burst), last: time.Now()}
+}
+
+// Claims a token, returning how long until it accrues.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.perSecond
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.perSecond * float64(time.Second))
+}
+
+// Returns a claimed token that won't be used, so later waiters don't wait for it.
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens++
+}
+
+// Waits until the caller's turn to send a request, or until ctx is done, whichever comes first.  Gives up right away
+// if ctx's deadline would pass before then.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	wait := l.reserve()
+	if wait == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(time.Now().Add(wait)) {
+		l.cancel()
+		return context.DeadlineExceeded
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,45 @@
+package spark
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("rateLimiter", func() {
+	It("lets a burst through, then paces the rest", func() {
+		l := newRateLimiter(50, 2) // a token every 20ms
+
+		start := time.Now()
+		Expect(l.Wait(context.Background())).To(Succeed())
+		Expect(l.Wait(context.Background())).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically("<", 10*time.Millisecond))
+
+		Expect(l.Wait(context.Background())).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 15*time.Millisecond))
+	})
+
+	It("refills up to the burst while idle", func() {
+		l := newRateLimiter(1000, 1)
+		Expect(l.Wait(context.Background())).To(Succeed())
+		time.Sleep(10 * time.Millisecond)
+		Expect(l.reserve()).To(BeZero())
+		Expect(l.tokens).To(BeNumerically("<", 0.01))
+	})
+
+	It("gives up, and returns its token, when the context would expire first", func() {
+		l := newRateLimiter(1, 1)
+		Expect(l.Wait(context.Background())).To(Succeed())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		Expect(l.Wait(ctx)).To(MatchError(context.DeadlineExceeded))
+
+		ctx, cancel = context.WithCancel(context.Background())
+		cancel()
+		Expect(l.Wait(ctx)).To(MatchError(context.Canceled))
+		Expect(l.tokens).To(BeNumerically(">", -0.5)) // neither claim was kept
+	})
+})
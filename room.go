@@ -1,46 +1,117 @@
 package spark
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
-const RoomsURL = "https://api.ciscospark.com/v1/rooms"
+const RoomsURL = BaseURL + "/rooms"
+
+// MaxRoomTitleLength is the longest room title, in characters, that Spark accepts.  Longer titles are rejected by
+// CreateRoom and UpdateRoomName before they're sent.
+const MaxRoomTitleLength = 100
 
 type Room struct {
-	ID           string    `json:"id,omitempty"`
-	Title        string    `json:"title,omitempty"`
-	Type         string    `json:"type,omitempty"`
-	IsLocked     bool      `json:"isLocked,omitempty"`
-	SIPAddress   string    `json:"sipAddress,omitempty"`
-	TeamID       string    `json:"teamId,omitempty"`
-	LastActivity time.Time `json:"lastActivity,omitempty"`
-	CreatorID    string    `json:"creatorId,omitempty"`
-	Created      time.Time `json:"created,omitempty"`
+	ID           string `json:"id,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Type         string `json:"type,omitempty"`
+	IsLocked     bool   `json:"isLocked,omitempty"`
+	SIPAddress   string `json:"sipAddress,omitempty"`
+	TeamID       string `json:"teamId,omitempty"`
+	LastActivity Time   `json:"lastActivity,omitempty"`
+	CreatorID    string `json:"creatorId,omitempty"`
+	Created      Time   `json:"created,omitempty"`
+
+	ClassificationID   string `json:"classificationId,omitempty"`
+	IsAnnouncementOnly bool   `json:"isAnnouncementOnly,omitempty"`
+}
+
+// The types of room that Room.Type reports, and that RoomListParams.Type lists.
+const (
+	RoomTypeDirect = "direct" // a 1:1 room with another person
+	RoomTypeGroup  = "group"
+)
+
+// NewRoom holds the settings for a room created with CreateRoomWithOptions.
+type NewRoom struct {
+	Title              string `json:"title"`                        // required
+	TeamID             string `json:"teamId,omitempty"`             // optional
+	ClassificationID   string `json:"classificationId,omitempty"`   // optional
+	IsLocked           bool   `json:"isLocked,omitempty"`           // optional
+	IsAnnouncementOnly bool   `json:"isAnnouncementOnly,omitempty"` // optional
 }
 
 type RoomList struct {
 	Items []*Room
 }
 
+// StaleSince reports whether the room has had no activity for longer than d.  Rooms whose LastActivity is unknown (the
+// zero time) are never reported as stale.
+func (r *Room) StaleSince(d time.Duration) bool {
+	if r == nil || r.LastActivity.IsZero() {
+		return false
+	}
+	return clk.Now().Sub(r.LastActivity.Time) > d
+}
+
 // https://developer.webex.com/endpoint-rooms-roomId-get.html
 func (c *client) GetRoom(roomId string) (*Room, error) {
-	if roomId == "" {
-		return nil, fmt.Errorf("no room ID specified")
-	}
-	resp, err := c.getRequest(fmt.Sprintf("%s/%s", RoomsURL, roomId), nil)
+	resp, err := c.GetRoomRaw(roomId)
 	if err != nil {
 		return nil, err
 	}
 
 	var room Room
-	err = json.Unmarshal(resp, &room)
+	err = c.unmarshal(resp, &room)
 	return &room, err
 }
 
+// GetRoomRaw works like GetRoom, except it returns the raw JSON of the response instead of a parsed Room.  This allows
+// callers to read fields that the Room struct does not (yet) model.
+func (c *client) GetRoomRaw(roomID string) (json.RawMessage, error) {
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+
+	return c.getRequest(fmt.Sprintf("%s/%s", RoomsURL, roomID), nil)
+}
+
+// GetRoomIfChanged works like GetRoom, except it makes a conditional request using an ETag from a previous call.  If
+// the room hasn't changed since that ETag was issued, the server responds with a 304, and this returns a nil room and
+// changed = false.  Otherwise, it returns the room, its new ETag, and changed = true.  Pass an empty etag to
+// unconditionally fetch the room (and get an ETag for next time).
+func (c *client) GetRoomIfChanged(roomID, etag string) (*Room, string, bool, error) {
+	if roomID == "" {
+		return nil, "", false, fmt.Errorf("no room ID specified")
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%s", RoomsURL, roomID), nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	res, resp, err := c.requestWithResponse(req)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if res.StatusCode == http.StatusNotModified {
+		return nil, etag, false, nil
+	}
+
+	var room Room
+	err = c.unmarshal(resp, &room)
+	return &room, res.Header.Get("ETag"), true, err
+}
+
 // GetRoomByName is a helper method that wraps GetRoom.  It will query for all rooms that the user is a member of, then
 // return the first one that matches the provided name.  If no such room exists, an error will be returned instead.
 func (c *client) GetRoomByName(roomName string) (*Room, error) {
@@ -59,23 +130,31 @@ func (c *client) GetRoomByName(roomName string) (*Room, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("no room with name %q was found", roomName)
+	return nil, fmt.Errorf("%w: %q", ErrRoomNotFound, roomName)
 }
 
 // https://developer.webex.com/endpoint-rooms-post.html
 func (c *client) CreateRoom(name, teamID string) (*Room, error) {
-	if name == "" {
-		return nil, fmt.Errorf("no room name specified")
-	}
 	// weirdly, a team ID isn't required
+	return c.CreateRoomWithOptions(&NewRoom{Title: name, TeamID: teamID})
+}
 
-	r := Room{
-		Title:  name,
-		TeamID: teamID,
+// CreateRoomWithOptions works like CreateRoom, except it can also create locked or announcement-only rooms, and set
+// the room's classification.  Like UpdateRoomName, it trims surrounding whitespace from the title (without modifying
+// r), and fails if what's left is empty or longer than MaxRoomTitleLength characters.
+func (c *client) CreateRoomWithOptions(r *NewRoom) (*Room, error) {
+	if r == nil {
+		return nil, fmt.Errorf("nil room")
+	}
+	title, err := roomTitle(r.Title)
+	if err != nil {
+		return nil, err
 	}
+	cp := *r
+	cp.Title = title
 
-	b := new(bytes.Buffer)
-	if err := json.NewEncoder(b).Encode(r); err != nil {
+	b, err := c.encode(cp)
+	if err != nil {
 		return nil, err
 	}
 	resp, err := c.postRequest(RoomsURL, b)
@@ -84,7 +163,7 @@ func (c *client) CreateRoom(name, teamID string) (*Room, error) {
 	}
 
 	var rr Room
-	err = json.Unmarshal(resp, &rr)
+	err = c.unmarshal(resp, &rr)
 	return &rr, err
 }
 
@@ -93,14 +172,15 @@ func (c *client) UpdateRoomName(roomID, newName string) (*Room, error) {
 	if roomID == "" {
 		return nil, fmt.Errorf("no room ID specified")
 	}
-	if newName == "" {
-		return nil, fmt.Errorf("no room name specified")
+	title, err := roomTitle(newName)
+	if err != nil {
+		return nil, err
 	}
 
-	r := Room{Title: newName}
+	r := Room{Title: title}
 
-	b := new(bytes.Buffer)
-	if err := json.NewEncoder(b).Encode(r); err != nil {
+	b, err := c.encode(r)
+	if err != nil {
 		return nil, err
 	}
 	resp, err := c.putRequest(fmt.Sprintf("%s/%s", RoomsURL, roomID), b)
@@ -109,7 +189,57 @@ func (c *client) UpdateRoomName(roomID, newName string) (*Room, error) {
 	}
 
 	var rr Room
-	err = json.Unmarshal(resp, &rr)
+	err = c.unmarshal(resp, &rr)
+	return &rr, err
+}
+
+// Returns a room title with surrounding whitespace trimmed, after checking that it's neither blank nor too long.
+func roomTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", fmt.Errorf("no room name specified")
+	}
+	if n := utf8.RuneCountInString(title); n > MaxRoomTitleLength {
+		return "", fmt.Errorf("room name is %d characters long, over the limit of %d", n, MaxRoomTitleLength)
+	}
+	return title, nil
+}
+
+// MoveRoomToTeam moves a group room into the team with the provided ID, or out of its team if teamID is empty.  The
+// update endpoint requires the room's title along with the team, so the room is fetched first (bypassing any GET
+// cache), and its current title is sent back unchanged.
+func (c *client) MoveRoomToTeam(roomID, teamID string) (*Room, error) {
+	if roomID == "" {
+		return nil, fmt.Errorf("no room ID specified")
+	}
+
+	u := fmt.Sprintf("%s/%s", RoomsURL, roomID)
+	resp, err := c.getRequestContext(context.Background(), u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var current Room
+	if err := c.unmarshal(resp, &current); err != nil {
+		return nil, err
+	}
+
+	// Room omits an empty TeamID, but detaching has to send it
+	r := struct {
+		Title  string `json:"title"`
+		TeamID string `json:"teamId"`
+	}{Title: current.Title, TeamID: teamID}
+
+	b, err := c.encode(r)
+	if err != nil {
+		return nil, err
+	}
+	resp, err = c.putRequest(u, b)
+	if err != nil {
+		return nil, err
+	}
+
+	var rr Room
+	err = c.unmarshal(resp, &rr)
 	return &rr, err
 }
 
@@ -125,6 +255,10 @@ func (c *client) DeleteRoom(roomID string) error {
 
 // https://developer.webex.com/endpoint-rooms-get.html
 func (c *client) ListRooms(max int, params *RoomListParams) ([]*Room, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
 	resp, reqErr := c.getRequestWithPaging(RoomsURL, params.values(), max)
 	if reqErr != nil && len(resp) == 0 { // if we got an error *and* results, parse them and return them
 		return nil, reqErr
@@ -133,20 +267,167 @@ func (c *client) ListRooms(max int, params *RoomListParams) ([]*Room, error) {
 	var rooms []*Room
 	for _, r := range resp {
 		var rl RoomList
-		if jsonErr := json.Unmarshal(r, &rl); jsonErr != nil {
+		if jsonErr := c.unmarshal(r, &rl); jsonErr != nil {
 			return rooms, fmt.Errorf("%v && %v", reqErr, jsonErr)
 		}
 		rooms = append(rooms, rl.Items...)
 	}
-	return rooms, nil
+	if c.dedupe {
+		rooms = DedupeRooms(rooms)
+	}
+	if rooms == nil {
+		rooms = []*Room{} // empty, not failed
+	}
+	return rooms, reqErr
+}
+
+// ListDirectRooms is a helper method that wraps ListRooms, listing every 1:1 room the client is in.
+func (c *client) ListDirectRooms() ([]*Room, error) {
+	return c.ListRooms(0, &RoomListParams{Type: RoomTypeDirect})
+}
+
+// ListGroupRooms is a helper method that wraps ListRooms, listing up to max of the group rooms the client is in.  Like
+// ListRooms, a max of 0 lists all of them.
+func (c *client) ListGroupRooms(max int) ([]*Room, error) {
+	return c.ListRooms(max, &RoomListParams{Type: RoomTypeGroup})
+}
+
+// ListRoomsPages works like ListRooms, except that instead of stopping after a number of rooms, it stops after
+// fetching the provided number of pages, however many rooms they hold (pages are the client's page size, see
+// SetMaxPerPage, but the server may return fewer).  Stopping there isn't an error.  The client's page limit (see
+// SetMaxPages) still applies on top of this.
+func (c *client) ListRoomsPages(pages int, params *RoomListParams) ([]*Room, error) {
+	if pages < 1 {
+		return nil, fmt.Errorf("at least one page must be requested, not %d", pages)
+	}
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
+	var rooms []*Room
+	fetched := 0
+	err := c.forEachPage(RoomsURL, params.values(), 0, func(page []byte) (bool, error) {
+		var rl RoomList
+		if err := c.unmarshal(page, &rl); err != nil {
+			return false, err
+		}
+		rooms = append(rooms, rl.Items...)
+		fetched++
+		return fetched < pages, nil
+	})
+	if err != nil && rooms == nil {
+		return nil, err
+	}
+	if c.dedupe {
+		rooms = DedupeRooms(rooms)
+	}
+	if rooms == nil {
+		rooms = []*Room{} // empty, not failed
+	}
+	return rooms, err
 }
 
+// ListActiveRooms is a helper method that lists every room with activity at or after since, most recently active
+// first.  Rooms are requested sorted by last activity, and paging stops as soon as a room older than since is reached,
+// so this doesn't have to list every room the client is in.
+func (c *client) ListActiveRooms(since time.Time) ([]*Room, error) {
+	params := &RoomListParams{SortBy: "lastactivity"}
+
+	var rooms []*Room
+	err := c.forEachPage(RoomsURL, params.values(), 0, func(page []byte) (bool, error) {
+		var rl RoomList
+		if err := c.unmarshal(page, &rl); err != nil {
+			return false, err
+		}
+		for _, r := range rl.Items {
+			if r.LastActivity.Before(since) {
+				return false, nil // everything after this is less recently active still
+			}
+			rooms = append(rooms, r)
+		}
+		return true, nil
+	})
+	if c.dedupe {
+		rooms = DedupeRooms(rooms)
+	}
+	if rooms == nil && err == nil {
+		rooms = []*Room{} // empty, not failed
+	}
+	return rooms, err
+}
+
+// ListStaleRooms is a helper method that lists the rooms that have had no activity for longer than olderThan, for
+// cleaning up abandoned rooms.  Rooms are paged through by last activity, so they're returned most recently active
+// first.  Rooms whose last activity is unknown are skipped, see StaleSince.
+func (c *client) ListStaleRooms(olderThan time.Duration) ([]*Room, error) {
+	params := &RoomListParams{SortBy: "lastactivity"}
+
+	var rooms []*Room
+	err := c.forEachPage(RoomsURL, params.values(), 0, func(page []byte) (bool, error) {
+		var rl RoomList
+		if err := c.unmarshal(page, &rl); err != nil {
+			return false, err
+		}
+		for _, r := range rl.Items {
+			if r.StaleSince(olderThan) {
+				rooms = append(rooms, r)
+			}
+		}
+		return true, nil
+	})
+	if err != nil && rooms == nil {
+		return nil, err
+	}
+	if c.dedupe {
+		rooms = DedupeRooms(rooms)
+	}
+	if rooms == nil {
+		rooms = []*Room{} // empty, not failed
+	}
+	return rooms, err
+}
+
+// ListRoomsSingle works like ListRooms, except that it makes exactly one request, for exactly max rooms, regardless of
+// the client's page size.  Along with the rooms, it returns the pagination links the server sent, for callers like
+// pagers that want more than the next page.  Links.Next is empty if there are no more rooms.
+func (c *client) ListRoomsSingle(max int, params *RoomListParams) ([]*Room, Links, error) {
+	if err := params.validate(); err != nil {
+		return nil, Links{}, err
+	}
+
+	page, links, err := c.getSinglePage(RoomsURL, params.values(), max)
+	if err != nil {
+		return nil, Links{}, err
+	}
+
+	var rl RoomList
+	if err := c.unmarshal(page, &rl); err != nil {
+		return nil, Links{}, err
+	}
+	if rl.Items == nil {
+		rl.Items = []*Room{} // empty, not failed
+	}
+	return rl.Items, links, nil
+}
+
+// RoomListParams filters and sorts ListRooms.  Type, if set, must be RoomTypeDirect or RoomTypeGroup.
 type RoomListParams struct {
 	TeamID string
 	Type   string
 	SortBy string
 }
 
+func (r *RoomListParams) validate() error {
+	if r == nil {
+		return nil
+	}
+	switch r.Type {
+	case "", RoomTypeDirect, RoomTypeGroup:
+		return nil
+	}
+	return fmt.Errorf("invalid room type %q, must be %q or %q", r.Type, RoomTypeDirect, RoomTypeGroup)
+}
+
 func (r *RoomListParams) values() url.Values {
 	uv := make(url.Values)
 	if r == nil {
@@ -3,10 +3,12 @@ package spark
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -102,6 +104,33 @@ var _ = Describe("Room (Mock)", func() {
 			Expect(c.GetRoom(roomID)).To(Equal(rooms.Items[0]))
 		})
 
+		It("treats an empty 200 body as a successful empty result", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(&bytes.Buffer{}), // empty body
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.GetRoom("1")).To(Equal(&Room{}))
+		})
+
+		It("fails on unmodeled fields only in strict mode", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"id":"1","unmodeledField":"hello"}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.GetRoom("1")).To(Equal(&Room{ID: "1"}))
+
+			_, err := c.SetStrictDecoding(true).GetRoom("1")
+			Expect(err).To(MatchError(`json: unknown field "unmodeledField"`))
+		})
+
 		It("fails if no room ID is specified", func() {
 			p, err := c.GetRoom("")
 			Expect(err).To(MatchError("no room ID specified"))
@@ -118,6 +147,107 @@ var _ = Describe("Room (Mock)", func() {
 		})
 	})
 
+	Describe("GetRoomRaw", func() {
+		It("returns fields the Room struct doesn't model", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/%s", RoomsURL, "1")))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"id":"1","unmodeledField":"hello"}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			raw, err := c.GetRoomRaw("1")
+			Expect(err).ToNot(HaveOccurred())
+
+			var fields map[string]interface{}
+			Expect(json.Unmarshal(raw, &fields)).To(Succeed())
+			Expect(fields["id"]).To(Equal("1"))
+			Expect(fields["unmodeledField"]).To(Equal("hello"))
+		})
+
+		It("fails if no room ID is specified", func() {
+			raw, err := c.GetRoomRaw("")
+			Expect(err).To(MatchError("no room ID specified"))
+			Expect(raw).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			raw, err := c.GetRoomRaw("1")
+			Expect(err).To(MatchError(mockErr))
+			Expect(raw).To(BeNil())
+		})
+	})
+
+	Describe("GetRoomIfChanged", func() {
+		It("gets a room and its ETag", func() {
+			roomID := rooms.Items[0].ID
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/%s", RoomsURL, roomID)))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				Expect(req.Header.Get("If-None-Match")).To(BeEmpty())
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(rooms.Items[0])).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Etag": {`"v1"`}},
+				}
+				return r, nil
+			}
+
+			room, etag, changed, err := c.GetRoomIfChanged(roomID, "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(room).To(Equal(rooms.Items[0]))
+			Expect(etag).To(Equal(`"v1"`))
+			Expect(changed).To(BeTrue())
+		})
+
+		It("reports an unchanged room on a 304", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.Header.Get("If-None-Match")).To(Equal(`"v1"`))
+
+				r := &http.Response{
+					Body:       closer(&bytes.Buffer{}),
+					StatusCode: http.StatusNotModified,
+				}
+				return r, nil
+			}
+
+			room, etag, changed, err := c.GetRoomIfChanged("1", `"v1"`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(room).To(BeNil())
+			Expect(etag).To(Equal(`"v1"`))
+			Expect(changed).To(BeFalse())
+		})
+
+		It("fails if no room ID is specified", func() {
+			room, _, _, err := c.GetRoomIfChanged("", "")
+			Expect(err).To(MatchError("no room ID specified"))
+			Expect(room).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			room, _, changed, err := c.GetRoomIfChanged("1", `"v1"`)
+			Expect(err).To(MatchError(mockErr))
+			Expect(room).To(BeNil())
+			Expect(changed).To(BeFalse())
+		})
+	})
+
 	Describe("GetRoomByName", func() {
 		It("gets a room by name", func() {
 			roomName := rooms.Items[0].Title
@@ -159,7 +289,8 @@ var _ = Describe("Room (Mock)", func() {
 			}
 
 			p, err := c.GetRoomByName(roomName)
-			Expect(err).To(MatchError(fmt.Sprintf("no room with name %q was found", roomName)))
+			Expect(errors.Is(err, ErrRoomNotFound)).To(BeTrue())
+			Expect(err).To(MatchError(fmt.Sprintf("room not found: %q", roomName)))
 			Expect(p).To(BeNil())
 		})
 
@@ -332,7 +463,7 @@ var _ = Describe("Room (Mock)", func() {
 			max := len(rooms.Items)
 			params := RoomListParams{
 				TeamID: "test team ID",
-				Type:   "test type",
+				Type:   RoomTypeGroup,
 				SortBy: "test sort by",
 			}
 
@@ -359,6 +490,68 @@ var _ = Describe("Room (Mock)", func() {
 			Expect(c.ListRooms(max, &params)).To(ConsistOf(rooms.Items))
 		})
 
+		It("de-duplicates items repeated across pages, if enabled", func() {
+			pages := []RoomList{
+				{Items: []*Room{rooms.Items[0], {ID: "2", Title: "room 2"}}},
+				{Items: []*Room{{ID: "2", Title: "room 2"}, {ID: "3", Title: "room 3"}}}, // the data shifted
+			}
+
+			for _, dedupe := range []bool{false, true} {
+				calls := 0
+				mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+					var b bytes.Buffer
+					Expect(json.NewEncoder(&b).Encode(pages[calls])).To(Succeed())
+					r := &http.Response{
+						Body:       closer(&b),
+						StatusCode: http.StatusOK,
+					}
+					if calls++; calls < len(pages) {
+						r.Header = map[string][]string{
+							"Link": {fmt.Sprintf("<%s>; rel=\"next\"", RoomsURL)},
+						}
+					}
+					return r, nil
+				}
+
+				r, err := c.SetDeduplication(dedupe).ListRooms(0, nil)
+				Expect(err).ToNot(HaveOccurred())
+				if dedupe {
+					Expect(r).To(Equal([]*Room{pages[0].Items[0], pages[0].Items[1], pages[1].Items[1]}))
+				} else {
+					Expect(r).To(HaveLen(4))
+				}
+			}
+		})
+
+		It("returns an empty, non-nil slice when there are no results", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"items":[]}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			p, err := c.ListRooms(0, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p).ToNot(BeNil())
+			Expect(p).To(BeEmpty())
+		})
+
+		It("fails with a nil slice if the results can't be decoded", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString("not json")),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			p, err := c.ListRooms(0, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(p).To(BeNil())
+		})
+
 		It("passes through errors encountered during the request", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
 				return nil, mockErr
@@ -369,6 +562,306 @@ var _ = Describe("Room (Mock)", func() {
 		})
 	})
 
+	Describe("RoomListParams", func() {
+		It("builds empty values from nil params", func() {
+			uv := (*RoomListParams)(nil).values()
+			Expect(uv).ToNot(BeNil())
+			Expect(uv).To(BeEmpty())
+		})
+
+		It("rejects unknown room types before sending anything", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Fail("no request should be sent")
+				return nil, nil
+			}
+
+			params := &RoomListParams{Type: "Direct"}
+			_, err := c.ListRooms(0, params)
+			Expect(err).To(MatchError(`invalid room type "Direct", must be "direct" or "group"`))
+			_, err = c.ListRoomsPages(1, params)
+			Expect(err).To(HaveOccurred())
+			_, _, err = c.ListRoomsSingle(0, params)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("ListDirectRooms and ListGroupRooms", func() {
+		var roomType string
+
+		BeforeEach(func() {
+			roomType = ""
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Path).To(Equal("/v1/rooms"))
+				roomType = req.URL.Query().Get("type")
+				b, err := json.Marshal(RoomList{Items: []*Room{{ID: "1", Type: roomType}}})
+				Expect(err).ToNot(HaveOccurred())
+				return &http.Response{Body: closer(bytes.NewBuffer(b)), StatusCode: http.StatusOK}, nil
+			}
+		})
+
+		It("lists only direct rooms", func() {
+			Expect(c.ListDirectRooms()).To(Equal([]*Room{{ID: "1", Type: RoomTypeDirect}}))
+			Expect(roomType).To(Equal("direct"))
+		})
+
+		It("lists only group rooms, up to max", func() {
+			Expect(c.ListGroupRooms(5)).To(Equal([]*Room{{ID: "1", Type: RoomTypeGroup}}))
+			Expect(roomType).To(Equal("group"))
+		})
+	})
+
+	Describe("ListActiveRooms", func() {
+		It("stops paging at the first room that was last active before the cutoff", func() {
+			since := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+			c = c.SetMaxPerPage(2)
+
+			// Most recently active first, spanning the cutoff on the second page
+			all := []*Room{
+				{ID: "1", LastActivity: Time{since.Add(3 * time.Hour)}},
+				{ID: "2", LastActivity: Time{since.Add(2 * time.Hour)}},
+				{ID: "3", LastActivity: Time{since}},
+				{ID: "4", LastActivity: Time{since.Add(-time.Hour)}},
+				{ID: "5", LastActivity: Time{since.Add(-2 * time.Hour)}},
+				{ID: "6", LastActivity: Time{since.Add(-3 * time.Hour)}},
+			}
+
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Query().Get("sortBy")).To(Equal("lastactivity"))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(RoomList{Items: all[calls*2 : calls*2+2]})).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s>; rel=\"next\"", RoomsURL)},
+					},
+				}
+				calls++
+				return r, nil
+			}
+
+			Expect(c.ListActiveRooms(since)).To(Equal(all[:3]))
+			Expect(calls).To(Equal(2))
+		})
+
+		It("returns an empty list if no rooms were active", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(rooms)).To(Succeed())
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+
+			rs, err := c.ListActiveRooms(time.Now())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(rs).ToNot(BeNil())
+			Expect(rs).To(BeEmpty())
+		})
+	})
+
+	Describe("stale rooms", func() {
+		now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+		day := 24 * time.Hour
+
+		BeforeEach(func() {
+			clk = &fakeClock{now: now}
+		})
+
+		AfterEach(func() {
+			clk = realClock{}
+		})
+
+		It("reports whether a room has been inactive for longer than a duration", func() {
+			Expect((&Room{LastActivity: Time{now.Add(-31 * day)}}).StaleSince(30 * day)).To(BeTrue())
+			Expect((&Room{LastActivity: Time{now.Add(-29 * day)}}).StaleSince(30 * day)).To(BeFalse())
+		})
+
+		It("never reports a room with unknown activity as stale", func() {
+			Expect((&Room{}).StaleSince(time.Hour)).To(BeFalse())
+			Expect((*Room)(nil).StaleSince(time.Hour)).To(BeFalse())
+		})
+
+		It("lists the rooms past the threshold from every page", func() {
+			c = c.SetMaxPerPage(2)
+
+			// Most recently active first, as sortBy=lastactivity lists them
+			all := []*Room{
+				{ID: "fresh", LastActivity: Time{now.Add(-time.Hour)}},
+				{ID: "unknown"},
+				{ID: "stale 1", LastActivity: Time{now.Add(-10 * day)}},
+				{ID: "stale 2", LastActivity: Time{now.Add(-40 * day)}},
+			}
+
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Query().Get("sortBy")).To(Equal("lastactivity"))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(RoomList{Items: all[calls*2 : calls*2+2]})).To(Succeed())
+				r := &http.Response{Body: closer(&b), StatusCode: http.StatusOK, Header: http.Header{}}
+				if calls == 0 {
+					r.Header.Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", RoomsURL))
+				}
+				calls++
+				return r, nil
+			}
+
+			Expect(c.ListStaleRooms(7 * day)).To(Equal(all[2:]))
+			Expect(calls).To(Equal(2))
+		})
+
+		It("returns an empty list if no rooms are stale", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(RoomList{Items: []*Room{{ID: "1", LastActivity: Time{now}}}})).To(Succeed())
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+
+			rs, err := c.ListStaleRooms(day)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(rs).ToNot(BeNil())
+			Expect(rs).To(BeEmpty())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			rs, err := c.ListStaleRooms(day)
+			Expect(err).To(MatchError(mockErr))
+			Expect(rs).To(BeNil())
+		})
+	})
+
+	Describe("ListRoomsPages", func() {
+		// Serves pages of varying sizes, forever
+		var calls int
+		BeforeEach(func() {
+			calls = 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(strings.Split(req.URL.String(), "?")[0]).To(Equal(RoomsURL))
+				Expect(req.URL.Query().Get("type")).To(Equal("group"))
+				calls++
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(RoomList{Items: rooms.Items[:1+calls%len(rooms.Items)]})).To(Succeed())
+				return &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Link": {fmt.Sprintf("<%s?type=group&cursor=%d>; rel=\"next\"", RoomsURL, calls)}},
+				}, nil
+			}
+		})
+
+		It("fetches exactly the requested number of pages, however many rooms they hold", func() {
+			for _, pages := range []int{1, 3, 5} {
+				calls = 0
+				rs, err := c.ListRoomsPages(pages, &RoomListParams{Type: "group"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(calls).To(Equal(pages))
+				Expect(len(rs)).To(BeNumerically(">=", pages))
+			}
+		})
+
+		It("stops early if the server runs out of pages", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(rooms)).To(Succeed())
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+
+			Expect(c.ListRoomsPages(3, nil)).To(Equal(rooms.Items))
+			Expect(calls).To(Equal(1))
+		})
+
+		It("still obeys the client's page limit", func() {
+			rs, err := c.SetMaxPages(2).ListRoomsPages(3, &RoomListParams{Type: "group"})
+			Expect(err).To(MatchError(ErrPageLimitExceeded))
+			Expect(rs).ToNot(BeEmpty())
+			Expect(calls).To(Equal(2))
+		})
+
+		It("fails if less than one page is requested", func() {
+			rs, err := c.ListRoomsPages(0, nil)
+			Expect(err).To(MatchError("at least one page must be requested, not 0"))
+			Expect(rs).To(BeNil())
+			Expect(calls).To(BeZero())
+		})
+	})
+
+	Describe("ListRoomsSingle", func() {
+		It("requests exactly max rooms in a single request, regardless of the client's page size", func() {
+			c = c.SetMaxPerPage(2)
+			next := fmt.Sprintf("%s?max=7&cursor=abc", RoomsURL)
+
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				uri := strings.Split(req.URL.String(), "?")[0]
+				Expect(uri).To(Equal(RoomsURL))
+				Expect(req.URL.Query().Get("max")).To(Equal("7"))
+				Expect(req.URL.Query().Get("type")).To(Equal("group"))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+				calls++
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(rooms)).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+					Header: map[string][]string{
+						"Link": {fmt.Sprintf("<%s>; rel=\"next\", <%s?cursor=first>; rel=\"first prev\", <%s?cursor=z>; rel=\"last\"", next, RoomsURL, RoomsURL)},
+					},
+				}
+				return r, nil
+			}
+
+			rs, links, err := c.ListRoomsSingle(7, &RoomListParams{Type: "group"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(rs).To(Equal(rooms.Items))
+			Expect(links).To(Equal(Links{
+				First: RoomsURL + "?cursor=first",
+				Prev:  RoomsURL + "?cursor=first",
+				Next:  next,
+				Last:  RoomsURL + "?cursor=z",
+			}))
+			Expect(calls).To(Equal(1))
+		})
+
+		It("returns no next page URL on the last page", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"items":[]}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			rs, links, err := c.ListRoomsSingle(7, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(rs).ToNot(BeNil())
+			Expect(rs).To(BeEmpty())
+			Expect(links).To(BeZero())
+		})
+
+		It("handles an error response", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"message":"broken"}`)),
+					StatusCode: http.StatusInternalServerError,
+				}
+				return r, nil
+			}
+
+			rs, links, err := c.ListRoomsSingle(7, nil)
+			Expect(err).To(BeAssignableToTypeOf(&APIError{}))
+			Expect(rs).To(BeNil())
+			Expect(links).To(BeZero())
+		})
+	})
+
 	Describe("CreateRoom", func() {
 		It("creates a room", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
@@ -393,12 +886,63 @@ var _ = Describe("Room (Mock)", func() {
 			Expect(c.CreateRoom(rooms.Items[0].Title, rooms.Items[0].TeamID)).To(Equal(rooms.Items[1]))
 		})
 
+		It("treats an empty 200 body as a successful empty result", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(&bytes.Buffer{}), // empty body
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.CreateRoom("1", "")).To(Equal(&Room{}))
+		})
+
 		It("fails if an empty room name is provided", func() {
 			p, err := c.CreateRoom("", "")
 			Expect(err).To(MatchError("no room name specified"))
 			Expect(p).To(BeNil())
 		})
 
+		It("fails if a whitespace-only room name is provided", func() {
+			p, err := c.CreateRoom(" \t\n", "")
+			Expect(err).To(MatchError("no room name specified"))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails if the room name is too long, counting characters rather than bytes", func() {
+			p, err := c.CreateRoom(strings.Repeat("a", MaxRoomTitleLength+1), "")
+			Expect(err).To(MatchError("room name is 101 characters long, over the limit of 100"))
+			Expect(p).To(BeNil())
+
+			var titles []string
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var r NewRoom
+				Expect(json.NewDecoder(req.Body).Decode(&r)).To(Succeed())
+				titles = append(titles, r.Title)
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1"}`)), StatusCode: http.StatusOK}, nil
+			}
+			_, err = c.CreateRoom(strings.Repeat("é", MaxRoomTitleLength), "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(titles).To(Equal([]string{strings.Repeat("é", MaxRoomTitleLength)}))
+		})
+
+		It("trims whitespace from the room name, without modifying the caller's room", func() {
+			var title string
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var r NewRoom
+				Expect(json.NewDecoder(req.Body).Decode(&r)).To(Succeed())
+				title = r.Title
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1"}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			r := &NewRoom{Title: "  room\n"}
+			_, err := c.CreateRoomWithOptions(r)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(title).To(Equal("room"))
+			Expect(r.Title).To(Equal("  room\n"))
+		})
+
 		It("passes through errors encountered during the request", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
 				return nil, mockErr
@@ -407,6 +951,91 @@ var _ = Describe("Room (Mock)", func() {
 			Expect(err).To(MatchError(mockErr))
 			Expect(p).To(BeNil())
 		})
+
+		It("fails clearly if a proxy responds with an HTML page", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString("<html><body>Gateway error</body></html>")),
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+				}
+				return r, nil
+			}
+			p, err := c.CreateRoom("1", "")
+			Expect(err).To(MatchError(ContainSubstring("expected JSON response but got text/html")))
+			Expect(err.Error()).To(ContainSubstring("Gateway error"))
+			Expect(p).To(BeNil())
+		})
+	})
+
+	Describe("CreateRoomWithOptions", func() {
+		It("creates a room with all of the options", func() {
+			n := &NewRoom{
+				Title:              "room",
+				TeamID:             "team 1",
+				ClassificationID:   "classification 1",
+				IsLocked:           true,
+				IsAnnouncementOnly: true,
+			}
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(RoomsURL))
+				Expect(req.Method).To(Equal("POST"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				var p map[string]interface{}
+				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
+				Expect(p).To(Equal(map[string]interface{}{
+					"title":              "room",
+					"teamId":             "team 1",
+					"classificationId":   "classification 1",
+					"isLocked":           true,
+					"isAnnouncementOnly": true,
+				}))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(rooms.Items[1])).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.CreateRoomWithOptions(n)).To(Equal(rooms.Items[1]))
+		})
+
+		It("omits unset options", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var p map[string]interface{}
+				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
+				Expect(p).To(Equal(map[string]interface{}{"title": "room"}))
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"id":"1","classificationId":"c","isAnnouncementOnly":true}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.CreateRoomWithOptions(&NewRoom{Title: "room"})).To(Equal(&Room{
+				ID:                 "1",
+				ClassificationID:   "c",
+				IsAnnouncementOnly: true,
+			}))
+		})
+
+		It("fails if a nil argument is provided", func() {
+			p, err := c.CreateRoomWithOptions(nil)
+			Expect(err).To(MatchError("nil room"))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails if an empty room name is provided", func() {
+			p, err := c.CreateRoomWithOptions(&NewRoom{IsLocked: true})
+			Expect(err).To(MatchError("no room name specified"))
+			Expect(p).To(BeNil())
+		})
 	})
 
 	Describe("UpdateRoomName", func() {
@@ -445,6 +1074,16 @@ var _ = Describe("Room (Mock)", func() {
 			Expect(p).To(BeNil())
 		})
 
+		It("fails if a whitespace-only or too long room name is provided", func() {
+			p, err := c.UpdateRoomName("1", "   ")
+			Expect(err).To(MatchError("no room name specified"))
+			Expect(p).To(BeNil())
+
+			p, err = c.UpdateRoomName("1", strings.Repeat("a", MaxRoomTitleLength+1))
+			Expect(err).To(MatchError(ContainSubstring("over the limit of 100")))
+			Expect(p).To(BeNil())
+		})
+
 		It("passes through errors encountered during the request", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
 				return nil, mockErr
@@ -455,6 +1094,52 @@ var _ = Describe("Room (Mock)", func() {
 		})
 	})
 
+	Describe("MoveRoomToTeam", func() {
+		It("moves a room into a team, or out of it", func() {
+			for _, teamID := range []string{"team", ""} {
+				teamID := teamID
+				var methods []string
+				mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+					Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/%s", RoomsURL, rooms.Items[0].ID)))
+					methods = append(methods, req.Method)
+					if req.Method == "GET" {
+						var b bytes.Buffer
+						Expect(json.NewEncoder(&b).Encode(&Room{ID: rooms.Items[0].ID, Title: "current title"})).To(Succeed())
+						return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+					}
+					Expect(req.Method).To(Equal("PUT"))
+
+					// The update endpoint rejects bodies without a title
+					var sent map[string]interface{}
+					Expect(json.NewDecoder(req.Body).Decode(&sent)).To(Succeed())
+					Expect(sent).To(Equal(map[string]interface{}{"title": "current title", "teamId": teamID}))
+
+					var b bytes.Buffer
+					Expect(json.NewEncoder(&b).Encode(&Room{ID: rooms.Items[0].ID, TeamID: teamID})).To(Succeed())
+					return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+				}
+
+				Expect(c.MoveRoomToTeam(rooms.Items[0].ID, teamID)).To(Equal(&Room{ID: rooms.Items[0].ID, TeamID: teamID}))
+				Expect(methods).To(Equal([]string{"GET", "PUT"}))
+			}
+		})
+
+		It("fails if an empty room ID is provided", func() {
+			p, err := c.MoveRoomToTeam("", "team")
+			Expect(err).To(MatchError("no room ID specified"))
+			Expect(p).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			p, err := c.MoveRoomToTeam("1", "team")
+			Expect(err).To(MatchError(mockErr))
+			Expect(p).To(BeNil())
+		})
+	})
+
 	Describe("DeleteRoom", func() {
 		It("deletes a room", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
@@ -472,6 +1157,20 @@ var _ = Describe("Room (Mock)", func() {
 			Expect(c.DeleteRoom(rooms.Items[0].ID)).To(Succeed())
 		})
 
+		It("fails if a 200 response contains an error object", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"message":"Room not found","trackingId":"1"}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			err := c.DeleteRoom(rooms.Items[0].ID)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Room not found"))
+		})
+
 		It("fails if the room ID is empty", func() {
 			Expect(c.DeleteRoom("")).To(MatchError("no room ID specified"))
 		})
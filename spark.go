@@ -1,44 +1,238 @@
 package spark
 
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client is a Spark API client.  All of the list methods follow the same contract: a successful query with no results
+// returns a non-nil, empty slice, while a query that fails outright returns a nil slice and an error.  If the query
+// fails partway through paging, the results received before the failure are returned along with the error.  Their
+// params arguments may always be nil, which is the same as passing empty params.
 type Client interface {
 	SetMaxPerPage(max int) Client
+	SetMaxPerPageFor(resource string, max int) Client
+	SetMaxPages(pages int) Client
+	SetStrictDecoding(strict bool) Client
+	SetCodec(codec Codec) Client
+	SetMaxRetries(retries int) Client
+	SetRateLimit(perSecond float64, burst int) Client
+	SetGETCache(ttl time.Duration, maxEntries int) Client
+	SetToken(token string) Client
+	SetAdminToken(admin bool) Client
+	SetUserAgent(ua string) Client
+	SetAPIVersion(v string) Client
+	SetDeduplication(dedupe bool) Client
+	SetResponseObserver(fn func(resource string, h http.Header)) Client
+	SetMetricsObserver(fn func(resource, method string, status int, latency time.Duration)) Client
+	SetHTTPClient(cli *http.Client) Client
+	SetTLSConfig(cfg *tls.Config) Client
+	SetConnectionPool(maxIdle, maxIdlePerHost int) Client
+	SetFollowRedirects(follow bool) Client
+	SetMarkdownFallback(fallback bool) Client
+	SetRequireClassification(require bool) Client
+	SetDebugWriter(w io.Writer) Client
+	Close() error
+	Config() ClientConfig
+
+	DoJSON(method, path string, body interface{}, out interface{}) error
+	ServiceStatus() (*Status, error)
 
 	GetPerson(personID string) (*Person, error)
+	GetPersonRaw(personID string) (json.RawMessage, error)
 	GetMyself() (*Person, error)
+	Ping(ctx context.Context) error
+	TokenInfo() (*TokenInfo, error)
+	GetPersonByEmail(email string) (*Person, error)
+	IsSelfAuthored(ctx context.Context, msg *Message) (bool, error)
 	ListPeople(max int, params *PeopleListParams) ([]*Person, error)
+	ListPeopleSingle(max int, params *PeopleListParams) ([]*Person, Links, error)
+	ListOrgPeople(orgID string) ([]*Person, error)
 	CreatePerson(p *Person) (*Person, error)
+	CreatePeople(ctx context.Context, people []*Person, concurrency int) ([]*Person, error)
 	UpdatePerson(p *Person) (*Person, error)
+	UpdatePersonFields(personID string, fields map[string]interface{}) (*Person, error)
+	SetPersonAvatar(personID string, image io.Reader, contentType string) (*Person, error)
 	DeletePerson(ID string) error
 
 	GetRoom(roomId string) (*Room, error)
+	GetRoomRaw(roomID string) (json.RawMessage, error)
+	GetRoomIfChanged(roomID, etag string) (*Room, string, bool, error)
 	GetRoomByName(roomName string) (*Room, error)
 	ListRooms(max int, params *RoomListParams) ([]*Room, error)
+	ListDirectRooms() ([]*Room, error)
+	ListGroupRooms(max int) ([]*Room, error)
+	ListRoomsPages(pages int, params *RoomListParams) ([]*Room, error)
+	ListActiveRooms(since time.Time) ([]*Room, error)
+	ListStaleRooms(olderThan time.Duration) ([]*Room, error)
+	ListRoomsSingle(max int, params *RoomListParams) ([]*Room, Links, error)
 	CreateRoom(name, teamID string) (*Room, error)
+	CreateRoomWithOptions(r *NewRoom) (*Room, error)
 	UpdateRoomName(roomID, newName string) (*Room, error)
+	MoveRoomToTeam(roomID, teamID string) (*Room, error)
 	DeleteRoom(roomID string) error
 
+	GetMembership(roomID, personID string) (*Membership, error)
+	CreateMembership(m *NewMembership) (*Membership, error)
+	CountRoomMembers(roomID string) (int, error)
+	ListRoomModerators(roomID string) ([]*Membership, error)
+	RoomReadStatus(roomID string) (map[string]string, error)
+	ListMemberships(max int, params *MembershipListParams) ([]*Membership, error)
+	ListMyMemberships() ([]*Membership, error)
+
 	GetMessage(messageID string) (*Message, error)
+	GetMessageRaw(messageID string) (json.RawMessage, error)
 	ListMessages(max int, roomID string, params *MessageListParams) ([]*Message, error)
+	ListMessagesSingle(max int, roomID string, params *MessageListParams) ([]*Message, Links, error)
+	ListMessagesTruncated(max int, roomID string, params *MessageListParams) ([]*Message, bool, error)
+	ListMessagesBetween(roomID string, from, to time.Time) ([]*Message, error)
+	ListMyMentions(roomID string, since time.Time) ([]*Message, error)
+	GetThread(messageID string) (parent *Message, replies []*Message, err error)
+	FindMessages(roomID string, match func(m *Message) bool, limit int) ([]*Message, error)
+	ListAllRoomMessages(since time.Time) (map[string][]*Message, error)
 	CreateMessage(m *NewMessage) (*Message, error)
+	CreateMessageWithOptions(m *NewMessage, opts *CreateMessageOptions) (*Message, error)
+	CreateMessageWithFiles(m *NewMessage, files ...*FileUpload) (*Message, error)
+	UpdateMessage(messageID string, m *NewMessage) (*Message, error)
 	DeleteMessage(messageID string) error
+	DeleteOwnMessage(messageID string) error
+	DeleteOwnMessagesInRoom(roomID string) (int, error)
 
 	GetWebhook(webhookID string) (*Webhook, error)
+	GetWebhookRaw(webhookID string) (json.RawMessage, error)
 	ListWebhooks(max int) ([]*Webhook, error)
+	ListOrgWebhooks(max int) ([]*Webhook, error)
+	ListWebhooksSingle(max int) ([]*Webhook, Links, error)
 	CreateWebhook(w *NewWebhook) (*Webhook, error)
 	UpdateWebhook(w *Webhook) (*Webhook, error)
 	DeleteWebhook(hookID string) error
+	FindWebhooksByTarget(targetURL string) ([]*Webhook, error)
+	DeleteWebhooksByTarget(targetURL string) (int, error)
+	PingWebhookTarget(targetURL string) error
+
+	GetAttachmentAction(actionID string) (*AttachmentAction, error)
+	HandleCardSubmit(event *WebhookEvent) (*AttachmentAction, error)
 }
 
 type client struct {
 	token   string
 	pageMax int
+	strict  bool
+	codec   Codec
+
+	// Per-resource overrides of pageMax, keyed by resource name (ex. "messages")
+	resourcePageMax map[string]int
+	maxPages        int
+	dedupe          bool
+
+	maxRetries int
+	admin      bool
+	userAgent  string
+	apiVersion string
+	observer   func(resource string, h http.Header)
+	metrics    func(resource, method string, status int, latency time.Duration)
+
+	// Shared between copies of the client made by the SetX methods after SetRateLimit, so they're paced together.  If
+	// nil, requests aren't paced.
+	limiter *rateLimiter
+
+	// Shared between copies of the client made by the SetX methods after SetGETCache.  If nil, nothing is cached.
+	cache *getCache
+
+	markdownFallback      bool
+	requireClassification bool
+
+	debug io.Writer
+
+	// If nil, the package's default httpCli is used
+	httpCli     httpClient
+	noRedirects bool
+
+	// Shared between copies of the client made by the SetX methods, since they all authenticate as the same identity
+	self *selfCache
+	sent *sentCache
+}
+
+// Caches the identity that the client's token authenticates as, since it can't change for the lifetime of the token (or
+// across the tokens given to SetToken).
+type selfCache struct {
+	mu sync.Mutex
+	me *Person
+}
+
+// The number of idempotency keys that are remembered, after which the oldest are forgotten.
+const maxIdempotencyKeys = 1000
+
+// Remembers the responses to messages that were created with an idempotency key, so they aren't sent twice.
+type sentCache struct {
+	mu    sync.Mutex
+	max   int
+	resps map[string][]byte
+	order []string // oldest first
+}
+
+func newSentCache(max int) *sentCache {
+	return &sentCache{max: max, resps: make(map[string][]byte)}
+}
+
+// Returns the response to the message created with the given key, if there was one.  An empty key is never found.
+func (s *sentCache) get(key string) ([]byte, bool) {
+	if key == "" {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp, ok := s.resps[key]
+	return resp, ok
+}
+
+// Records the response to the message created with the given key.  An empty key is ignored.
+func (s *sentCache) add(key string, resp []byte) {
+	if key == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.resps[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.resps[key] = resp
+	for len(s.order) > s.max {
+		delete(s.resps, s.order[0])
+		s.order = s.order[1:]
+	}
 }
 
+// Creates a new client that authenticates with the provided token.  Surrounding whitespace (ex. a trailing newline from
+// a token file) is trimmed from the token.  New never fails, even for an empty token; use NewValidated to catch that.
 func New(token string) Client {
 	return &client{
-		token:   token,
-		pageMax: 50,
+		token:     strings.TrimSpace(token),
+		pageMax:   50,
+		maxPages:  defaultMaxPages,
+		userAgent: DefaultUserAgent,
+		self:      new(selfCache),
+		sent:      newSentCache(maxIdempotencyKeys),
+	}
+}
+
+// Works like New, except it fails if the token is empty (or only whitespace), rather than creating a client whose every
+// request will be rejected.  The token is not checked with the server; to confirm that it's valid, call GetMyself on the
+// new client.
+func NewValidated(token string) (Client, error) {
+	if strings.TrimSpace(token) == "" {
+		return nil, fmt.Errorf("no token specified")
 	}
+	return New(token), nil
 }
 
 // Sets the maximum entries per page for paginated queries.  Does not modify the calling client.  Instead, returns
@@ -51,8 +245,299 @@ func New(token string) Client {
 //   cli := spark.New(token).SetMaxPerPage(25)
 //
 func (c *client) SetMaxPerPage(max int) Client {
-	return &client{
-		token:   c.token,
-		pageMax: max,
+	cp := *c
+	cp.pageMax = max
+	return &cp
+}
+
+// Works like SetMaxPerPage, except the max only applies to paginated queries of one resource, ex. "messages" or
+// "rooms".  Other resources continue to use the client's default max.  Setting a max of 0 removes the override.
+func (c *client) SetMaxPerPageFor(resource string, max int) Client {
+	cp := *c
+	cp.resourcePageMax = make(map[string]int, len(c.resourcePageMax)+1)
+	for k, v := range c.resourcePageMax {
+		cp.resourcePageMax[k] = v
+	}
+	if max > 0 {
+		cp.resourcePageMax[resource] = max
+	} else {
+		delete(cp.resourcePageMax, resource)
+	}
+	return &cp
+}
+
+// Returns the max entries per page for paginated queries of the given resource.
+func (c *client) maxPerPage(resource string) int {
+	if max, ok := c.resourcePageMax[resource]; ok {
+		return max
+	}
+	return c.pageMax
+}
+
+// The default limit on how many pages a single paginated query will fetch.
+const defaultMaxPages = 10000
+
+// Sets a limit on how many pages a single paginated query will fetch, as a safeguard against a misbehaving server that
+// never stops returning next links.  A query that reaches the limit returns the results it has so far, along with
+// ErrPageLimitExceeded.  Defaults to 10000.  A limit of 0 or less disables it.  Like SetMaxPerPage, this returns a
+// modified *copy* of the client.
+func (c *client) SetMaxPages(pages int) Client {
+	cp := *c
+	cp.maxPages = pages
+	return &cp
+}
+
+// Enables or disables de-duplication of list results.  When enabled, the list methods drop any item whose ID they've
+// already returned, which Spark can send when the data shifts while a query is paging through it.  See DedupeRooms.
+// Off by default.  Like SetMaxPerPage, this returns a modified *copy* of the client.
+func (c *client) SetDeduplication(dedupe bool) Client {
+	cp := *c
+	cp.dedupe = dedupe
+	return &cp
+}
+
+// Enables or disables strict decoding of responses.  When enabled, any field in a response that the destination struct
+// does not model causes the call to fail, rather than being silently dropped.  This is intended for catching API
+// changes during testing, and is off by default.  Like SetMaxPerPage, this returns a modified *copy* of the client.
+func (c *client) SetStrictDecoding(strict bool) Client {
+	cp := *c
+	cp.strict = strict
+	return &cp
+}
+
+// Sets the codec that request bodies are encoded with, and response bodies decoded with, ex. to plug in a faster JSON
+// implementation for bulk listings.  Strict decoding (see SetStrictDecoding) always uses encoding/json, since it
+// relies on its DisallowUnknownFields, and so do the package-level functions that have no client, like
+// ParseWebhookEvent.  A nil codec restores StdCodec.  Like SetMaxPerPage, this returns a modified *copy* of the client.
+func (c *client) SetCodec(codec Codec) Client {
+	cp := *c
+	cp.codec = codec
+	return &cp
+}
+
+// Sets how many times a request that is rate limited by the server (HTTP 429) will be retried before giving up.  Each
+// retry waits for the duration requested by the server's Retry-After header.  Defaults to 0, meaning rate limited
+// requests fail immediately.  Like SetMaxPerPage, this returns a modified *copy* of the client.
+func (c *client) SetMaxRetries(retries int) Client {
+	cp := *c
+	cp.maxRetries = retries
+	return &cp
+}
+
+// Paces the client's requests to at most perSecond on average, with bursts of up to burst requests, to stay under
+// Spark's rate limits rather than only reacting to 429s after hitting them.  Every request waits for its turn before
+// it's sent, including retries and each page of a paged query, and gives up if its context is done first.  The limit
+// is shared by every copy of the client made from the returned one, so they're paced together.  A perSecond of 0 or
+// less removes the limit, and a burst of less than 1 is treated as 1.  Like SetMaxPerPage, this returns a modified
+// *copy* of the client.
+func (c *client) SetRateLimit(perSecond float64, burst int) Client {
+	cp := *c
+	cp.limiter = nil
+	if perSecond > 0 {
+		if burst < 1 {
+			burst = 1
+		}
+		cp.limiter = newRateLimiter(perSecond, burst)
+	}
+	return &cp
+}
+
+// Caches the responses to requests for single resources (ex. GetRoom and GetPerson) for ttl, for callers like
+// dashboards that fetch the same resources over and over.  Lists are never cached, nor are responses whose
+// Cache-Control header says no-store.  Once maxEntries responses are cached, the least recently used is evicted.  A
+// resource's cached response is dropped when the client (or a copy sharing the cache) sends any other request for it,
+// ex. UpdatePerson, but changes made elsewhere go unnoticed until ttl passes.  Only the URL that was written to is
+// dropped, so a resource reached by another URL, or changed as a side effect of writing to another resource (ex. a
+// room's activity when a message is posted to it), can be stale until ttl passes.  GetMyself is never cached, since
+// people/me is such an alias.  The cache is shared by every copy of the client made from the returned one, including
+// those given another token by SetToken.  A ttl or maxEntries of 0 or less disables caching, which is the default.
+// Like SetMaxPerPage, this returns a modified *copy* of the client.
+func (c *client) SetGETCache(ttl time.Duration, maxEntries int) Client {
+	cp := *c
+	cp.cache = nil
+	if ttl > 0 && maxEntries > 0 {
+		cp.cache = newGetCache(ttl, maxEntries)
+	}
+	return &cp
+}
+
+// Version is the version of this package, as reported in the default User-Agent.
+const Version = "0.1.0"
+
+// DefaultUserAgent is the User-Agent header that clients send unless it's changed with SetUserAgent.
+const DefaultUserAgent = "go-spark/" + Version
+
+// Sets the User-Agent header sent with every request, so the client's traffic can be identified.  An empty ua restores
+// DefaultUserAgent.  Like SetMaxPerPage, this returns a modified *copy* of the client.
+func (c *client) SetUserAgent(ua string) Client {
+	cp := *c
+	cp.userAgent = ua
+	if ua == "" {
+		cp.userAgent = DefaultUserAgent
+	}
+	return &cp
+}
+
+// Sets the API version that the client asks for, for beta features that Spark only enables for a version.  It's sent
+// with every request as the Accept header, as application/json;version=<v>.  An empty v stops sending the header,
+// which is the default.  Like SetMaxPerPage, this returns a modified *copy* of the client.
+func (c *client) SetAPIVersion(v string) Client {
+	cp := *c
+	cp.apiVersion = strings.TrimSpace(v)
+	return &cp
+}
+
+// Replaces the token that the client authenticates with, ex. after refreshing an OAuth access token, trimming it like
+// New does.  The client doesn't refresh tokens itself, and has no notion of a token source: whatever does the refreshing
+// should call this with each new token, and switch to using the returned client.  Like SetMaxPerPage, this returns a
+// modified *copy* of the client, so requests already in flight on the original keep its old token.  The copy shares
+// the original's cached state, like the identity that IsSelfAuthored looks up, so the new token must authenticate as
+// the same person or bot; for a different one, use New instead.
+func (c *client) SetToken(token string) Client {
+	cp := *c
+	cp.token = strings.TrimSpace(token)
+	return &cp
+}
+
+// Declares whether the client's token is an organization admin token.  Some queries are only allowed for admins, ex.
+// ListPeople without any filters, and the client rejects those up front with a clear error unless this is set, rather
+// than letting the server reject them with an opaque one.  Off by default.  Like SetMaxPerPage, this returns a modified
+// *copy* of the client.
+func (c *client) SetAdminToken(admin bool) Client {
+	cp := *c
+	cp.admin = admin
+	return &cp
+}
+
+// Sets a function that is called with the headers of every successful response the client receives, along with the
+// resource the request was for (ex. "rooms" or "messages").  Paged queries call it once per page.  This is intended for
+// things like logging tracking IDs or rate limit headers, and does not change what the calling method returns.  A nil
+// fn disables the observer.  Like SetMaxPerPage, this returns a modified *copy* of the client.
+func (c *client) SetResponseObserver(fn func(resource string, h http.Header)) Client {
+	cp := *c
+	cp.observer = fn
+	return &cp
+}
+
+// Sets a function that is called after every request the client sends, with the resource it was for (ex. "rooms"), its
+// method, the HTTP status of the response, and how long it took to receive the response, including its body.  It's
+// called for failed responses too, and with a status of 0 if no response was received at all (ex. the connection was
+// refused).  Retried requests and paged queries call it once per attempt and page.  This is intended for feeding a
+// metrics system, like Prometheus counters, without the package depending on one.  A nil fn disables the observer.
+// Like SetMaxPerPage, this returns a modified *copy* of the client.
+func (c *client) SetMetricsObserver(fn func(resource, method string, status int, latency time.Duration)) Client {
+	cp := *c
+	cp.metrics = fn
+	return &cp
+}
+
+// Sets the *http.Client that the client sends its requests with, for callers that need control over timeouts,
+// proxies, or the transport.  A nil cli restores the default.  Like SetMaxPerPage, this returns a modified *copy* of
+// the client.
+func (c *client) SetHTTPClient(cli *http.Client) Client {
+	cp := *c
+	cp.httpCli = nil
+	if cli != nil {
+		cp.httpCli = cli
+	}
+	return &cp
+}
+
+// Sets the TLS configuration that the client connects to Spark with, ex. to trust a private CA (through RootCAs) when
+// running behind a TLS-terminating proxy.  This installs a new *http.Client, with a copy of the default transport that
+// uses cfg, so it replaces any client set by SetHTTPClient, and vice versa: whichever is called last wins.  A nil cfg
+// restores the default client.  Like SetMaxPerPage, this returns a modified *copy* of the client.
+func (c *client) SetTLSConfig(cfg *tls.Config) Client {
+	if cfg == nil {
+		return c.SetHTTPClient(nil)
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = cfg
+	return c.SetHTTPClient(&http.Client{Transport: t})
+}
+
+// Sets the size of the client's pool of idle connections: at most maxIdle in total, and at most maxIdlePerHost to any
+// one host.  The default transport only keeps 2 idle connections per host, so a client making many concurrent requests
+// to Spark keeps opening new ones; raising maxIdlePerHost lets it reuse them instead.  As with http.Transport, a
+// maxIdle of 0 means no limit, and a maxIdlePerHost of 0 means http.DefaultMaxIdleConnsPerHost.  This installs a new
+// *http.Client, with a fresh copy of the default transport, so it replaces any client set by SetHTTPClient or
+// SetTLSConfig (and whichever is called last wins).  Like SetMaxPerPage, this returns a modified *copy* of the client.
+func (c *client) SetConnectionPool(maxIdle, maxIdlePerHost int) Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = maxIdle
+	t.MaxIdleConnsPerHost = maxIdlePerHost
+	return c.SetHTTPClient(&http.Client{Transport: t})
+}
+
+// Enables or disables following redirects.  By default, like any *http.Client, the client follows the redirects that
+// it's sent, which can hide authentication failures behind proxies that redirect to a login page (the login page comes
+// back with a 200, and fails to decode).  When disabled, a redirect is returned as is instead, and fails the request
+// with an *APIError for its status, ex. 302.  This applies on top of any client set by SetHTTPClient, SetTLSConfig, or
+// SetConnectionPool, without modifying it.  Like SetMaxPerPage, this returns a modified *copy* of the client.
+func (c *client) SetFollowRedirects(follow bool) Client {
+	cp := *c
+	cp.noRedirects = !follow
+	return &cp
+}
+
+// Enables or disables plain text fallbacks for markdown messages.  When enabled, CreateMessage fills in the Text of a
+// message that only has Markdown set with a plain text version of the markdown, for clients that can't render it.  The
+// caller's NewMessage is not modified.  Off by default.  Like SetMaxPerPage, this returns a modified *copy* of the
+// client.
+func (c *client) SetMarkdownFallback(fallback bool) Client {
+	cp := *c
+	cp.markdownFallback = fallback
+	return &cp
+}
+
+// Enables or disables checking messages' classifications before they're sent.  When enabled, CreateMessage looks up the
+// room a message is posted to, and if the room has a classification, refuses to send a message without a
+// ClassificationID.  This costs an extra request per message, so it's off by default.  Like SetMaxPerPage, this
+// returns a modified *copy* of the client.
+func (c *client) SetRequireClassification(require bool) Client {
+	cp := *c
+	cp.requireClassification = require
+	return &cp
+}
+
+// Sets a writer that every request the client sends is logged to, for debugging.  Each request is logged with its
+// method, URL, and headers, followed by the response's status and size.  The Authorization header is always redacted,
+// so the client's token never appears in the output.  Bodies are not logged.  A nil w disables logging.  Writes to w
+// are not synchronized, so if the client is used concurrently, w must be safe for concurrent use.  Like SetMaxPerPage,
+// this returns a modified *copy* of the client.
+func (c *client) SetDebugWriter(w io.Writer) Client {
+	cp := *c
+	cp.debug = w
+	return &cp
+}
+
+// Closes any idle keep-alive connections held by the client's underlying http.Client, for long-lived services that
+// create and discard clients.  Connections that are in use are left alone.  Only an http.Client given to the client
+// (by SetHTTPClient, or installed by a setter like SetTLSConfig) is closed: clients using the package's default one
+// share http.DefaultTransport with the rest of the process, so closing it would drop everyone's idle connections, and
+// Close does nothing for them.  A client given to SetHTTPClient is closed even if the caller shares it elsewhere.  The
+// client remains usable afterwards, and it's safe to call Close multiple times.  It currently always returns nil.
+func (c *client) Close() error {
+	if c.httpCli == nil {
+		return nil
+	}
+	if cli, ok := c.http().(interface{ CloseIdleConnections() }); ok {
+		cli.CloseIdleConnections()
+	}
+	return nil
+}
+
+// Returns the http client that requests should be sent with.
+func (c *client) http() httpClient {
+	cli := httpCli
+	if c.httpCli != nil {
+		cli = c.httpCli
+	}
+	if hc, ok := cli.(*http.Client); ok && c.noRedirects {
+		cp := *hc // shares the transport, and so its connections
+		cp.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+		return &cp
 	}
+	return cli
 }
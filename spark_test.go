@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	"io"
 
@@ -71,8 +72,62 @@ func closer(r io.Reader) *readCloser {
 	return &readCloser{Reader: r}
 }
 
+// Adapts a function into an http.RoundTripper, for building *http.Clients that don't touch the network.
+type roundTripper func(req *http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 type failReader struct{}
 
 func (*failReader) Read([]byte) (int, error) {
 	return 0, mockErr
 }
+
+// A clock that never actually sleeps.  Instead, calls to After advance the clock's current time and are recorded, so
+// tests can check how long the code under test *would* have waited.
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.sleeps = append(f.sleeps, d)
+	f.now = f.now.Add(d)
+
+	ch := make(chan time.Time, 1)
+	ch <- f.now
+	return ch
+}
+
+var _ = Describe("New", func() {
+	It("trims whitespace from the token", func() {
+		Expect(New(" token \n").(*client).token).To(Equal("token"))
+		Expect(New(" token ").SetMaxPerPage(10).(*client).token).To(Equal("token"))
+	})
+
+	It("doesn't fail for an empty token", func() {
+		Expect(New("")).ToNot(BeNil())
+	})
+
+	It("rejects an empty token when validating", func() {
+		c, err := NewValidated("")
+		Expect(err).To(MatchError("no token specified"))
+		Expect(c).To(BeNil())
+
+		c, err = NewValidated(" \t\n")
+		Expect(err).To(MatchError("no token specified"))
+		Expect(c).To(BeNil())
+	})
+
+	It("trims a padded token when validating", func() {
+		c, err := NewValidated(" token ")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.(*client).token).To(Equal("token"))
+	})
+})
@@ -0,0 +1,71 @@
+package spark
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// ServiceStatusURL is Webex's public status page API, which reports whether the service is having problems.
+const ServiceStatusURL = "https://status.webex.com/api/v2/status.json"
+
+// StatusOperational is the Status.Indicator of a service with no known problems.
+const StatusOperational = "none"
+
+// Status is the overall status of the Webex service, from its status page.
+type Status struct {
+	// One of "none", "minor", "major", or "critical"
+	Indicator string `json:"indicator"`
+
+	// A summary for humans, ex. "All Systems Operational"
+	Description string `json:"description"`
+
+	// When the status page was last updated
+	Updated Time `json:"-"`
+}
+
+// Operational reports whether the status page lists no known problems.
+func (s *Status) Operational() bool {
+	return s != nil && s.Indicator == StatusOperational
+}
+
+type statusPage struct {
+	Page struct {
+		Updated Time `json:"updated_at"`
+	} `json:"page"`
+	Status Status `json:"status"`
+}
+
+// ServiceStatus fetches the status of the Webex service from its public status page, for telling failures caused by
+// the caller from an outage.  Like PingWebhookTarget, the request is sent with the client's http.Client, but it doesn't
+// contact Spark and doesn't include the client's token.
+func (c *client) ServiceStatus() (*Status, error) {
+	req, err := http.NewRequest("GET", ServiceStatusURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	res, err := c.http().Do(req)
+	if err != nil {
+		return nil, &TransportError{Err: err}
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, &BodyReadError{StatusCode: res.StatusCode, Err: err}
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, newAPIError(req, res, b)
+	}
+
+	// Not c.unmarshal: the status page isn't Spark's to model, so strict decoding doesn't apply
+	var page statusPage
+	if err := c.jsonCodec().Unmarshal(b, &page); err != nil {
+		return nil, fmt.Errorf("decoding service status: %v", err)
+	}
+	status := page.Status
+	status.Updated = page.Page.Updated
+	return &status, nil
+}
@@ -0,0 +1,73 @@
+package spark
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ServiceStatus", func() {
+	var c Client
+	var mockCli *mockHTTPClient
+
+	BeforeEach(func() {
+		c = New("mock")
+		mockCli = new(mockHTTPClient)
+		httpCli = mockCli
+	})
+
+	It("parses the status page, without sending the client's token", func() {
+		mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+			Expect(req.URL.String()).To(Equal(ServiceStatusURL))
+			Expect(req.Method).To(Equal("GET"))
+			Expect(req.Header).ToNot(HaveKey("Authorization"))
+			Expect(req.Header.Get("User-Agent")).To(Equal(DefaultUserAgent))
+
+			body := `{
+				"page": {"id": "abc", "name": "Webex", "url": "https://status.webex.com", "updated_at": "2020-01-02T03:04:05.678Z"},
+				"status": {"indicator": "minor", "description": "Partially Degraded Service"}
+			}`
+			return &http.Response{Body: closer(bytes.NewBufferString(body)), StatusCode: http.StatusOK}, nil
+		}
+
+		status, err := c.SetStrictDecoding(true).ServiceStatus()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(status.Indicator).To(Equal("minor"))
+		Expect(status.Description).To(Equal("Partially Degraded Service"))
+		Expect(status.Updated.Equal(time.Date(2020, 1, 2, 3, 4, 5, 678000000, time.UTC))).To(BeTrue())
+		Expect(status.Operational()).To(BeFalse())
+	})
+
+	It("reports an operational service", func() {
+		Expect((&Status{Indicator: "none"}).Operational()).To(BeTrue())
+		Expect((*Status)(nil).Operational()).To(BeFalse())
+	})
+
+	It("fails on unexpected statuses and bodies", func() {
+		mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{Body: closer(bytes.NewBufferString("down")), StatusCode: http.StatusBadGateway}, nil
+		}
+		status, err := c.ServiceStatus()
+		Expect(err).To(MatchError(&APIError{StatusCode: http.StatusBadGateway, Body: []byte("down"), Method: "GET", Path: "/api/v2/status.json"}))
+		Expect(status).To(BeNil())
+
+		mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{Body: closer(bytes.NewBufferString("<html>")), StatusCode: http.StatusOK}, nil
+		}
+		status, err = c.ServiceStatus()
+		Expect(err).To(MatchError(HavePrefix("decoding service status:")))
+		Expect(status).To(BeNil())
+	})
+
+	It("passes through errors encountered during the request", func() {
+		mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return nil, mockErr
+		}
+		status, err := c.ServiceStatus()
+		Expect(err).To(MatchError(mockErr))
+		Expect(status).To(BeNil())
+	})
+})
@@ -0,0 +1,45 @@
+package spark
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// Time is a time.Time that decodes leniently from JSON, for the timestamps of the resources Spark returns (ex.
+// Message.Created).  Besides RFC 3339 with or without fractional seconds, which time.Time already accepts, it takes
+// offsets without a colon (ex. +0000), a space instead of the T, and a missing offset, which is taken to be UTC.  A
+// timestamp in any other format decodes as the zero time instead of failing the whole resource; the raw JSON can be
+// fetched with the GetXRaw methods if it's needed.  It encodes like time.Time does.
+type Time struct {
+	time.Time
+}
+
+// The layouts that Time accepts, most common first.  Fractional seconds are optional in all of them.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999",
+}
+
+func (t *Time) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		return nil // like time.Time, null leaves the time alone
+	}
+
+	t.Time = time.Time{}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil // not a string, so not a timestamp we know how to read
+	}
+	for _, layout := range timeLayouts {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package spark
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Time", func() {
+	It("decodes the timestamp formats Spark has been seen to send", func() {
+		want := time.Date(2018, 6, 1, 12, 30, 45, 0, time.UTC)
+		for _, tc := range []struct {
+			json string
+			want time.Time
+		}{
+			{`"2018-06-01T12:30:45.000Z"`, want},
+			{`"2018-06-01T12:30:45Z"`, want},
+			{`"2018-06-01T12:30:45.123Z"`, want.Add(123 * time.Millisecond)},
+			{`"2018-06-01T12:30:45+00:00"`, want},
+			{`"2018-06-01T12:30:45+0000"`, want},
+			{`"2018-06-01T14:30:45+02:00"`, want},
+			{`"2018-06-01T12:30:45"`, want},
+			{`"2018-06-01 12:30:45Z"`, want},
+			{`"2018-06-01 12:30:45.000+0000"`, want},
+			{`"2018-06-01 12:30:45"`, want},
+		} {
+			tc := tc
+			var m Message
+			Expect(json.Unmarshal([]byte(`{"id":"1","created":`+tc.json+`}`), &m)).To(Succeed(), tc.json)
+			Expect(m.Created.Equal(tc.want)).To(BeTrue(), "%s decoded as %v", tc.json, m.Created)
+		}
+	})
+
+	It("falls back to the zero time instead of failing the resource", func() {
+		for _, ts := range []string{`"yesterday"`, `""`, `12345`, `{}`} {
+			var r Room
+			Expect(json.Unmarshal([]byte(`{"id":"1","title":"room","lastActivity":`+ts+`}`), &r)).To(Succeed(), ts)
+			Expect(r.ID).To(Equal("1"))
+			Expect(r.Title).To(Equal("room"))
+			Expect(r.LastActivity.IsZero()).To(BeTrue(), ts)
+		}
+	})
+
+	It("leaves the time alone for null", func() {
+		t := Time{time.Now()}
+		Expect(json.Unmarshal([]byte(`null`), &t)).To(Succeed())
+		Expect(t.IsZero()).To(BeFalse())
+	})
+
+	It("encodes like time.Time", func() {
+		t := time.Date(2018, 6, 1, 12, 30, 45, 0, time.UTC)
+		Expect(json.Marshal(Time{t})).To(MatchJSON(`"2018-06-01T12:30:45Z"`))
+	})
+})
@@ -0,0 +1,97 @@
+package spark
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Capability is something that a token's identity is thought to be allowed to do.  See TokenInfo.
+type Capability string
+
+const (
+	// Read people and other resources across the whole org, ex. with ListOrgPeople
+	CapabilityReadOrg Capability = "read-org"
+
+	// Create, update, and delete people, ex. with CreatePerson
+	CapabilityManagePeople Capability = "manage-people"
+
+	// Change the org's settings and everything in it
+	CapabilityManageOrg Capability = "manage-org"
+)
+
+// The capabilities granted by each admin role Spark has, by the name that its role ID decodes to.
+var roleCapabilities = map[string][]Capability{
+	"id_full_admin":     {CapabilityReadOrg, CapabilityManagePeople, CapabilityManageOrg},
+	"id_readonly_admin": {CapabilityReadOrg},
+	"id_user_admin":     {CapabilityReadOrg, CapabilityManagePeople},
+}
+
+// TokenInfo describes who a client's token belongs to, and what they're likely allowed to do.
+type TokenInfo struct {
+	// The authenticated person, as returned by GetMyself
+	Person *Person
+
+	// The person's roles, by name (ex. "id_full_admin") where their IDs could be decoded, otherwise by ID
+	Roles []string
+
+	// What the person's roles allow, sorted
+	Capabilities []Capability
+}
+
+// Has reports whether the token's identity is thought to have a capability.
+func (t *TokenInfo) Has(capability Capability) bool {
+	if t == nil {
+		return false
+	}
+	for _, c := range t.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOrgAdmin reports whether the token's identity is thought to be a full administrator of their org.
+func (t *TokenInfo) IsOrgAdmin() bool {
+	return t.Has(CapabilityManageOrg)
+}
+
+// TokenInfo fetches the authenticated person and works out what their admin roles let them do, so callers can check
+// a token before starting admin operations, rather than failing with a 403 partway through.  Spark has no endpoint for
+// introspecting a token, so this is a heuristic: it's based on the roles listed on the person, which doesn't account
+// for the token's scopes (ex. an integration that wasn't granted spark-admin scopes), and roles it doesn't recognize
+// grant no capabilities.  The server is always the final word.  Like Ping, this always goes to the server.
+func (c *client) TokenInfo() (*TokenInfo, error) {
+	resp, err := c.getRequestContext(context.Background(), fmt.Sprintf("%s/me", PeopleURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var me Person
+	if err := c.unmarshal(resp, &me); err != nil {
+		return nil, err
+	}
+	return tokenInfo(&me), nil
+}
+
+// Builds the TokenInfo for a person, from the roles they hold.
+func tokenInfo(p *Person) *TokenInfo {
+	info := &TokenInfo{Person: p, Roles: make([]string, 0, len(p.Roles))}
+
+	granted := make(map[Capability]bool)
+	for _, id := range p.Roles {
+		role := resourceUUID(id)
+		info.Roles = append(info.Roles, role)
+		for _, capability := range roleCapabilities[role] {
+			granted[capability] = true
+		}
+	}
+
+	info.Capabilities = make([]Capability, 0, len(granted))
+	for capability := range granted {
+		info.Capabilities = append(info.Capabilities, capability)
+	}
+	sort.Slice(info.Capabilities, func(i, j int) bool { return info.Capabilities[i] < info.Capabilities[j] })
+	return info
+}
@@ -0,0 +1,91 @@
+package spark
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TokenInfo", func() {
+	var c Client
+	var mockCli *mockHTTPClient
+
+	role := func(name string) string {
+		return base64.StdEncoding.EncodeToString([]byte("ciscospark://us/ROLE/" + name))
+	}
+
+	BeforeEach(func() {
+		c = New("mock")
+		mockCli = new(mockHTTPClient)
+		httpCli = mockCli
+	})
+
+	respond := func(roles ...string) {
+		mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+			Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/me", PeopleURL)))
+			Expect(req.Method).To(Equal("GET"))
+
+			p, err := json.Marshal(&Person{ID: "1", Roles: roles})
+			Expect(err).ToNot(HaveOccurred())
+			return &http.Response{Body: closer(bytes.NewBuffer(p)), StatusCode: http.StatusOK}, nil
+		}
+	}
+
+	It("infers capabilities from the person's roles", func() {
+		respond(role("id_user_admin"), role("id_readonly_admin"), "unknown")
+
+		info, err := c.TokenInfo()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Person.ID).To(Equal("1"))
+		Expect(info.Roles).To(Equal([]string{"id_user_admin", "id_readonly_admin", "unknown"}))
+		Expect(info.Capabilities).To(Equal([]Capability{CapabilityManagePeople, CapabilityReadOrg}))
+		Expect(info.Has(CapabilityManagePeople)).To(BeTrue())
+		Expect(info.IsOrgAdmin()).To(BeFalse())
+	})
+
+	It("recognizes full administrators", func() {
+		respond(role("id_full_admin"))
+
+		info, err := c.TokenInfo()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.IsOrgAdmin()).To(BeTrue())
+		Expect(info.Has(CapabilityReadOrg)).To(BeTrue())
+	})
+
+	It("grants nothing to people without roles", func() {
+		respond()
+
+		info, err := c.TokenInfo()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Roles).To(BeEmpty())
+		Expect(info.Capabilities).To(BeEmpty())
+		Expect(info.Has(CapabilityReadOrg)).To(BeFalse())
+		Expect((*TokenInfo)(nil).IsOrgAdmin()).To(BeFalse())
+	})
+
+	It("passes through errors encountered during the request", func() {
+		mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return nil, mockErr
+		}
+		info, err := c.TokenInfo()
+		Expect(err).To(MatchError(mockErr))
+		Expect(info).To(BeNil())
+	})
+
+	It("is supported by the fake", func() {
+		f := NewFakeClient(&Person{ID: "me", Roles: []string{role("id_full_admin")}})
+		info, err := f.TokenInfo()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Person.ID).To(Equal("me"))
+		Expect(info.IsOrgAdmin()).To(BeTrue())
+
+		f.SetError("TokenInfo", mockErr)
+		_, err = f.TokenInfo()
+		Expect(err).To(MatchError(mockErr))
+	})
+})
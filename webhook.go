@@ -2,11 +2,20 @@ package spark
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
 )
 
-const WebhooksURL = "https://api.ciscospark.com/v1/webhooks"
+const WebhooksURL = BaseURL + "/webhooks"
 
 type Webhook struct {
 	ID        string                 `json:"id"`
@@ -20,42 +29,212 @@ type Webhook struct {
 	CreatedBy string                 `json:"createdBy,omitempty"`
 	AppID     string                 `json:"appId,omitempty"`
 	OwnedBy   string                 `json:"ownedBy,omitempty"`
-	Status    string                 `json:"active,omitempty"`
+	Status    WebhookStatus          `json:"active,omitempty"`
 	ActorID   string                 `json:"actorId,omitempty"`
 	Data      map[string]interface{} `json:"data,omitempty"` // TODO: what is this?  Is it needed? Not in the docs
 }
 
+// WebhookStatus is whether a webhook is firing.  Spark reports it as a boolean in some payloads and as a string in
+// others, so it decodes from either form, with true and false becoming WebhookActive and WebhookInactive.
+type WebhookStatus string
+
+const (
+	WebhookActive   WebhookStatus = "active"
+	WebhookInactive WebhookStatus = "inactive"
+)
+
+func (s *WebhookStatus) UnmarshalJSON(b []byte) error {
+	switch string(bytes.TrimSpace(b)) {
+	case "null":
+		return nil
+	case "true":
+		*s = WebhookActive
+		return nil
+	case "false":
+		*s = WebhookInactive
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return fmt.Errorf("webhook status must be a string or boolean, not %s", b)
+	}
+	*s = WebhookStatus(str)
+	return nil
+}
+
 type WebhookList struct {
 	Items []*Webhook
 }
 
+// WebhookEvent is the payload that Spark POSTs to a webhook's target URL when the webhook fires.  Data contains the
+// resource that triggered the event (ex. a Message for the messages resource), but note that for security reasons
+// Spark omits sensitive content like message text from it, so it will usually need to be fetched separately.
+type WebhookEvent struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	TargetURL string          `json:"targetUrl"`
+	Resource  string          `json:"resource"`
+	Event     string          `json:"event"`
+	Filter    string          `json:"filter,omitempty"`
+	OrgID     string          `json:"orgId,omitempty"`
+	CreatedBy string          `json:"createdBy,omitempty"`
+	AppID     string          `json:"appId,omitempty"`
+	OwnedBy   string          `json:"ownedBy,omitempty"`
+	Status    WebhookStatus   `json:"status,omitempty"`
+	ActorID   string          `json:"actorId,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// IsFromSelf reports whether the event was triggered by the provided person, typically the result of GetMyself.  Bots
+// should check this before responding to a messages webhook, to avoid endlessly replying to themselves.
+func (e *WebhookEvent) IsFromSelf(me *Person) bool {
+	if e == nil || me == nil || me.ID == "" {
+		return false
+	}
+	return e.ActorID == me.ID
+}
+
+// ParseWebhookEvent decodes the body of a request that Spark sent to a webhook's target URL.  It doesn't check the
+// request's signature; see VerifyWebhookSignature for that.
+func ParseWebhookEvent(body []byte) (*WebhookEvent, error) {
+	var e WebhookEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("decoding webhook event: %v", err)
+	}
+	return &e, nil
+}
+
+// DecodeData unmarshals the event's data into v, which should be the type of the event's resource, ex. an
+// AttachmentAction for the attachmentActions resource.
+func (e *WebhookEvent) DecodeData(v interface{}) error {
+	if len(e.Data) == 0 {
+		return fmt.Errorf("webhook event has no data")
+	}
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return fmt.Errorf("decoding webhook event data: %v", err)
+	}
+	return nil
+}
+
 type NewWebhook struct {
-	Name      string `json:"name"`             // required
-	TargetURL string `json:"targetUrl"`        // required
-	Resource  string `json:"resource"`         // required
-	Event     string `json:"event"`            // required
-	Filter    string `json:"filter,omitempty"` // optional
-	Secret    string `json:"secret,omitempty"` // optional
+	Name      string `json:"name"`              // required
+	TargetURL string `json:"targetUrl"`         // required
+	Resource  string `json:"resource"`          // required
+	Event     string `json:"event"`             // required
+	Filter    string `json:"filter,omitempty"`  // optional
+	Secret    string `json:"secret,omitempty"`  // optional
+	OwnedBy   string `json:"ownedBy,omitempty"` // optional, "org" for an org-wide webhook (admin only)
 }
 
-// https://developer.webex.com/endpoint-webhooks-webhookId-get.html
-func (c *client) GetWebhook(webhookID string) (*Webhook, error) {
-	if webhookID == "" {
-		return nil, fmt.Errorf("no webhook ID specified")
+// WebhookFilter builds the Filter of a webhook for a resource, so that it only fires for matching events, ex.
+//
+//	filter, err := spark.NewWebhookFilter("messages").RoomID(roomID).ParentID(threadID).Build()
+//
+// Each filter is added as a URL-encoded key=value pair, the form Spark expects.  Filters that don't apply to the
+// resource are reported by Build, rather than being rejected by the server when the webhook is created.
+type WebhookFilter struct {
+	resource string
+	values   url.Values
+	errs     []string
+}
+
+// NewWebhookFilter starts an empty filter for webhooks on the provided resource, ex. "messages".
+func NewWebhookFilter(resource string) *WebhookFilter {
+	return &WebhookFilter{resource: resource, values: make(url.Values)}
+}
+
+// Adds a filter that's only legal for the listed resources.
+func (f *WebhookFilter) add(key, value string, resources ...string) *WebhookFilter {
+	if len(resources) > 0 && !containsString(resources, f.resource) {
+		f.errs = append(f.errs, fmt.Sprintf("%s can't filter %s webhooks", key, f.resource))
+		return f
+	}
+	f.values.Set(key, value)
+	return f
+}
+
+// RoomID limits the webhook to events in the room with the provided ID.
+func (f *WebhookFilter) RoomID(id string) *WebhookFilter {
+	return f.add("roomId", id, "messages", "memberships", "attachmentActions")
+}
+
+// PersonID limits the webhook to events by (or for memberships, of) the person with the provided ID.
+func (f *WebhookFilter) PersonID(id string) *WebhookFilter {
+	return f.add("personId", id, "messages", "memberships", "attachmentActions")
+}
+
+// PersonEmail limits the webhook to events by (or for memberships, of) the person with the provided email.
+func (f *WebhookFilter) PersonEmail(email string) *WebhookFilter {
+	return f.add("personEmail", email, "messages", "memberships")
+}
+
+// MentionedPeople limits a messages webhook to messages that mention the people with the provided IDs, where "me" is
+// the client's own identity.
+func (f *WebhookFilter) MentionedPeople(ids ...string) *WebhookFilter {
+	return f.add("mentionedPeople", strings.Join(ids, ","), "messages")
+}
+
+// ParentID limits a messages webhook to threaded replies to the message with the provided ID.
+func (f *WebhookFilter) ParentID(id string) *WebhookFilter {
+	return f.add("parentId", id, "messages")
+}
+
+// Build returns the filter, for NewWebhook.Filter or Webhook.Filter.  It fails if any of the filters don't apply to
+// the resource.
+func (f *WebhookFilter) Build() (string, error) {
+	if len(f.errs) > 0 {
+		return "", fmt.Errorf("invalid webhook filter: %s", strings.Join(f.errs, "; "))
 	}
+	return f.values.Encode(), nil
+}
 
-	resp, err := c.getRequest(fmt.Sprintf("%s/%s", WebhooksURL, webhookID), nil)
+// Reports whether s is in list.
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// The body of an UpdateWebhook request, which leaves out the fields of a Webhook that are owned by the server (its ID,
+// which goes in the path instead, and createdBy, ownedBy, appId, orgId, actorId and data).
+type webhookUpdate struct {
+	Name      string        `json:"name"`
+	TargetURL string        `json:"targetUrl"`
+	Resource  string        `json:"resource,omitempty"`
+	Event     string        `json:"event,omitempty"`
+	Filter    string        `json:"filter,omitempty"`
+	Secret    string        `json:"secret,omitempty"`
+	Status    WebhookStatus `json:"status,omitempty"`
+}
+
+// https://developer.webex.com/endpoint-webhooks-webhookId-get.html
+func (c *client) GetWebhook(webhookID string) (*Webhook, error) {
+	resp, err := c.GetWebhookRaw(webhookID)
 	if err != nil {
 		return nil, err
 	}
 
 	var webhook Webhook
-	if err := json.Unmarshal(resp, &webhook); err != nil {
+	if err := c.unmarshal(resp, &webhook); err != nil {
 		return nil, err
 	}
 	return &webhook, err
 }
 
+// GetWebhookRaw works like GetWebhook, except it returns the raw JSON of the response instead of a parsed Webhook.
+// This allows callers to read fields that the Webhook struct does not (yet) model.
+func (c *client) GetWebhookRaw(webhookID string) (json.RawMessage, error) {
+	if webhookID == "" {
+		return nil, fmt.Errorf("no webhook ID specified")
+	}
+
+	return c.getRequest(fmt.Sprintf("%s/%s", WebhooksURL, webhookID), nil)
+}
+
 // https://developer.webex.com/endpoint-webhooks-post.html
 func (c *client) CreateWebhook(w *NewWebhook) (*Webhook, error) {
 	if w == nil {
@@ -64,8 +243,8 @@ func (c *client) CreateWebhook(w *NewWebhook) (*Webhook, error) {
 	if w.Name == "" {
 		return nil, fmt.Errorf("no webhook name specified")
 	}
-	if w.TargetURL == "" {
-		return nil, fmt.Errorf("no webhook target URL specified")
+	if err := validateTargetURL(w.TargetURL); err != nil {
+		return nil, err
 	}
 	if w.Resource == "" {
 		return nil, fmt.Errorf("no webhook resource specified")
@@ -73,9 +252,12 @@ func (c *client) CreateWebhook(w *NewWebhook) (*Webhook, error) {
 	if w.Event == "" {
 		return nil, fmt.Errorf("no webhook event specified")
 	}
+	if err := validateWebhookOwner(w.OwnedBy); err != nil {
+		return nil, err
+	}
 
-	b := new(bytes.Buffer)
-	if err := json.NewEncoder(b).Encode(w); err != nil {
+	b, err := c.encode(w)
+	if err != nil {
 		return nil, err
 	}
 	resp, err := c.postRequest(WebhooksURL, b)
@@ -85,7 +267,7 @@ func (c *client) CreateWebhook(w *NewWebhook) (*Webhook, error) {
 	}
 
 	var rwh Webhook
-	err = json.Unmarshal(resp, &rwh)
+	err = c.unmarshal(resp, &rwh)
 	return &rwh, err
 }
 
@@ -100,13 +282,23 @@ func (c *client) UpdateWebhook(w *Webhook) (*Webhook, error) {
 	if w.Name == "" {
 		return nil, fmt.Errorf("no webhook name specified")
 	}
-	if w.TargetURL == "" {
-		return nil, fmt.Errorf("no webhook target URL specified")
+	if err := validateTargetURL(w.TargetURL); err != nil {
+		return nil, err
 	}
 	// weirdly, Resource and Event aren't required, despite the fact that they are required for *new* webhooks
 
-	b := new(bytes.Buffer)
-	if err := json.NewEncoder(b).Encode(w); err != nil {
+	// Only the editable fields are sent, since the server rejects updates that try to set its read-only ones
+	u := webhookUpdate{
+		Name:      w.Name,
+		TargetURL: w.TargetURL,
+		Resource:  w.Resource,
+		Event:     w.Event,
+		Filter:    w.Filter,
+		Secret:    w.Secret,
+		Status:    w.Status,
+	}
+	b, err := c.encode(&u)
+	if err != nil {
 		return nil, err
 	}
 	resp, err := c.putRequest(fmt.Sprintf("%s/%s", WebhooksURL, w.ID), b)
@@ -115,7 +307,7 @@ func (c *client) UpdateWebhook(w *Webhook) (*Webhook, error) {
 	}
 
 	var rwh Webhook
-	err = json.Unmarshal(resp, &rwh)
+	err = c.unmarshal(resp, &rwh)
 	return &rwh, err
 }
 
@@ -129,9 +321,70 @@ func (c *client) DeleteWebhook(hookID string) error {
 	return err
 }
 
+// FindWebhooksByTarget is a helper method that wraps ListWebhooks.  It lists every webhook, and returns those whose
+// target URL is exactly targetURL.  This is meant for finding stale webhooks when a callback URL changes.
+func (c *client) FindWebhooksByTarget(targetURL string) ([]*Webhook, error) {
+	if targetURL == "" {
+		return nil, fmt.Errorf("no webhook target URL specified")
+	}
+
+	webhooks, err := c.ListWebhooks(0)
+	if err != nil {
+		return nil, err
+	}
+	return webhooksByTarget(webhooks, targetURL), nil
+}
+
+// DeleteWebhooksByTarget is a helper method that deletes every webhook found by FindWebhooksByTarget, returning how
+// many were deleted.  It tries to delete all of them, even if some fail, in which case it returns a WebhookErrors
+// describing the failures.
+func (c *client) DeleteWebhooksByTarget(targetURL string) (int, error) {
+	webhooks, err := c.FindWebhooksByTarget(targetURL)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	errs := make(WebhookErrors)
+	for _, w := range webhooks {
+		if err := c.DeleteWebhook(w.ID); err != nil {
+			errs[w.ID] = err
+			continue
+		}
+		deleted++
+	}
+	if len(errs) > 0 {
+		return deleted, errs
+	}
+	return deleted, nil
+}
+
+// Returns the webhooks whose target URL is exactly targetURL, or an empty slice if there are none.
+func webhooksByTarget(webhooks []*Webhook, targetURL string) []*Webhook {
+	matched := []*Webhook{}
+	for _, w := range webhooks {
+		if w.TargetURL == targetURL {
+			matched = append(matched, w)
+		}
+	}
+	return matched
+}
+
 // https://developer.webex.com/endpoint-webhooks-get.html
+//
+// This lists the webhooks the client's identity created.  See ListOrgWebhooks for org-wide webhooks.
 func (c *client) ListWebhooks(max int) ([]*Webhook, error) {
-	resp, reqErr := c.getRequestWithPaging(WebhooksURL, nil, max)
+	return c.listWebhooks(max, nil)
+}
+
+// ListOrgWebhooks works like ListWebhooks, except that it lists the org-wide webhooks of the client's org, ie. those
+// created with an OwnedBy of "org".  This requires an admin token.
+func (c *client) ListOrgWebhooks(max int) ([]*Webhook, error) {
+	return c.listWebhooks(max, url.Values{"ownedBy": {"org"}})
+}
+
+func (c *client) listWebhooks(max int, uv url.Values) ([]*Webhook, error) {
+	resp, reqErr := c.getRequestWithPaging(WebhooksURL, uv, max)
 	if reqErr != nil && len(resp) == 0 { // if we got an error *and* results, parse them and return them
 		return nil, reqErr
 	}
@@ -139,10 +392,152 @@ func (c *client) ListWebhooks(max int) ([]*Webhook, error) {
 	var webhooks []*Webhook
 	for _, r := range resp {
 		var w WebhookList
-		if jsonErr := json.Unmarshal(r, &w); jsonErr != nil {
+		if jsonErr := c.unmarshal(r, &w); jsonErr != nil {
 			return webhooks, fmt.Errorf("%v && %v", reqErr, jsonErr)
 		}
 		webhooks = append(webhooks, w.Items...)
 	}
+	if c.dedupe {
+		webhooks = DedupeWebhooks(webhooks)
+	}
+	if webhooks == nil {
+		webhooks = []*Webhook{} // empty, not failed
+	}
 	return webhooks, reqErr
 }
+
+// ListWebhooksSingle works like ListWebhooks, except that it makes exactly one request, for exactly max webhooks,
+// regardless of the client's page size.  Along with the webhooks, it returns the pagination links the server sent.
+// Links.Next is empty if there are no more webhooks.
+func (c *client) ListWebhooksSingle(max int) ([]*Webhook, Links, error) {
+	page, links, err := c.getSinglePage(WebhooksURL, nil, max)
+	if err != nil {
+		return nil, Links{}, err
+	}
+
+	var w WebhookList
+	if err := c.unmarshal(page, &w); err != nil {
+		return nil, Links{}, err
+	}
+	if w.Items == nil {
+		w.Items = []*Webhook{} // empty, not failed
+	}
+	return w.Items, links, nil
+}
+
+// PingWebhookTarget checks that a webhook target URL is reachable, by sending it a HEAD request.  It fails if the
+// request can't be sent, or if the target responds with a 5xx status.  Any other status (including 4xx, since many
+// targets only accept POSTs) counts as reachable.  This is a local diagnostic: the request is sent with the client's
+// http.Client, so it respects its timeout, but it doesn't contact Spark and doesn't include the client's token.
+func (c *client) PingWebhookTarget(targetURL string) error {
+	if err := validateTargetURL(targetURL); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("HEAD", targetURL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.http().Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook target %q is unreachable: %w", targetURL, err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= 500 {
+		return fmt.Errorf("webhook target %q responded with HTTP Status %d", targetURL, res.StatusCode)
+	}
+	return nil
+}
+
+// A webhook is either owned by its creator (the default, when ownedBy is empty), or by the creator's whole org.
+func validateWebhookOwner(ownedBy string) error {
+	if ownedBy != "" && ownedBy != "org" {
+		return fmt.Errorf("webhook ownedBy must be empty or \"org\", not %q", ownedBy)
+	}
+	return nil
+}
+
+// Spark requires webhook target URLs to be absolute https URLs.  Checking that up front gives a much clearer error than
+// the one the server sends back.
+func validateTargetURL(targetURL string) error {
+	if targetURL == "" {
+		return fmt.Errorf("no webhook target URL specified")
+	}
+
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook target URL %q: %v", targetURL, err)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("webhook target URL %q must use https", targetURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("webhook target URL %q has no host", targetURL)
+	}
+	return nil
+}
+
+// GenerateWebhookSecret returns a cryptographically random, URL-safe secret suitable for NewWebhook.Secret.  Spark uses
+// the secret to sign the events it sends to the webhook, so the same value must be stored and passed to
+// VerifyWebhookSignature to check them.
+func GenerateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// VerifyWebhookSignature reports whether signature, the value of the X-Spark-Signature header of a webhook event, is
+// valid for the event's raw body and the webhook's secret.  Spark signs events with an HMAC-SHA1 of the body, keyed by
+// the secret.
+func VerifyWebhookSignature(body []byte, signature, secret string) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+// The largest webhook event body that a webhook receiver will read.  Events only carry a summary of their resource, so
+// real ones are far smaller.
+const maxWebhookEventSize = 1 << 20
+
+// NewWebhookReceiver returns an http.Handler for a webhook's target URL, ex. http.Handle("/webhook",
+// spark.NewWebhookReceiver(secret, handler)).  For each event Spark POSTs to it, the receiver checks the
+// X-Spark-Signature header against secret, parses the event, and passes it to handler, then responds with a 200.
+// Requests that aren't signed correctly or don't contain an event get a 400, and handler isn't called.  An empty
+// secret skips the signature check, for webhooks that were created without a secret.
+//
+// handler is called synchronously, and Spark waits for the response, so long-running work should be moved out of it.
+func NewWebhookReceiver(secret string, handler func(e *WebhookEvent)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "webhook events must be POSTed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookEventSize))
+		if err != nil {
+			http.Error(w, "reading webhook event: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if secret != "" && !VerifyWebhookSignature(body, r.Header.Get("X-Spark-Signature"), secret) {
+			http.Error(w, "invalid webhook signature", http.StatusBadRequest)
+			return
+		}
+		e, err := ParseWebhookEvent(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		handler(e)
+		w.WriteHeader(http.StatusOK)
+	})
+}
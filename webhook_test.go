@@ -3,8 +3,12 @@ package spark
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
 
 	"strings"
 
@@ -28,21 +32,21 @@ var _ = Describe("Webhook (Mock)", func() {
 				{
 					ID:        "1",
 					Name:      "webhook 1",
-					TargetURL: "url 1",
+					TargetURL: "https://example.com/hook1",
 					Resource:  "resource 1",
 					Event:     "event 1",
 				},
 				{
 					ID:        "2",
 					Name:      "webhook 2",
-					TargetURL: "url 2",
+					TargetURL: "https://example.com/hook2",
 					Resource:  "resource 2",
 					Event:     "event 2",
 				},
 				{
 					ID:        "3",
 					Name:      "webhook 3",
-					TargetURL: "url 3",
+					TargetURL: "https://example.com/hook3",
 					Resource:  "resource 3",
 					Event:     "event 3",
 				},
@@ -71,6 +75,49 @@ var _ = Describe("Webhook (Mock)", func() {
 			Expect(c.GetWebhook(webhookID)).To(Equal(webhooks.Items[0]))
 		})
 
+		It("treats an empty 200 body as a successful empty result", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(&bytes.Buffer{}), // empty body
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.GetWebhook("1")).To(Equal(&Webhook{}))
+		})
+
+		It("decodes a boolean active flag", func() {
+			for _, tc := range []struct {
+				active string
+				want   WebhookStatus
+			}{
+				{`true`, WebhookActive},
+				{`false`, WebhookInactive},
+				{`"inactive"`, WebhookInactive},
+				{`null`, ""},
+			} {
+				tc := tc
+				mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+					body := fmt.Sprintf(`{"id":"1","name":"webhook 1","targetUrl":"https://example.com/hook1","resource":"messages","event":"created","active":%s}`, tc.active)
+					return &http.Response{Body: closer(bytes.NewBufferString(body)), StatusCode: http.StatusOK}, nil
+				}
+
+				w, err := c.GetWebhook("1")
+				Expect(err).ShouldNot(HaveOccurred(), tc.active)
+				Expect(w.Status).To(Equal(tc.want), tc.active)
+			}
+		})
+
+		It("fails on a status that is neither a string nor a boolean", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1","active":1}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			_, err := c.GetWebhook("1")
+			Expect(err).To(MatchError(ContainSubstring("webhook status must be a string or boolean, not 1")))
+		})
+
 		It("fails if no webhook ID is specified", func() {
 			p, err := c.GetWebhook("")
 			Expect(err).To(MatchError("no webhook ID specified"))
@@ -87,6 +134,45 @@ var _ = Describe("Webhook (Mock)", func() {
 		})
 	})
 
+	Describe("GetWebhookRaw", func() {
+		It("returns fields the Webhook struct doesn't model", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/%s", WebhooksURL, "1")))
+				Expect(req.Method).To(Equal("GET"))
+				Expect(req.Header.Get("Authorization")).To(Equal("Bearer mock"))
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"id":"1","unmodeledField":"hello"}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			raw, err := c.GetWebhookRaw("1")
+			Expect(err).ToNot(HaveOccurred())
+
+			var fields map[string]interface{}
+			Expect(json.Unmarshal(raw, &fields)).To(Succeed())
+			Expect(fields["id"]).To(Equal("1"))
+			Expect(fields["unmodeledField"]).To(Equal("hello"))
+		})
+
+		It("fails if no webhook ID is specified", func() {
+			raw, err := c.GetWebhookRaw("")
+			Expect(err).To(MatchError("no webhook ID specified"))
+			Expect(raw).To(BeNil())
+		})
+
+		It("passes through errors encountered during the request", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			raw, err := c.GetWebhookRaw("1")
+			Expect(err).To(MatchError(mockErr))
+			Expect(raw).To(BeNil())
+		})
+	})
+
 	Describe("ListWebhooks", func() {
 		It("gets a list of webhooks", func() {
 			max := len(webhooks.Items)
@@ -236,6 +322,35 @@ var _ = Describe("Webhook (Mock)", func() {
 			Expect(calls).To(BeEquivalentTo(10))
 		})
 
+		It("returns an empty, non-nil slice when there are no results", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"items":[]}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			p, err := c.ListWebhooks(0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p).ToNot(BeNil())
+			Expect(p).To(BeEmpty())
+		})
+
+		It("fails with a nil slice if the results can't be decoded", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString("not json")),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			p, err := c.ListWebhooks(0)
+			Expect(err).To(HaveOccurred())
+			Expect(p).To(BeNil())
+		})
+
 		It("passes through errors encountered during the request", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
 				return nil, mockErr
@@ -246,10 +361,67 @@ var _ = Describe("Webhook (Mock)", func() {
 		})
 	})
 
+	Describe("ListOrgWebhooks", func() {
+		It("lists org-wide webhooks", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				uri := strings.Split(req.URL.String(), "?")[0]
+				Expect(uri).To(Equal(WebhooksURL))
+				Expect(req.URL.Query().Get("ownedBy")).To(Equal("org"))
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"items":[{"id":"1","ownedBy":"org","createdBy":"admin","appId":"app"}]}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			Expect(c.ListOrgWebhooks(0)).To(Equal([]*Webhook{{ID: "1", OwnedBy: "org", CreatedBy: "admin", AppID: "app"}}))
+		})
+
+		It("doesn't ask for org-wide webhooks from ListWebhooks", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Query()).ShouldNot(HaveKey("ownedBy"))
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"items":[]}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			Expect(c.ListWebhooks(0)).To(BeEmpty())
+		})
+	})
+
+	Describe("ListWebhooksSingle", func() {
+		It("requests exactly max webhooks in a single request, regardless of the client's page size", func() {
+			c = c.SetMaxPerPage(2)
+
+			calls := 0
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				uri := strings.Split(req.URL.String(), "?")[0]
+				Expect(uri).To(Equal(WebhooksURL))
+				Expect(req.URL.Query().Get("max")).To(Equal("7"))
+				calls++
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(webhooks)).To(Succeed())
+				r := &http.Response{
+					Body:       closer(&b),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			ws, links, err := c.ListWebhooksSingle(7)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ws).To(Equal(webhooks.Items))
+			Expect(links.Next).To(BeEmpty())
+			Expect(calls).To(Equal(1))
+		})
+	})
+
 	Describe("CreateWebhook", func() {
 		var n NewWebhook
 
 		BeforeEach(func() {
+			n = NewWebhook{}
+
 			// Wash it through the json package, because honestly that's the easiest way to copy a struct to
 			// a struct with a subset of the same fields
 			var b bytes.Buffer
@@ -286,6 +458,44 @@ var _ = Describe("Webhook (Mock)", func() {
 			Expect(c.CreateWebhook(&n)).To(Equal(webhooks.Items[1]))
 		})
 
+		It("creates an org-wide webhook", func() {
+			n.OwnedBy = "org"
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var sent map[string]interface{}
+				Expect(json.NewDecoder(req.Body).Decode(&sent)).To(Succeed())
+				Expect(sent).To(HaveKeyWithValue("ownedBy", "org"))
+
+				r := &http.Response{
+					Body:       closer(bytes.NewBufferString(`{"id":"1","ownedBy":"org"}`)),
+					StatusCode: http.StatusOK,
+				}
+				return r, nil
+			}
+
+			w, err := c.CreateWebhook(&n)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(w.OwnedBy).To(Equal("org"))
+		})
+
+		It("leaves out ownedBy by default", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var sent map[string]interface{}
+				Expect(json.NewDecoder(req.Body).Decode(&sent)).To(Succeed())
+				Expect(sent).ShouldNot(HaveKey("ownedBy"))
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1"}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			_, err := c.CreateWebhook(&n)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("fails if ownedBy isn't org", func() {
+			n.OwnedBy = "me"
+			p, err := c.CreateWebhook(&n)
+			Expect(err).To(MatchError(`webhook ownedBy must be empty or "org", not "me"`))
+			Expect(p).To(BeNil())
+		})
+
 		It("fails if a nil argument is provided", func() {
 			p, err := c.CreateWebhook(nil)
 			Expect(err).To(MatchError("nil webhook"))
@@ -308,6 +518,43 @@ var _ = Describe("Webhook (Mock)", func() {
 			Expect(p).To(BeNil())
 		})
 
+		It("fails if the webhook target URL isn't https", func() {
+			n.TargetURL = "http://example.com/hook"
+
+			p, err := c.CreateWebhook(&n)
+			Expect(err).To(MatchError(`webhook target URL "http://example.com/hook" must use https`))
+			Expect(p).To(BeNil())
+
+			n.TargetURL = "ftp://example.com/hook"
+
+			p, err = c.CreateWebhook(&n)
+			Expect(err).To(MatchError(`webhook target URL "ftp://example.com/hook" must use https`))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails if the webhook target URL has no host", func() {
+			n.TargetURL = "https:///hook"
+
+			p, err := c.CreateWebhook(&n)
+			Expect(err).To(MatchError(`webhook target URL "https:///hook" has no host`))
+			Expect(p).To(BeNil())
+
+			n.TargetURL = "example.com"
+
+			p, err = c.CreateWebhook(&n)
+			Expect(err).To(MatchError(`webhook target URL "example.com" must use https`))
+			Expect(p).To(BeNil())
+		})
+
+		It("fails if the webhook target URL is malformed", func() {
+			n.TargetURL = "https://exa mple.com/%zz"
+
+			p, err := c.CreateWebhook(&n)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix(`invalid webhook target URL "https://exa mple.com/%zz": `))
+			Expect(p).To(BeNil())
+		})
+
 		It("fails if no webhook resource is provided", func() {
 			n.Resource = ""
 
@@ -343,7 +590,12 @@ var _ = Describe("Webhook (Mock)", func() {
 
 				var p Webhook
 				Expect(json.NewDecoder(req.Body).Decode(&p)).To(Succeed())
-				Expect(&p).To(Equal(webhooks.Items[0]))
+				Expect(p).To(Equal(Webhook{
+					Name:      webhooks.Items[0].Name,
+					TargetURL: webhooks.Items[0].TargetURL,
+					Resource:  webhooks.Items[0].Resource,
+					Event:     webhooks.Items[0].Event,
+				}))
 
 				var b bytes.Buffer
 				Expect(json.NewEncoder(&b).Encode(webhooks.Items[1])).To(Succeed())
@@ -357,6 +609,46 @@ var _ = Describe("Webhook (Mock)", func() {
 			Expect(c.UpdateWebhook(webhooks.Items[0])).To(Equal(webhooks.Items[1]))
 		})
 
+		It("only sends the editable fields", func() {
+			w := &Webhook{
+				ID:        "1",
+				Name:      "webhook 1",
+				TargetURL: "https://example.com/hook1",
+				Resource:  "messages",
+				Event:     "created",
+				Filter:    "roomId=room",
+				Secret:    "secret",
+				Status:    "inactive",
+				OrgID:     "org",
+				CreatedBy: "creator",
+				AppID:     "app",
+				OwnedBy:   "creator",
+				ActorID:   "actor",
+				Data:      map[string]interface{}{"key": "value"},
+			}
+
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.String()).To(Equal(fmt.Sprintf("%s/%s", WebhooksURL, w.ID)))
+
+				var sent map[string]interface{}
+				Expect(json.NewDecoder(req.Body).Decode(&sent)).To(Succeed())
+				Expect(sent).To(Equal(map[string]interface{}{
+					"name":      "webhook 1",
+					"targetUrl": "https://example.com/hook1",
+					"resource":  "messages",
+					"event":     "created",
+					"filter":    "roomId=room",
+					"secret":    "secret",
+					"status":    "inactive",
+				}))
+
+				return &http.Response{Body: closer(bytes.NewBufferString(`{"id":"1"}`)), StatusCode: http.StatusOK}, nil
+			}
+
+			_, err := c.UpdateWebhook(w)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
 		It("fails if a nil argument is provided", func() {
 			p, err := c.UpdateWebhook(nil)
 			Expect(err).To(MatchError("nil webhook"))
@@ -384,6 +676,13 @@ var _ = Describe("Webhook (Mock)", func() {
 			Expect(p).To(BeNil())
 		})
 
+		It("fails if the webhook target URL isn't https", func() {
+			webhooks.Items[0].TargetURL = "http://example.com/hook"
+			p, err := c.UpdateWebhook(webhooks.Items[0])
+			Expect(err).To(MatchError(`webhook target URL "http://example.com/hook" must use https`))
+			Expect(p).To(BeNil())
+		})
+
 		It("passes through errors encountered during the request", func() {
 			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
 				return nil, mockErr
@@ -422,4 +721,360 @@ var _ = Describe("Webhook (Mock)", func() {
 			Expect(c.DeleteWebhook("1")).To(MatchError(mockErr))
 		})
 	})
+
+	Describe("WebhookFilter", func() {
+		It("builds a URL-encoded filter", func() {
+			filter, err := NewWebhookFilter("messages").
+				RoomID("room/1").
+				PersonEmail("a+b@world.com").
+				ParentID("parent 1").
+				Build()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(filter).To(Equal("parentId=parent+1&personEmail=a%2Bb%40world.com&roomId=room%2F1"))
+
+			parsed, err := url.ParseQuery(filter)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(parsed.Get("parentId")).To(Equal("parent 1"))
+			Expect(parsed.Get("personEmail")).To(Equal("a+b@world.com"))
+		})
+
+		It("builds an empty filter if nothing was added", func() {
+			Expect(NewWebhookFilter("rooms").Build()).To(BeEmpty())
+		})
+
+		It("joins mentioned people", func() {
+			Expect(NewWebhookFilter("messages").MentionedPeople("me", "person 2").Build()).To(Equal("mentionedPeople=me%2Cperson+2"))
+		})
+
+		It("rejects filters that don't apply to the resource", func() {
+			filter, err := NewWebhookFilter("memberships").RoomID("room").ParentID("parent").MentionedPeople("me").Build()
+			Expect(err).To(MatchError("invalid webhook filter: parentId can't filter memberships webhooks; mentionedPeople can't filter memberships webhooks"))
+			Expect(filter).To(BeEmpty())
+		})
+
+		It("round-trips through CreateWebhook and UpdateWebhook", func() {
+			filter, err := NewWebhookFilter("messages").RoomID("room").ParentID("parent").Build()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var sent []string
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var body map[string]interface{}
+				Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+				sent = append(sent, body["filter"].(string))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(&Webhook{ID: "1", Name: "hook", TargetURL: "https://example.com/hook", Filter: body["filter"].(string)})).To(Succeed())
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+
+			created, err := c.CreateWebhook(&NewWebhook{Name: "hook", TargetURL: "https://example.com/hook", Resource: "messages", Event: "created", Filter: filter})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(created.Filter).To(Equal(filter))
+
+			updated, err := c.UpdateWebhook(created)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(updated.Filter).To(Equal(filter))
+			Expect(sent).To(Equal([]string{filter, filter}))
+		})
+	})
+
+	Describe("FindWebhooksByTarget", func() {
+		BeforeEach(func() {
+			webhooks.Items[2].TargetURL = webhooks.Items[0].TargetURL
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(strings.Split(req.URL.String(), "?")[0]).To(Equal(WebhooksURL))
+				Expect(req.Method).To(Equal("GET"))
+
+				var b bytes.Buffer
+				Expect(json.NewEncoder(&b).Encode(webhooks)).To(Succeed())
+				return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+			}
+		})
+
+		It("finds the webhooks with an exactly matching target URL", func() {
+			Expect(c.FindWebhooksByTarget("https://example.com/hook1")).To(Equal([]*Webhook{webhooks.Items[0], webhooks.Items[2]}))
+			Expect(c.FindWebhooksByTarget("https://example.com/hook1/")).To(BeEmpty())
+			Expect(c.FindWebhooksByTarget("https://example.com/HOOK1")).To(BeEmpty())
+		})
+
+		It("fails if no target URL is specified", func() {
+			w, err := c.FindWebhooksByTarget("")
+			Expect(err).To(MatchError("no webhook target URL specified"))
+			Expect(w).To(BeNil())
+		})
+
+		It("passes through errors encountered listing the webhooks", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, mockErr
+			}
+			w, err := c.FindWebhooksByTarget("https://example.com/hook1")
+			Expect(err).To(MatchError(mockErr))
+			Expect(w).To(BeNil())
+		})
+	})
+
+	Describe("DeleteWebhooksByTarget", func() {
+		var deleted []string
+		var failing map[string]bool
+
+		BeforeEach(func() {
+			deleted = nil
+			failing = map[string]bool{}
+			webhooks.Items[2].TargetURL = webhooks.Items[0].TargetURL
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				if req.Method == "GET" {
+					var b bytes.Buffer
+					Expect(json.NewEncoder(&b).Encode(webhooks)).To(Succeed())
+					return &http.Response{Body: closer(&b), StatusCode: http.StatusOK}, nil
+				}
+
+				Expect(req.Method).To(Equal("DELETE"))
+				id := strings.TrimPrefix(req.URL.String(), WebhooksURL+"/")
+				if failing[id] {
+					return &http.Response{Body: closer(&bytes.Buffer{}), StatusCode: http.StatusNotFound}, nil
+				}
+				deleted = append(deleted, id)
+				return &http.Response{Body: closer(&bytes.Buffer{}), StatusCode: http.StatusNoContent}, nil
+			}
+		})
+
+		It("deletes every webhook with a matching target URL", func() {
+			Expect(c.DeleteWebhooksByTarget("https://example.com/hook1")).To(Equal(2))
+			Expect(deleted).To(Equal([]string{"1", "3"}))
+		})
+
+		It("deletes nothing if no webhooks match", func() {
+			Expect(c.DeleteWebhooksByTarget("https://example.com/other")).To(Equal(0))
+			Expect(deleted).To(BeEmpty())
+		})
+
+		It("keeps deleting after a failure, and aggregates the errors", func() {
+			failing["1"] = true
+
+			n, err := c.DeleteWebhooksByTarget("https://example.com/hook1")
+			Expect(n).To(Equal(1))
+			Expect(deleted).To(Equal([]string{"3"}))
+
+			var errs WebhookErrors
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs).To(HaveLen(1))
+			Expect(IsNotFound(errs["1"])).To(BeTrue())
+			Expect(err.Error()).To(HavePrefix("webhook 1: "))
+		})
+
+		It("fails without deleting anything if the webhooks can't be listed", func() {
+			mockCli.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(req.Method).To(Equal("GET"))
+				return nil, mockErr
+			}
+			n, err := c.DeleteWebhooksByTarget("https://example.com/hook1")
+			Expect(err).To(MatchError(mockErr))
+			Expect(n).To(BeZero())
+		})
+	})
+
+	Describe("WebhookEvent", func() {
+		It("reports an event triggered by the provided person", func() {
+			e := WebhookEvent{ActorID: "me"}
+			Expect(e.IsFromSelf(&Person{ID: "me"})).To(BeTrue())
+		})
+
+		It("reports an event triggered by someone else", func() {
+			e := WebhookEvent{ActorID: "someone else"}
+			Expect(e.IsFromSelf(&Person{ID: "me"})).To(BeFalse())
+		})
+
+		It("never matches a missing identity", func() {
+			var nilEvent *WebhookEvent
+			Expect(nilEvent.IsFromSelf(&Person{ID: "me"})).To(BeFalse())
+			Expect((&WebhookEvent{}).IsFromSelf(&Person{})).To(BeFalse())
+			Expect((&WebhookEvent{ActorID: "me"}).IsFromSelf(nil)).To(BeFalse())
+		})
+
+		It("parses an event and decodes its data", func() {
+			body := []byte(`{"id":"hook","resource":"messages","event":"created","data":{"id":"message","roomId":"room"}}`)
+			e, err := ParseWebhookEvent(body)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(e.ID).To(Equal("hook"))
+			Expect(e.Resource).To(Equal("messages"))
+
+			var m Message
+			Expect(e.DecodeData(&m)).To(Succeed())
+			Expect(m).To(Equal(Message{ID: "message", RoomID: "room"}))
+		})
+
+		It("fails to parse a malformed event", func() {
+			e, err := ParseWebhookEvent([]byte("not json"))
+			Expect(err).Should(HaveOccurred())
+			Expect(e).To(BeNil())
+		})
+
+		It("fails to decode missing data", func() {
+			var m Message
+			Expect((&WebhookEvent{}).DecodeData(&m)).To(MatchError("webhook event has no data"))
+		})
+	})
+
+	Describe("PingWebhookTarget", func() {
+		var srv *httptest.Server
+		var status int
+
+		BeforeEach(func() {
+			status = http.StatusOK
+			srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.Method).To(Equal("HEAD"))
+				Expect(req.Header.Get("Authorization")).To(BeEmpty())
+				if status == 0 {
+					time.Sleep(100 * time.Millisecond)
+					return
+				}
+				w.WriteHeader(status)
+			}))
+			c = c.SetHTTPClient(srv.Client())
+		})
+
+		AfterEach(func() {
+			srv.Close()
+		})
+
+		It("succeeds if the target responds", func() {
+			Expect(c.PingWebhookTarget(srv.URL)).To(Succeed())
+		})
+
+		It("treats client errors as reachable", func() {
+			status = http.StatusMethodNotAllowed
+			Expect(c.PingWebhookTarget(srv.URL)).To(Succeed())
+		})
+
+		It("fails if the target responds with a server error", func() {
+			status = http.StatusBadGateway
+			Expect(c.PingWebhookTarget(srv.URL)).To(MatchError(fmt.Sprintf("webhook target %q responded with HTTP Status 502", srv.URL)))
+		})
+
+		It("fails if the target is unreachable", func() {
+			u := srv.URL
+			srv.Close()
+
+			err := c.PingWebhookTarget(u)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix(fmt.Sprintf("webhook target %q is unreachable: ", u)))
+		})
+
+		It("respects the http client's timeout", func() {
+			status = 0
+			cli := srv.Client()
+			cli.Timeout = 10 * time.Millisecond
+
+			err := c.SetHTTPClient(cli).PingWebhookTarget(srv.URL)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("unreachable"))
+		})
+
+		It("fails if the target URL is invalid", func() {
+			Expect(c.PingWebhookTarget("http://example.com")).To(MatchError(`webhook target URL "http://example.com" must use https`))
+		})
+	})
+
+	Describe("GenerateWebhookSecret", func() {
+		It("generates unique, URL-safe secrets", func() {
+			seen := make(map[string]bool)
+			for i := 0; i < 100; i++ {
+				secret, err := GenerateWebhookSecret()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(secret).To(HaveLen(43)) // 32 bytes, unpadded base64
+				Expect(secret).To(MatchRegexp(`^[A-Za-z0-9_-]+$`))
+				Expect(seen).ToNot(HaveKey(secret))
+				seen[secret] = true
+			}
+		})
+	})
+
+	Describe("VerifyWebhookSignature", func() {
+		body := []byte(`{"id":"1","resource":"messages","event":"created"}`)
+		secret := "secret"
+		// echo -n "$body" | openssl dgst -sha1 -hmac secret
+		signature := "63500a704247ed57be3f39bea751c1d703110755"
+
+		It("accepts a valid signature", func() {
+			Expect(VerifyWebhookSignature(body, signature, secret)).To(BeTrue())
+			Expect(VerifyWebhookSignature(body, strings.ToUpper(signature), secret)).To(BeTrue())
+		})
+
+		It("rejects an invalid signature", func() {
+			Expect(VerifyWebhookSignature(body, signature, "other secret")).To(BeFalse())
+			Expect(VerifyWebhookSignature(append(body, ' '), signature, secret)).To(BeFalse())
+			Expect(VerifyWebhookSignature(body, "not hex", secret)).To(BeFalse())
+			Expect(VerifyWebhookSignature(body, "", secret)).To(BeFalse())
+		})
+	})
+
+	Describe("NewWebhookReceiver", func() {
+		body := `{"id":"1","resource":"messages","event":"created"}`
+		secret := "secret"
+		signature := "63500a704247ed57be3f39bea751c1d703110755" // as in the VerifyWebhookSignature tests
+
+		var received []*WebhookEvent
+		var srv *httptest.Server
+
+		BeforeEach(func() {
+			received = nil
+		})
+
+		AfterEach(func() {
+			srv.Close()
+		})
+
+		serve := func(secret string) {
+			srv = httptest.NewServer(NewWebhookReceiver(secret, func(e *WebhookEvent) {
+				received = append(received, e)
+			}))
+		}
+
+		post := func(body, signature string) int {
+			req, err := http.NewRequest("POST", srv.URL, strings.NewReader(body))
+			Expect(err).ShouldNot(HaveOccurred())
+			if signature != "" {
+				req.Header.Set("X-Spark-Signature", signature)
+			}
+			res, err := http.DefaultClient.Do(req)
+			Expect(err).ShouldNot(HaveOccurred())
+			res.Body.Close()
+			return res.StatusCode
+		}
+
+		It("dispatches signed events to the handler", func() {
+			serve(secret)
+			Expect(post(body, signature)).To(Equal(http.StatusOK))
+			Expect(received).To(Equal([]*WebhookEvent{{ID: "1", Resource: "messages", Event: "created"}}))
+		})
+
+		It("rejects unsigned and incorrectly signed events", func() {
+			serve(secret)
+			Expect(post(body, "")).To(Equal(http.StatusBadRequest))
+			Expect(post(body, "63500a704247ed57be3f39bea751c1d703110756")).To(Equal(http.StatusBadRequest))
+			Expect(post(body+" ", signature)).To(Equal(http.StatusBadRequest))
+			Expect(received).To(BeEmpty())
+		})
+
+		It("accepts unsigned events if it has no secret", func() {
+			serve("")
+			Expect(post(body, "")).To(Equal(http.StatusOK))
+			Expect(received).To(HaveLen(1))
+		})
+
+		It("rejects bodies that aren't events", func() {
+			serve("")
+			Expect(post("not json", "")).To(Equal(http.StatusBadRequest))
+			Expect(received).To(BeEmpty())
+		})
+
+		It("only accepts POSTs", func() {
+			serve("")
+			res, err := http.Get(srv.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			res.Body.Close()
+			Expect(res.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+			Expect(received).To(BeEmpty())
+		})
+	})
 })